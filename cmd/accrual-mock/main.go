@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/accrualmock"
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+)
+
+const (
+	defaultRunAddress        = "localhost:3560"
+	defaultReadHeaderTimeout = 5 * time.Second
+)
+
+func main() {
+	runAddress := flag.String("a", defaultRunAddress, "HTTP server endpoint")
+	rewardsFile := flag.String("rewards", "",
+		`JSON file with rewards to seed, example: [{"order":"2377225624","accrual":500}]`)
+	flag.Parse()
+
+	if envRunAddress := os.Getenv("RUN_ADDRESS"); envRunAddress != "" {
+		*runAddress = envRunAddress
+	}
+
+	l := logger.New("info", logger.NewDefaultLoggerConfig()).With(zap.String("app", "accrual-mock"))
+
+	server := accrualmock.New(l)
+
+	if *rewardsFile != "" {
+		if err := seedRewards(server, *rewardsFile); err != nil {
+			log.Printf("can't seed rewards: %s", err.Error())
+			return
+		}
+	}
+
+	httpServer := &http.Server{
+		Addr:              *runAddress,
+		Handler:           server.Router(),
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+	}
+
+	l.Info("server started", zap.String("addr", *runAddress))
+	if err := httpServer.ListenAndServe(); err != nil {
+		log.Printf("server terminated with error: %s", err.Error())
+	}
+}
+
+func seedRewards(server *accrualmock.Server, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var rewards []accrualmock.Reward
+	if err = json.Unmarshal(content, &rewards); err != nil {
+		return err
+	}
+
+	for _, reward := range rewards {
+		server.AddReward(reward)
+	}
+
+	return nil
+}
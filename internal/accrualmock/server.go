@@ -0,0 +1,200 @@
+package accrualmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusProcessing = "PROCESSING"
+	StatusInvalid    = "INVALID"
+	StatusProcessed  = "PROCESSED"
+)
+
+// Step is one scripted response of the accrual system. Code defaults to 200,
+// RetryAfter is sent in seconds with 429 responses.
+type Step struct {
+	Accrual    *decimal.Decimal `json:"accrual,omitempty"`
+	Status     string           `json:"status,omitempty"`
+	Code       int              `json:"code,omitempty"`
+	RetryAfter int              `json:"retry_after,omitempty"`
+}
+
+// Script is the sequence of responses for an order, every request moves to the next step
+// and the last step is repeated forever.
+type Script struct {
+	Steps []Step `json:"steps"`
+}
+
+// Reward seeds an order that goes through REGISTERED and PROCESSING to PROCESSED with the accrual.
+type Reward struct {
+	Order   string          `json:"order"`
+	Accrual decimal.Decimal `json:"accrual"`
+}
+
+type order struct {
+	steps []Step
+	next  int
+}
+
+// Server is an in-process implementation of the accrual system protocol with scriptable responses.
+type Server struct {
+	log    *zap.Logger
+	orders map[string]*order
+	mu     sync.Mutex
+}
+
+func New(log *zap.Logger) *Server {
+	return &Server{
+		log:    log.With(zap.String("server", "accrual mock")),
+		orders: make(map[string]*order),
+	}
+}
+
+func (s *Server) Router() *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Get("/api/orders/{number}", s.orderStatus)
+
+	router.Route("/api/admin", func(r chi.Router) {
+		r.Post("/rewards", s.seedRewards)
+		r.Put("/orders/{number}", s.seedScript)
+		r.Delete("/orders", s.reset)
+	})
+
+	return router
+}
+
+// SetScript replaces the responses for the order number.
+func (s *Server) SetScript(number string, script Script) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[number] = &order{steps: script.Steps}
+}
+
+// AddReward scripts the usual REGISTERED, PROCESSING, PROCESSED transitions for the order.
+func (s *Server) AddReward(reward Reward) {
+	accrual := reward.Accrual
+
+	s.SetScript(reward.Order, Script{Steps: []Step{
+		{Status: StatusRegistered},
+		{Status: StatusProcessing},
+		{Status: StatusProcessed, Accrual: &accrual},
+	}})
+}
+
+// Reset forgets all orders, unknown orders are answered with 204.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders = make(map[string]*order)
+}
+
+func (s *Server) nextStep(number string) (Step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[number]
+	if !ok || len(o.steps) == 0 {
+		return Step{}, false
+	}
+
+	step := o.steps[o.next]
+	if o.next < len(o.steps)-1 {
+		o.next++
+	}
+
+	return step, true
+}
+
+func (s *Server) orderStatus(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	step, ok := s.nextStep(number)
+	if !ok {
+		s.log.Info("unknown order", zap.String("order", number))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.log.Info("scripted response", zap.String("order", number), zap.Int("code", step.Code),
+		zap.String("status", step.Status))
+
+	switch step.Code {
+	case 0, http.StatusOK:
+	case http.StatusTooManyRequests:
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", strconv.Itoa(step.RetryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("No more than N requests per minute allowed"))
+		return
+	default:
+		w.WriteHeader(step.Code)
+		return
+	}
+
+	decimal.MarshalJSONWithoutQuotes = true
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, struct {
+		Accrual *decimal.Decimal `json:"accrual,omitempty"`
+		Order   string           `json:"order"`
+		Status  string           `json:"status"`
+	}{
+		Accrual: step.Accrual,
+		Order:   number,
+		Status:  step.Status,
+	})
+}
+
+func (s *Server) seedRewards(w http.ResponseWriter, r *http.Request) {
+	var rewards []Reward
+
+	err := json.NewDecoder(r.Body).Decode(&rewards)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": "can't parse request body"})
+		return
+	}
+
+	for _, reward := range rewards {
+		s.AddReward(reward)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, render.M{"message": "rewards added", "count": len(rewards)})
+}
+
+func (s *Server) seedScript(w http.ResponseWriter, r *http.Request) {
+	var script Script
+
+	err := json.NewDecoder(r.Body).Decode(&script)
+	if err != nil || len(script.Steps) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": "can't parse request body"})
+		return
+	}
+
+	s.SetScript(chi.URLParam(r, "number"), script)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, render.M{"message": "script saved"})
+}
+
+func (s *Server) reset(w http.ResponseWriter, r *http.Request) {
+	s.Reset()
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, render.M{"message": "all orders removed"})
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+// Callback applies the order status pushed by the accrual system. Callbacks for orders
+// that are already final are acknowledged without changes, so they can be safely redelivered.
+func (ah *AccrualHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var cr CallbackRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&cr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	err = ah.validate.Struct(cr)
+	if err != nil || (cr.Accrual != nil && cr.Accrual.IsNegative()) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	err = ah.accrualService.ApplyCallback(r.Context(), ToAccrualInfo(&cr))
+	if errors.Is(err, entity.ErrUnknownOrderStatus) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": entity.ErrUnknownOrderStatus.Error()})
+		return
+	}
+	if errors.Is(err, entity.ErrOrderNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, render.M{"message": entity.ErrOrderNotFound.Error()})
+		return
+	}
+	if errors.Is(err, entity.ErrOrderAlreadyFinal) {
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, render.M{"message": entity.ErrOrderAlreadyFinal.Error()})
+		return
+	}
+	if err != nil {
+		ah.log.Error("can't apply accrual callback", zap.String("order", cr.Order), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, render.M{"message": "accrual status applied"})
+}
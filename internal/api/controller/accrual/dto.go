@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"github.com/shopspring/decimal"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type CallbackRequest struct {
+	Accrual *decimal.Decimal `json:"accrual,omitempty"`
+	Order   string           `json:"order" validate:"required,gte=4,lte=255"`
+	Status  string           `json:"status" validate:"required"`
+}
+
+func ToAccrualInfo(cr *CallbackRequest) *entity.AccrualInfo {
+	accrualInfo := &entity.AccrualInfo{
+		Number: cr.Order,
+		Status: cr.Status,
+	}
+
+	if cr.Accrual != nil {
+		accrualInfo.Accrual = cr.Accrual.Mul(decimal.NewFromInt(entity.DecimalPartDiv)).IntPart()
+	}
+
+	return accrualInfo
+}
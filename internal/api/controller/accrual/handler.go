@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type AccrualService interface {
+	ApplyCallback(ctx context.Context, accrualInfo *entity.AccrualInfo) error
+}
+
+type AccrualHandler struct {
+	accrualService AccrualService
+	log            *zap.Logger
+	validate       *validator.Validate
+}
+
+func NewAccrualHandler(accrualService AccrualService, validate *validator.Validate) *AccrualHandler {
+	return &AccrualHandler{
+		accrualService: accrualService,
+		log:            zap.L().With(zap.String("handler", "accrual")),
+		validate:       validate,
+	}
+}
@@ -7,15 +7,42 @@ import (
 )
 
 type UserResponse struct {
-	ID        string `json:"id"`
-	Username  string `json:"username"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID        string         `json:"id"`
+	Username  string         `json:"username"`
+	CreatedAt string         `json:"created_at"`
+	UpdatedAt string         `json:"updated_at"`
+	Tokens    *TokenResponse `json:"tokens,omitempty"`
+}
+
+type TokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required,lte=255"`
 }
 
 type UserRequest struct {
 	Username string `json:"login" validate:"required,gte=2,lte=255"`
-	Password string `json:"password" validate:"required,gt=8,lte=1000"`
+	Password string `json:"password" validate:"required,lte=1000"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required,lte=1000"`
+	NewPassword     string `json:"new_password" validate:"required,lte=1000"`
+}
+
+type PasswordResetRequest struct {
+	Username string `json:"login" validate:"required,gte=2,lte=255"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required,lte=255"`
+	NewPassword string `json:"new_password" validate:"required,lte=1000"`
 }
 
 func ToUserResponse(user *entity.User) *UserResponse {
@@ -26,3 +53,13 @@ func ToUserResponse(user *entity.User) *UserResponse {
 		UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
 	}
 }
+
+func ToTokenResponse(tokens *entity.Tokens) *TokenResponse {
+	return &TokenResponse{
+		AccessToken:      tokens.AccessToken,
+		TokenType:        AuthorizationSchema,
+		ExpiresIn:        int64(time.Until(tokens.AccessExpiresAt).Round(time.Second).Seconds()),
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt.Format(time.RFC3339),
+	}
+}
@@ -2,7 +2,14 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
 
+	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
 
@@ -16,7 +23,14 @@ const (
 
 type AuthService interface {
 	Register(ctx context.Context, username, password string) (*entity.User, error)
-	Login(ctx context.Context, username, password string) (*entity.User, error)
+	Login(ctx context.Context, loginInfo *entity.LoginInfo) (*entity.User, error)
+	NewSession(ctx context.Context, userID string) (*entity.Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (*entity.Tokens, error)
+	Logout(ctx context.Context, userID, sessionID string) error
+	LogoutAll(ctx context.Context, userID string) (int64, error)
+	ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (*entity.Tokens, error)
+	RequestPasswordReset(ctx context.Context, username, remoteIP string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
 }
 
 type AuthHandler struct {
@@ -32,3 +46,43 @@ func NewAuthHandler(authService AuthService, validate *validator.Validate) *Auth
 		validate:    validate,
 	}
 }
+
+func setAuthorization(w http.ResponseWriter, tokens *entity.Tokens) {
+	w.Header().Set(AuthorizationHeader, fmt.Sprintf("%s %s", AuthorizationSchema, tokens.AccessToken))
+}
+
+// writeThrottled responds with 429 and the "Retry-After" header if the error is ThrottleError.
+func writeThrottled(w http.ResponseWriter, r *http.Request, err error) bool {
+	var throttleErr *entity.ThrottleError
+	if !errors.As(err, &throttleErr) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttleErr.RetryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	render.JSON(w, r, render.M{"message": entity.ErrTooManyAttempts.Error()})
+
+	return true
+}
+
+// writeWeakPassword responds with 400 and the violated rule if the error is PasswordViolation.
+func writeWeakPassword(w http.ResponseWriter, r *http.Request, err error) bool {
+	var violation *entity.PasswordViolation
+	if !errors.As(err, &violation) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	render.JSON(w, r, render.M{"message": violation.Message, "rule": violation.Rule})
+
+	return true
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -3,15 +3,14 @@ package controller
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/go-chi/render"
+	"go.uber.org/zap"
 
 	"github.com/ivas1ly/gophermart/internal/api/controller"
 	"github.com/ivas1ly/gophermart/internal/entity"
-	"github.com/ivas1ly/gophermart/pkg/jwt"
 )
 
 func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +38,15 @@ func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := ah.authService.Login(r.Context(), ur.Username, ur.Password)
+	user, err := ah.authService.Login(r.Context(), &entity.LoginInfo{
+		Username: ur.Username,
+		Password: ur.Password,
+		RemoteIP: remoteIP(r),
+	})
+	if writeThrottled(w, r, err) {
+		ah.log.Info("login throttled", zap.String("username", ur.Username), zap.String("remote", r.RemoteAddr))
+		return
+	}
 	if errors.Is(err, entity.ErrUsernameNotFound) {
 		w.WriteHeader(http.StatusNotFound)
 		render.JSON(w, r, render.M{"message": entity.ErrUsernameNotFound.Error()})
@@ -56,16 +63,17 @@ func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := ToUserResponse(user)
-
-	authToken, err := jwt.NewToken(jwt.SigningKey, user.ID)
+	tokens, err := ah.authService.NewSession(r.Context(), user.ID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
 		return
 	}
 
-	w.Header().Set(AuthorizationHeader, fmt.Sprintf("%s %s", AuthorizationSchema, authToken))
+	response := ToUserResponse(user)
+	response.Tokens = ToTokenResponse(tokens)
+
+	setAuthorization(w, tokens)
 	w.WriteHeader(http.StatusOK)
 	render.JSON(w, r, response)
 }
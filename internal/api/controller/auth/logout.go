@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/jwt"
+)
+
+// Logout revokes the session of the access token.
+func (ah *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, claims, _ := jwtauth.FromContext(r.Context())
+
+	userID := token.Subject()
+	sessionID, _ := claims[jwt.SessionClaim].(string)
+
+	err := ah.authService.Logout(r.Context(), userID, sessionID)
+	if err != nil && !errors.Is(err, entity.ErrSessionNotFound) {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll revokes all sessions of the user, including the current one.
+func (ah *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _, _ := jwtauth.FromContext(r.Context())
+
+	userID := token.Subject()
+
+	revoked, err := ah.authService.LogoutAll(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	ah.log.Info("all user sessions revoked", zap.String("user_id", userID), zap.Int64("sessions", revoked))
+
+	w.WriteHeader(http.StatusNoContent)
+}
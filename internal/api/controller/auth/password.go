@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+// ChangePassword sets the new password of the user, all sessions are revoked and the tokens
+// of a new session are returned.
+func (ah *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _, _ := jwtauth.FromContext(r.Context())
+
+	userID := token.Subject()
+
+	var cpr ChangePasswordRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&cpr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	err = ah.validate.Struct(cpr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	tokens, err := ah.authService.ChangePassword(r.Context(), userID, cpr.CurrentPassword, cpr.NewPassword)
+	if writeThrottled(w, r, err) || writeWeakPassword(w, r, err) {
+		return
+	}
+	if errors.Is(err, entity.ErrIncorrectPassword) {
+		w.WriteHeader(http.StatusForbidden)
+		render.JSON(w, r, render.M{"message": entity.ErrIncorrectPassword.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	ah.log.Info("password changed, user sessions revoked", zap.String("user_id", userID))
+
+	setAuthorization(w, tokens)
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, ToTokenResponse(tokens))
+}
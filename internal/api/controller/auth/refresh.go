@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func (ah *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var rr RefreshRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&rr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	err = ah.validate.Struct(rr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	tokens, err := ah.authService.Refresh(r.Context(), rr.RefreshToken)
+	if errors.Is(err, entity.ErrRefreshTokenReused) {
+		ah.log.Warn("used refresh token presented again, session revoked", zap.String("remote", r.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		render.JSON(w, r, render.M{"message": entity.ErrInvalidRefreshToken.Error()})
+		return
+	}
+	if errors.Is(err, entity.ErrInvalidRefreshToken) {
+		w.WriteHeader(http.StatusUnauthorized)
+		render.JSON(w, r, render.M{"message": entity.ErrInvalidRefreshToken.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	setAuthorization(w, tokens)
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, ToTokenResponse(tokens))
+}
@@ -11,7 +11,6 @@ import (
 
 	"github.com/ivas1ly/gophermart/internal/api/controller"
 	"github.com/ivas1ly/gophermart/internal/entity"
-	"github.com/ivas1ly/gophermart/pkg/jwt"
 )
 
 func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -40,6 +39,9 @@ func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := ah.authService.Register(r.Context(), ur.Username, ur.Password)
+	if writeThrottled(w, r, err) || writeWeakPassword(w, r, err) {
+		return
+	}
 	if errors.Is(err, entity.ErrUsernameUniqueViolation) {
 		w.WriteHeader(http.StatusConflict)
 		render.JSON(w, r, render.M{"message": fmt.Sprintf("username %q already exists", ur.Username)})
@@ -51,16 +53,17 @@ func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := ToUserResponse(user)
-
-	authToken, err := jwt.NewToken(jwt.SigningKey, user.ID)
+	tokens, err := ah.authService.NewSession(r.Context(), user.ID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
 		return
 	}
 
-	w.Header().Set(AuthorizationHeader, fmt.Sprintf("%s %s", AuthorizationSchema, authToken))
+	response := ToUserResponse(user)
+	response.Tokens = ToTokenResponse(tokens)
+
+	setAuthorization(w, tokens)
 	w.WriteHeader(http.StatusOK)
 	render.JSON(w, r, response)
 }
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+const msgPasswordResetRequested = "if the user exists, the password reset token is sent"
+
+// RequestPasswordReset sends the password reset token to the user. The response is the same
+// for unknown users, too many requests for the username or from the IP are throttled.
+func (ah *AuthHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var prr PasswordResetRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&prr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	err = ah.validate.Struct(prr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	err = ah.authService.RequestPasswordReset(r.Context(), prr.Username, remoteIP(r))
+	if writeThrottled(w, r, err) {
+		ah.log.Info("password reset request throttled", zap.String("remote", r.RemoteAddr))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	render.JSON(w, r, render.M{"message": msgPasswordResetRequested})
+}
+
+// ResetPassword sets the new password by the reset token, all sessions of the user are revoked.
+func (ah *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var rpr ResetPasswordRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&rpr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	err = ah.validate.Struct(rpr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	err = ah.authService.ResetPassword(r.Context(), rpr.Token, rpr.NewPassword)
+	if writeThrottled(w, r, err) || writeWeakPassword(w, r, err) {
+		return
+	}
+	if errors.Is(err, entity.ErrInvalidResetToken) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": entity.ErrInvalidResetToken.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
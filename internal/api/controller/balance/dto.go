@@ -6,11 +6,30 @@ import (
 	"github.com/shopspring/decimal"
 
 	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/cursor"
 )
 
+// BalanceResponse keeps the default program balance at the top level, balances of all loyalty
+// programs are listed in Programs.
 type BalanceResponse struct {
-	Balance   decimal.Decimal `json:"current"`
-	Withdrawn decimal.Decimal `json:"withdrawn"`
+	ExpiringSoon  *decimal.Decimal         `json:"expiring_soon,omitempty"`
+	Balance       decimal.Decimal          `json:"current"`
+	Withdrawn     decimal.Decimal          `json:"withdrawn"`
+	Pending       decimal.Decimal          `json:"pending"`
+	Programs      []ProgramBalanceResponse `json:"programs"`
+	PendingOrders int                      `json:"pending_orders"`
+}
+
+type ProgramBalanceResponse struct {
+	ExpiringSoon   *decimal.Decimal `json:"expiring_soon,omitempty"`
+	ID             string           `json:"id"`
+	Name           string           `json:"name"`
+	ConversionRate decimal.Decimal  `json:"conversion_rate"`
+	Balance        decimal.Decimal  `json:"current"`
+	Withdrawn      decimal.Decimal  `json:"withdrawn"`
+	Pending        decimal.Decimal  `json:"pending"`
+	Precision      int32            `json:"precision"`
+	PendingOrders  int              `json:"pending_orders"`
 }
 
 func ToUserBalanceResponse(userBalance *entity.Balance) *BalanceResponse {
@@ -21,39 +40,124 @@ func ToUserBalanceResponse(userBalance *entity.Balance) *BalanceResponse {
 	decimalBalance := decimal.NewFromInt(userBalance.Balance).Div(divValue)
 	decimalWithdrawn := decimal.NewFromInt(userBalance.Withdrawn).Div(divValue)
 
-	return &BalanceResponse{
-		Balance:   decimalBalance,
-		Withdrawn: decimalWithdrawn,
+	response := &BalanceResponse{
+		Balance:       decimalBalance,
+		Withdrawn:     decimalWithdrawn,
+		Pending:       decimal.NewFromInt(userBalance.Pending).Div(divValue),
+		PendingOrders: userBalance.PendingOrders,
 	}
+
+	if userBalance.ExpiringSoon > 0 {
+		decimalExpiringSoon := decimal.NewFromInt(userBalance.ExpiringSoon).Div(divValue)
+		response.ExpiringSoon = &decimalExpiringSoon
+	}
+
+	response.Programs = make([]ProgramBalanceResponse, 0, len(userBalance.Programs))
+	for _, programBalance := range userBalance.Programs {
+		programResponse := ProgramBalanceResponse{
+			ID:             programBalance.Program.ID,
+			Name:           programBalance.Program.Name,
+			ConversionRate: programBalance.Program.ConversionRate,
+			Balance:        programBalance.Program.ToPoints(programBalance.Balance),
+			Withdrawn:      programBalance.Program.ToPoints(programBalance.Withdrawn),
+			Pending:        programBalance.Program.ToPoints(programBalance.Pending),
+			Precision:      programBalance.Program.Precision,
+			PendingOrders:  programBalance.PendingOrders,
+		}
+
+		if programBalance.ExpiringSoon > 0 {
+			expiringSoon := programBalance.Program.ToPoints(programBalance.ExpiringSoon)
+			programResponse.ExpiringSoon = &expiringSoon
+		}
+
+		response.Programs = append(response.Programs, programResponse)
+	}
+
+	return response
 }
 
 type WithdrawRequest struct {
-	Order string          `json:"order" validate:"required,gte=4,lte=255"`
-	Sum   decimal.Decimal `json:"sum" validate:"required"`
+	Order   string          `json:"order" validate:"required,gte=4,lte=255"`
+	Program string          `json:"program,omitempty" validate:"omitempty,lte=64"`
+	Sum     decimal.Decimal `json:"sum" validate:"required"`
 }
 
 type WithdrawResponse struct {
-	ProcessedAt time.Time       `json:"processed_at"`
-	Order       string          `json:"order"`
-	Sum         decimal.Decimal `json:"sum"`
+	ProcessedAt    time.Time       `json:"processed_at"`
+	ReversedAt     *time.Time      `json:"reversed_at,omitempty"`
+	Order          string          `json:"order"`
+	Program        string          `json:"program,omitempty"`
+	Status         string          `json:"status"`
+	ReversalReason string          `json:"reversal_reason,omitempty"`
+	Sum            decimal.Decimal `json:"sum"`
+}
+
+func ToWithdrawResponse(withdraw *entity.Withdraw) WithdrawResponse {
+	decimal.MarshalJSONWithoutQuotes = true
+
+	return WithdrawResponse{
+		ProcessedAt:    withdraw.CreatedAt,
+		ReversedAt:     withdraw.ReversedAt,
+		Order:          withdraw.OrderNumber,
+		Program:        withdraw.ProgramID,
+		Status:         withdraw.Status,
+		ReversalReason: withdraw.ReversalReason,
+		Sum:            entity.ToPoints(withdraw.Withdrawn, withdraw.Precision),
+	}
 }
 
 func ToWithdrawalsResponse(withdrawals []entity.Withdraw) []WithdrawResponse {
 	entities := make([]WithdrawResponse, 0, len(withdrawals))
 
+	for i := range withdrawals {
+		entities = append(entities, ToWithdrawResponse(&withdrawals[i]))
+	}
+
+	return entities
+}
+
+type TransactionResponse struct {
+	ProcessedAt time.Time       `json:"processed_at"`
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Program     string          `json:"program"`
+	Order       string          `json:"order,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	Amount      decimal.Decimal `json:"amount"`
+	Balance     decimal.Decimal `json:"balance"`
+}
+
+type TransactionsResponse struct {
+	NextCursor   string                `json:"next_cursor,omitempty"`
+	Transactions []TransactionResponse `json:"transactions"`
+}
+
+func ToTransactionsResponse(transactions []entity.Transaction, hasMore bool) *TransactionsResponse {
+	entities := make([]TransactionResponse, 0, len(transactions))
+
 	decimal.MarshalJSONWithoutQuotes = true
 
-	divValue := decimal.NewFromInt(entity.DecimalPartDiv)
+	for _, transaction := range transactions {
+		entities = append(entities, TransactionResponse{
+			ProcessedAt: transaction.CreatedAt,
+			ID:          transaction.ID,
+			Type:        string(transaction.Kind),
+			Program:     transaction.ProgramID,
+			Order:       transaction.OrderNumber,
+			Reason:      transaction.Reason,
+			Amount:      entity.ToPoints(transaction.Amount, transaction.Precision),
+			Balance:     entity.ToPoints(transaction.Balance, transaction.Precision),
+		})
+	}
 
-	for _, withdraw := range withdrawals {
-		response := WithdrawResponse{
-			ProcessedAt: withdraw.CreatedAt,
-			Order:       withdraw.OrderNumber,
-			Sum:         decimal.NewFromInt(withdraw.Withdrawn).Div(divValue),
-		}
+	response := &TransactionsResponse{
+		Transactions: entities,
+	}
 
-		entities = append(entities, response)
+	if hasMore && len(transactions) > 0 {
+		last := transactions[len(transactions)-1]
+		response.NextCursor = cursor.Cursor{Time: last.CreatedAt, ID: last.ID}.Encode()
 	}
 
-	return entities
+	return response
 }
@@ -12,7 +12,9 @@ import (
 type BalanceService interface {
 	GetCurrentBalance(ctx context.Context, userID string) (*entity.Balance, error)
 	AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo) error
-	GetWithdrawals(ctx context.Context, userID string) ([]entity.Withdraw, error)
+	GetWithdrawals(ctx context.Context, filter *entity.PageFilter) ([]entity.Withdraw, bool, error)
+	GetTransactions(ctx context.Context, filter *entity.TransactionFilter) ([]entity.Transaction, bool, error)
+	GetProgram(ctx context.Context, programID string) (*entity.Program, error)
 }
 
 type BalanceHandler struct {
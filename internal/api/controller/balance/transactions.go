@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/cursor"
+)
+
+// Transactions returns the user ledger from the newest entry to the oldest by default.
+// Besides the page parameters it supports "type" with comma-separated entry kinds.
+func (bh *BalanceHandler) Transactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _, _ := jwtauth.FromContext(r.Context())
+
+	filter, err := toTransactionFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidQuery})
+		return
+	}
+	filter.UserID = token.Subject()
+
+	transactions, hasMore, err := bh.balanceService.GetTransactions(r.Context(), filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	response := ToTransactionsResponse(transactions, hasMore)
+	if hasMore {
+		last := transactions[len(transactions)-1]
+		controller.SetNextLink(w, r, cursor.Cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, response)
+}
+
+func toTransactionFilter(r *http.Request) (*entity.TransactionFilter, error) {
+	query := r.URL.Query()
+
+	page, err := controller.ParsePage(query, controller.DefaultPageLimit, true)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &entity.TransactionFilter{
+		PageFilter: page,
+	}
+
+	if value := query.Get("type"); value != "" {
+		for _, kindValue := range strings.Split(value, ",") {
+			kind, ok := entity.ParseEntryKind(strings.TrimSpace(kindValue))
+			if !ok {
+				return nil, controller.ErrInvalidQuery
+			}
+			filter.Kinds = append(filter.Kinds, kind)
+		}
+	}
+
+	return filter, nil
+}
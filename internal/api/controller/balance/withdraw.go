@@ -10,7 +10,6 @@ import (
 
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-chi/render"
-	"github.com/shopspring/decimal"
 
 	"github.com/ivas1ly/gophermart/internal/api/controller"
 	"github.com/ivas1ly/gophermart/internal/entity"
@@ -53,22 +52,43 @@ func (bh *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	okSum := wr.Sum.GreaterThanOrEqual(decimal.NewFromInt(1).Div(decimal.NewFromInt(entity.DecimalPartDiv)))
-	if !okSum {
+	program, err := bh.balanceService.GetProgram(r.Context(), wr.Program)
+	if errors.Is(err, entity.ErrProgramNotFound) {
 		w.WriteHeader(http.StatusBadRequest)
-		render.JSON(w, r, render.M{"message": "the amount to be withdrawn is less than the minimum amount"})
+		render.JSON(w, r, render.M{"message": entity.ErrProgramNotFound.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
 		return
 	}
 
-	intSum := wr.Sum.Mul(decimal.NewFromInt(entity.DecimalPartDiv)).IntPart()
+	intSum := program.FromPoints(wr.Sum)
+	if intSum < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": "the amount to be withdrawn is less than the minimum amount"})
+		return
+	}
 
 	withdrawInfo := &entity.WithdrawInfo{
 		UserID:      userID,
 		OrderNumber: wr.Order,
+		ProgramID:   program.ID,
 		Sum:         intSum,
 	}
 
 	err = bh.balanceService.AddWithdrawal(r.Context(), withdrawInfo)
+	var violation *entity.RuleViolation
+	if errors.As(err, &violation) {
+		w.WriteHeader(http.StatusForbidden)
+		render.JSON(w, r, render.M{
+			"message": violation.Message,
+			"rule":    violation.Rule,
+			"limit":   violation.Limit,
+		})
+		return
+	}
 	if errors.Is(err, entity.ErrNotEnoughPointsToWithdraw) {
 		w.WriteHeader(http.StatusPaymentRequired)
 		render.JSON(w, r, render.M{"message": entity.ErrNotEnoughPointsToWithdraw.Error()})
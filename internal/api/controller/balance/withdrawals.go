@@ -9,16 +9,25 @@ import (
 
 	"github.com/ivas1ly/gophermart/internal/api/controller"
 	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/cursor"
 )
 
+// Withdrawals returns the user withdrawals from the oldest to the newest by default,
+// the next page is passed in the Link header.
 func (bh *BalanceHandler) Withdrawals(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	token, _, _ := jwtauth.FromContext(r.Context())
 
-	userID := token.Subject()
+	filter, err := controller.ParsePage(r.URL.Query(), controller.DefaultEntityLimit, false)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidQuery})
+		return
+	}
+	filter.UserID = token.Subject()
 
-	withdrawals, err := bh.balanceService.GetWithdrawals(r.Context(), userID)
+	withdrawals, hasMore, err := bh.balanceService.GetWithdrawals(r.Context(), &filter)
 	if errors.Is(err, entity.ErrNoWithdrawalsFound) {
 		w.WriteHeader(http.StatusNoContent)
 		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
@@ -30,6 +39,11 @@ func (bh *BalanceHandler) Withdrawals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if hasMore {
+		last := withdrawals[len(withdrawals)-1]
+		controller.SetNextLink(w, r, cursor.Cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
 	response := ToWithdrawalsResponse(withdrawals)
 
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"go.uber.org/zap"
+)
+
+type KeySet interface {
+	PublicKeys() jwk.Set
+}
+
+type JWKSHandler struct {
+	keys KeySet
+	log  *zap.Logger
+}
+
+func NewJWKSHandler(keys KeySet) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+		log:  zap.L().With(zap.String("handler", "jwks")),
+	}
+}
@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// keysMaxAge lets verifiers cache the keys, a new signing key must be published
+// at least this long before it is used.
+const keysMaxAge = "public, max-age=300"
+
+// JWKS returns the public keys of the token signature, so other services can verify tokens
+// without a shared secret.
+func (jh *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", keysMaxAge)
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, jh.keys.PublicKeys())
+}
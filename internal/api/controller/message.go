@@ -6,4 +6,5 @@ const (
 	MsgInvalidRequest       = "invalid request format"
 	MsgInternalServerError  = "internal server error"
 	MsgIncorrectOrderNumber = "incorrect order number format"
+	MsgInvalidQuery         = "invalid query parameters"
 )
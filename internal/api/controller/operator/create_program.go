@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+// programIDPattern keeps program ids usable in query parameters as is.
+var programIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// CreateProgram adds a loyalty program. The precision can't be changed after the program
+// is created, the conversion rate is the number of program points per accrual system point.
+func (oh *OperatorHandler) CreateProgram(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var pr ProgramRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&pr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	pr.ID = strings.TrimSpace(pr.ID)
+	pr.Name = strings.TrimSpace(pr.Name)
+
+	err = oh.validate.Struct(pr)
+	if err != nil || !programIDPattern.MatchString(pr.ID) || !pr.ConversionRate.IsPositive() {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	program, err := oh.balanceService.AddProgram(r.Context(), &entity.Program{
+		ID:             pr.ID,
+		Name:           pr.Name,
+		ConversionRate: pr.ConversionRate,
+		Precision:      pr.Precision,
+	})
+	if errors.Is(err, entity.ErrProgramUniqueViolation) {
+		w.WriteHeader(http.StatusConflict)
+		render.JSON(w, r, render.M{"message": entity.ErrProgramUniqueViolation.Error()})
+		return
+	}
+	if err != nil {
+		oh.log.Error("can't create loyalty program", zap.String("program", pr.ID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	oh.log.Info("loyalty program created", zap.String("program", program.ID),
+		zap.Int32("precision", program.Precision), zap.String("conversion rate", program.ConversionRate.String()))
+
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, r, ToProgramResponse(program))
+}
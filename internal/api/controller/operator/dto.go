@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type ReverseWithdrawalRequest struct {
+	Reason string `json:"reason" validate:"required,lte=1024"`
+}
+
+type ProgramRequest struct {
+	ID             string          `json:"id" validate:"required,lte=64"`
+	Name           string          `json:"name" validate:"required,lte=255"`
+	ConversionRate decimal.Decimal `json:"conversion_rate" validate:"required"`
+	Precision      int32           `json:"precision" validate:"gte=0,lte=6"`
+}
+
+type ProgramResponse struct {
+	CreatedAt      time.Time       `json:"created_at"`
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	ConversionRate decimal.Decimal `json:"conversion_rate"`
+	Precision      int32           `json:"precision"`
+}
+
+func ToProgramResponse(program *entity.Program) *ProgramResponse {
+	decimal.MarshalJSONWithoutQuotes = true
+
+	return &ProgramResponse{
+		CreatedAt:      program.CreatedAt,
+		ID:             program.ID,
+		Name:           program.Name,
+		ConversionRate: program.ConversionRate,
+		Precision:      program.Precision,
+	}
+}
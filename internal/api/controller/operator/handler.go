@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type BalanceService interface {
+	ReverseWithdrawal(ctx context.Context, reversalInfo *entity.ReversalInfo) (*entity.Withdraw, error)
+	AddProgram(ctx context.Context, program *entity.Program) (*entity.Program, error)
+}
+
+type OperatorHandler struct {
+	balanceService BalanceService
+	log            *zap.Logger
+	validate       *validator.Validate
+}
+
+func NewOperatorHandler(balanceService BalanceService, validate *validator.Validate) *OperatorHandler {
+	return &OperatorHandler{
+		balanceService: balanceService,
+		log:            zap.L().With(zap.String("handler", "operator")),
+		validate:       validate,
+	}
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	balance "github.com/ivas1ly/gophermart/internal/api/controller/balance"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+// ReverseWithdrawal returns the withdrawn points to the user, for example when the shop
+// cancels the order they were spent on.
+func (oh *OperatorHandler) ReverseWithdrawal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	orderNumber := chi.URLParam(r, "number")
+
+	var rr ReverseWithdrawalRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&rr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	rr.Reason = strings.TrimSpace(rr.Reason)
+
+	err = oh.validate.Struct(rr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	withdraw, err := oh.balanceService.ReverseWithdrawal(r.Context(), &entity.ReversalInfo{
+		OrderNumber: orderNumber,
+		Reason:      rr.Reason,
+	})
+	if errors.Is(err, entity.ErrWithdrawalNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, render.M{"message": entity.ErrWithdrawalNotFound.Error()})
+		return
+	}
+	if errors.Is(err, entity.ErrWithdrawalAlreadyReversed) {
+		w.WriteHeader(http.StatusConflict)
+		render.JSON(w, r, render.M{"message": entity.ErrWithdrawalAlreadyReversed.Error()})
+		return
+	}
+	if err != nil {
+		oh.log.Error("can't reverse withdrawal", zap.String("order", orderNumber), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	oh.log.Info("withdrawal reversed", zap.String("order", orderNumber), zap.String("user", withdraw.UserID),
+		zap.Int64("sum", withdraw.Withdrawn), zap.String("reason", rr.Reason))
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, balance.ToWithdrawResponse(withdraw))
+}
@@ -10,6 +10,7 @@ import (
 
 type OrderResponse struct {
 	Number    string `json:"number"`
+	Program   string `json:"program,omitempty"`
 	Status    string `json:"status"`
 	CreatedAt string `json:"uploaded_at"`
 }
@@ -17,13 +18,16 @@ type OrderResponse struct {
 func ToOrderResponse(order *entity.Order) *OrderResponse {
 	return &OrderResponse{
 		Number:    order.Number,
-		Status:    order.Status,
+		Program:   order.ProgramID,
+		Status:    order.Status.String(),
 		CreatedAt: order.CreatedAt.Format(time.RFC3339),
 	}
 }
 
 type OrdersResponse struct {
-	Accrual *decimal.Decimal `json:"accrual,omitempty"`
+	Accrual       *decimal.Decimal `json:"accrual,omitempty"`
+	AccrualStatus string           `json:"accrual_status,omitempty"`
+	CheckedAt     string           `json:"checked_at,omitempty"`
 	OrderResponse
 }
 
@@ -32,21 +36,23 @@ func ToOrdersResponse(orders []entity.Order) []OrdersResponse {
 
 	decimal.MarshalJSONWithoutQuotes = true
 
-	divValue := decimal.NewFromInt(entity.DecimalPartDiv)
-
 	for _, order := range orders {
-		accrual := order.Accrual
-
 		response := OrdersResponse{
 			OrderResponse: OrderResponse{
 				Number:    order.Number,
-				Status:    order.Status,
+				Program:   order.ProgramID,
+				Status:    order.Status.String(),
 				CreatedAt: order.CreatedAt.Format(time.RFC3339),
 			},
+			AccrualStatus: order.AccrualStatus,
+		}
+
+		if order.CheckedAt != nil {
+			response.CheckedAt = order.CheckedAt.Format(time.RFC3339)
 		}
 
-		if order.Status == entity.StatusProcessed.String() {
-			decimalAccrual := decimal.NewFromInt(accrual).Div(divValue)
+		if order.Status == entity.StatusProcessed {
+			decimalAccrual := entity.ToPoints(order.Accrual, order.Precision)
 			response.Accrual = &decimalAccrual
 		}
 
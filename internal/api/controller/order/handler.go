@@ -10,7 +10,7 @@ import (
 
 type OrderService interface {
 	AddOrder(ctx context.Context, orderInfo *entity.OrderInfo) (*entity.Order, error)
-	GetOrders(ctx context.Context, userID string) ([]entity.Order, error)
+	GetOrders(ctx context.Context, filter *entity.OrderFilter) ([]entity.Order, bool, error)
 }
 
 type OrderHandler struct {
@@ -42,8 +42,9 @@ func (oh *OrderHandler) Order(w http.ResponseWriter, r *http.Request) {
 	}
 
 	orderInfo := &entity.OrderInfo{
-		UserID: userID,
-		Number: orderNumber,
+		UserID:    userID,
+		Number:    orderNumber,
+		ProgramID: r.URL.Query().Get("program"),
 	}
 
 	order, err := oh.orderService.AddOrder(r.Context(), orderInfo)
@@ -57,6 +58,11 @@ func (oh *OrderHandler) Order(w http.ResponseWriter, r *http.Request) {
 		render.JSON(w, r, render.M{"message": entity.ErrUploadedByAnotherUser.Error()})
 		return
 	}
+	if errors.Is(err, entity.ErrProgramNotFound) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": entity.ErrProgramNotFound.Error()})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
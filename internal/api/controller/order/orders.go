@@ -3,22 +3,33 @@ package controller
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-chi/render"
 
 	"github.com/ivas1ly/gophermart/internal/api/controller"
 	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/cursor"
 )
 
+// Orders returns the user orders from the oldest to the newest by default, the next page
+// is passed in the Link header. Besides the page parameters it supports "status" with
+// comma-separated order statuses.
 func (oh *OrderHandler) Orders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	token, _, _ := jwtauth.FromContext(r.Context())
 
-	userID := token.Subject()
+	filter, err := toOrderFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidQuery})
+		return
+	}
+	filter.UserID = token.Subject()
 
-	orders, err := oh.orderService.GetOrders(r.Context(), userID)
+	orders, hasMore, err := oh.orderService.GetOrders(r.Context(), filter)
 	if errors.Is(err, entity.ErrNoOrdersFound) {
 		w.WriteHeader(http.StatusNoContent)
 		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
@@ -30,8 +41,38 @@ func (oh *OrderHandler) Orders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if hasMore {
+		last := orders[len(orders)-1]
+		controller.SetNextLink(w, r, cursor.Cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
 	response := ToOrdersResponse(orders)
 
 	w.WriteHeader(http.StatusOK)
 	render.JSON(w, r, response)
 }
+
+func toOrderFilter(r *http.Request) (*entity.OrderFilter, error) {
+	query := r.URL.Query()
+
+	page, err := controller.ParsePage(query, controller.DefaultEntityLimit, false)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &entity.OrderFilter{
+		PageFilter: page,
+	}
+
+	if value := query.Get("status"); value != "" {
+		for _, statusValue := range strings.Split(value, ",") {
+			status := entity.ParseStatus(strings.TrimSpace(statusValue))
+			if status == entity.StatusUnknown {
+				return nil, controller.ErrInvalidQuery
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	return filter, nil
+}
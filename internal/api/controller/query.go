@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/cursor"
+)
+
+const (
+	DefaultPageLimit   = 50
+	DefaultEntityLimit = 100
+	MaxPageLimit       = 1000
+
+	dateLayout = "2006-01-02"
+)
+
+var ErrInvalidQuery = errors.New(MsgInvalidQuery)
+
+// ParsePage returns the page parameters "limit", "cursor", "sort" with "asc" or "desc" value
+// and the "from" and "to" range. Unset parameters are replaced by the defaults.
+func ParsePage(query url.Values, defaultLimit int, defaultDescending bool) (entity.PageFilter, error) {
+	page := entity.PageFilter{
+		Descending: defaultDescending,
+	}
+
+	var err error
+
+	page.Limit, err = ParseLimit(query, defaultLimit)
+	if err != nil {
+		return entity.PageFilter{}, err
+	}
+
+	page.From, page.To, err = ParseTimeRange(query)
+	if err != nil {
+		return entity.PageFilter{}, err
+	}
+
+	switch query.Get("sort") {
+	case "":
+	case "asc":
+		page.Descending = false
+	case "desc":
+		page.Descending = true
+	default:
+		return entity.PageFilter{}, ErrInvalidQuery
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		after, err := cursor.Decode(value)
+		if err != nil {
+			return entity.PageFilter{}, ErrInvalidQuery
+		}
+		page.AfterTime = after.Time
+		page.AfterID = after.ID
+	}
+
+	return page, nil
+}
+
+// SetNextLink adds the Link header with the URL of the next page, it is the request URL
+// with the cursor replaced.
+func SetNextLink(w http.ResponseWriter, r *http.Request, next cursor.Cursor) {
+	query := r.URL.Query()
+	query.Set("cursor", next.Encode())
+
+	link := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", link.String()))
+}
+
+// ParseLimit returns the "limit" query parameter or defaultLimit if it is not set.
+func ParseLimit(query url.Values, defaultLimit int) (int, error) {
+	value := query.Get("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > MaxPageLimit {
+		return 0, ErrInvalidQuery
+	}
+
+	return limit, nil
+}
+
+// ParseTimeRange returns the "from" and "to" query parameters in RFC 3339 or "2006-01-02" format.
+// A date in "to" includes the whole day. Zero time is returned for unset parameters.
+func ParseTimeRange(query url.Values) (from, to time.Time, err error) {
+	if value := query.Get("from"); value != "" {
+		from, err = parseTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidQuery
+		}
+	}
+
+	if value := query.Get("to"); value != "" {
+		to, err = parseTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidQuery
+		}
+		if len(value) == len(dateLayout) {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, ErrInvalidQuery
+	}
+
+	return from, to, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if len(value) == len(dateLayout) {
+		return time.Parse(dateLayout, value)
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
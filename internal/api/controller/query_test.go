@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/pkg/cursor"
+)
+
+func TestParseLimit(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		limit, err := ParseLimit(url.Values{}, DefaultPageLimit)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultPageLimit, limit)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		limit, err := ParseLimit(url.Values{"limit": {"10"}}, DefaultPageLimit)
+		require.NoError(t, err)
+		assert.Equal(t, 10, limit)
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		_, err := ParseLimit(url.Values{"limit": {"0"}}, DefaultPageLimit)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+
+		_, err = ParseLimit(url.Values{"limit": {"1001"}}, DefaultPageLimit)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+
+		_, err = ParseLimit(url.Values{"limit": {"ten"}}, DefaultPageLimit)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+}
+
+func TestParseTimeRange(t *testing.T) {
+	t.Run("dates include the last day", func(t *testing.T) {
+		from, to, err := ParseTimeRange(url.Values{"from": {"2024-02-01"}, "to": {"2024-02-10"}})
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), from)
+		assert.Equal(t, time.Date(2024, time.February, 11, 0, 0, 0, 0, time.UTC), to)
+	})
+
+	t.Run("rfc 3339", func(t *testing.T) {
+		from, to, err := ParseTimeRange(url.Values{"from": {"2024-02-01T10:00:00+03:00"}})
+		require.NoError(t, err)
+		assert.True(t, from.Equal(time.Date(2024, time.February, 1, 7, 0, 0, 0, time.UTC)))
+		assert.True(t, to.IsZero())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, _, err := ParseTimeRange(url.Values{"from": {"yesterday"}})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+
+		_, _, err = ParseTimeRange(url.Values{"from": {"2024-02-10"}, "to": {"2024-02-01"}})
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+}
+
+func TestParsePage(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		page, err := ParsePage(url.Values{}, DefaultEntityLimit, false)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultEntityLimit, page.Limit)
+		assert.False(t, page.Descending)
+		assert.Empty(t, page.AfterID)
+	})
+
+	t.Run("sort and cursor", func(t *testing.T) {
+		after := cursor.Cursor{
+			Time: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+			ID:   "018d9a5e-4f7a-7c3e-9b1a-2f3c4d5e6f70",
+		}
+
+		page, err := ParsePage(url.Values{"sort": {"desc"}, "cursor": {after.Encode()}}, DefaultEntityLimit, false)
+		require.NoError(t, err)
+		assert.True(t, page.Descending)
+		assert.Equal(t, after.ID, page.AfterID)
+		assert.True(t, after.Time.Equal(page.AfterTime))
+	})
+
+	t.Run("invalid sort or cursor", func(t *testing.T) {
+		_, err := ParsePage(url.Values{"sort": {"random"}}, DefaultEntityLimit, false)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+
+		_, err = ParsePage(url.Values{"cursor": {"???"}}, DefaultEntityLimit, false)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+
+		notUUID := cursor.Cursor{Time: time.Now(), ID: "id"}
+		_, err = ParsePage(url.Values{"cursor": {notUUID.Encode()}}, DefaultEntityLimit, false)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+}
+
+func TestSetNextLink(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/user/orders?limit=10&status=NEW", nil)
+	w := httptest.NewRecorder()
+
+	next := cursor.Cursor{Time: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), ID: "id"}
+	SetNextLink(w, r, next)
+
+	assert.Equal(t, `</api/user/orders?cursor=`+next.Encode()+`&limit=10&status=NEW>; rel="next"`,
+		w.Header().Get("Link"))
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+// Allowlist returns the usernames that can transfer points to the current user.
+func (th *TransferHandler) Allowlist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _, _ := jwtauth.FromContext(r.Context())
+
+	usernames, err := th.transferService.GetAllowedSenders(r.Context(), token.Subject())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, AllowlistResponse{Usernames: usernames})
+}
+
+// AllowSender lets the user from the URL transfer points to the current user.
+func (th *TransferHandler) AllowSender(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _, _ := jwtauth.FromContext(r.Context())
+
+	err := th.transferService.AllowSender(r.Context(), token.Subject(), chi.URLParam(r, "username"))
+	if errors.Is(err, entity.ErrAllowedUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, render.M{"message": entity.ErrAllowedUserNotFound.Error()})
+		return
+	}
+	if errors.Is(err, entity.ErrTransferToSelf) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": entity.ErrTransferToSelf.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DisallowSender stops transfers from the user in the URL to the current user.
+func (th *TransferHandler) DisallowSender(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _, _ := jwtauth.FromContext(r.Context())
+
+	err := th.transferService.DisallowSender(r.Context(), token.Subject(), chi.URLParam(r, "username"))
+	if errors.Is(err, entity.ErrAllowedUserNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, render.M{"message": entity.ErrAllowedUserNotFound.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type TransferRequest struct {
+	To      string          `json:"to" validate:"required,lte=255"`
+	Program string          `json:"program,omitempty" validate:"omitempty,lte=64"`
+	Sum     decimal.Decimal `json:"sum" validate:"required"`
+}
+
+type TransferResponse struct {
+	ProcessedAt time.Time       `json:"processed_at"`
+	ID          string          `json:"id"`
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	Program     string          `json:"program"`
+	Sum         decimal.Decimal `json:"sum"`
+}
+
+func ToTransferResponse(transfer *entity.Transfer) *TransferResponse {
+	decimal.MarshalJSONWithoutQuotes = true
+
+	return &TransferResponse{
+		ProcessedAt: transfer.CreatedAt,
+		ID:          transfer.ID,
+		From:        transfer.Sender,
+		To:          transfer.Recipient,
+		Program:     transfer.ProgramID,
+		Sum:         entity.ToPoints(transfer.Amount, transfer.Precision),
+	}
+}
+
+type AllowlistResponse struct {
+	Usernames []string `json:"usernames"`
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type TransferService interface {
+	Transfer(ctx context.Context, transferInfo *entity.TransferInfo) (*entity.Transfer, bool, error)
+	AllowSender(ctx context.Context, userID, username string) error
+	DisallowSender(ctx context.Context, userID, username string) error
+	GetAllowedSenders(ctx context.Context, userID string) ([]string, error)
+}
+
+type ProgramService interface {
+	GetProgram(ctx context.Context, programID string) (*entity.Program, error)
+}
+
+type TransferHandler struct {
+	transferService TransferService
+	programService  ProgramService
+	log             *zap.Logger
+	validate        *validator.Validate
+}
+
+func NewTransferHandler(transferService TransferService, programService ProgramService,
+	validate *validator.Validate) *TransferHandler {
+	return &TransferHandler{
+		transferService: transferService,
+		programService:  programService,
+		log:             zap.L().With(zap.String("handler", "transfer")),
+		validate:        validate,
+	}
+}
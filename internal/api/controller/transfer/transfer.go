@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/controller"
+	"github.com/ivas1ly/gophermart/internal/api/middleware/idempotency"
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// Transfer moves points to another user who allowed transfers from the current user. Every
+// transfer requires the Idempotency-Key header, a retry with the same key returns the original
+// transfer without moving the points again.
+func (th *TransferHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, _, _ := jwtauth.FromContext(r.Context())
+
+	userID := token.Subject()
+
+	key := strings.TrimSpace(r.Header.Get(idempotency.Header))
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{
+			"message": fmt.Sprintf("the %s header is required for transfers", idempotency.Header),
+		})
+		return
+	}
+
+	var tr TransferRequest
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&tr)
+	if errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgEmptyBody})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgCantParseBody})
+		return
+	}
+
+	tr.To = strings.TrimSpace(tr.To)
+
+	err = th.validate.Struct(tr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": controller.MsgInvalidRequest})
+		return
+	}
+
+	program, err := th.programService.GetProgram(r.Context(), tr.Program)
+	if errors.Is(err, entity.ErrProgramNotFound) {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": entity.ErrProgramNotFound.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	intSum := program.FromPoints(tr.Sum)
+	if intSum < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": "the amount to be transferred is less than the minimum amount"})
+		return
+	}
+
+	transfer, replayed, err := th.transferService.Transfer(r.Context(), &entity.TransferInfo{
+		SenderID:       userID,
+		Recipient:      tr.To,
+		ProgramID:      program.ID,
+		IdempotencyKey: key,
+		Sum:            intSum,
+	})
+	var violation *entity.RuleViolation
+	switch {
+	case errors.As(err, &violation):
+		w.WriteHeader(http.StatusForbidden)
+		render.JSON(w, r, render.M{
+			"message": violation.Message,
+			"rule":    violation.Rule,
+			"limit":   violation.Limit,
+		})
+		return
+	case errors.Is(err, entity.ErrRecipientNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, render.M{"message": entity.ErrRecipientNotFound.Error()})
+		return
+	case errors.Is(err, entity.ErrTransferToSelf):
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, render.M{"message": entity.ErrTransferToSelf.Error()})
+		return
+	case errors.Is(err, entity.ErrTransferNotAllowed):
+		w.WriteHeader(http.StatusForbidden)
+		render.JSON(w, r, render.M{"message": entity.ErrTransferNotAllowed.Error()})
+		return
+	case errors.Is(err, entity.ErrTransferKeyReused):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		render.JSON(w, r, render.M{"message": entity.ErrTransferKeyReused.Error()})
+		return
+	case errors.Is(err, entity.ErrNotEnoughPointsToWithdraw):
+		w.WriteHeader(http.StatusPaymentRequired)
+		render.JSON(w, r, render.M{"message": "not enough points to transfer"})
+		return
+	case err != nil:
+		th.log.Error("can't transfer points", zap.String("user", userID), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, render.M{"message": controller.MsgInternalServerError})
+		return
+	}
+
+	if replayed {
+		w.Header().Set(idempotency.ReplayHeader, "true")
+	} else {
+		th.log.Info("points transferred", zap.String("transfer", transfer.ID), zap.String("from", transfer.SenderID),
+			zap.String("to", transfer.RecipientID), zap.String("program", transfer.ProgramID),
+			zap.Int64("amount", transfer.Amount), zap.String("key", transfer.IdempotencyKey))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, ToTransferResponse(transfer))
+}
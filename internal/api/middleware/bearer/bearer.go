@@ -0,0 +1,58 @@
+package bearer
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"go.uber.org/zap"
+)
+
+type Verifier interface {
+	Verify(token string) (jwt.Token, error)
+}
+
+// New allows only requests with a valid access token in the "Authorization: Bearer <token>" header
+// or the "jwt" cookie. The token is stored in the request context, so handlers get it with
+// jwtauth.FromContext.
+func New(verifier Verifier, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		l := log.With(zap.String("middleware", "bearer"))
+
+		l.Info("added bearer middleware")
+
+		bearerFn := func(w http.ResponseWriter, r *http.Request) {
+			tokenString := jwtauth.TokenFromHeader(r)
+			if tokenString == "" {
+				tokenString = jwtauth.TokenFromCookie(r)
+			}
+			if tokenString == "" {
+				writeMessage(w, r, http.StatusUnauthorized, jwtauth.ErrNoTokenFound.Error())
+				return
+			}
+
+			token, err := verifier.Verify(tokenString)
+			if err != nil {
+				reason := jwtauth.ErrorReason(err)
+				if !errors.Is(reason, jwtauth.ErrExpired) {
+					l.Debug("invalid token", zap.String("remote", r.RemoteAddr), zap.Error(err))
+				}
+				writeMessage(w, r, http.StatusUnauthorized, reason.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, nil)))
+		}
+
+		return http.HandlerFunc(bearerFn)
+	}
+}
+
+func writeMessage(w http.ResponseWriter, r *http.Request, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	render.JSON(w, r, render.M{"message": message})
+}
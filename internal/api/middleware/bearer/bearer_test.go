@@ -0,0 +1,106 @@
+package bearer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+	"github.com/ivas1ly/gophermart/pkg/jwt"
+)
+
+const (
+	defaultLogLevel          = "info"
+	defaultTestClientTimeout = 3 * time.Second
+)
+
+func TestBearerMiddleware(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+
+	key, err := jwt.GenerateKey()
+	require.NoError(t, err)
+	keys, err := jwt.NewKeySet(key)
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(New(keys, log))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		token, _, _ := jwtauth.FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(token.Subject()))
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("valid token", func(t *testing.T) {
+		token, err := keys.NewToken("user", "session", jwt.DefaultTTL)
+		require.NoError(t, err)
+
+		resp, body := testRequest(t, ts, "Bearer "+token)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "user", body)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		token, err := keys.NewToken("user", "session", -time.Minute)
+		require.NoError(t, err)
+
+		resp, body := testRequest(t, ts, "Bearer "+token)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `{"message":"token is expired"}`, body)
+	})
+
+	t.Run("token of another key", func(t *testing.T) {
+		anotherKey, err := jwt.GenerateKey()
+		require.NoError(t, err)
+		anotherKeys, err := jwt.NewKeySet(anotherKey)
+		require.NoError(t, err)
+
+		token, err := anotherKeys.NewToken("user", "session", jwt.DefaultTTL)
+		require.NoError(t, err)
+
+		resp, body := testRequest(t, ts, "Bearer "+token)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `{"message":"token is unauthorized"}`, body)
+	})
+
+	t.Run("without token", func(t *testing.T) {
+		resp, body := testRequest(t, ts, "")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `{"message":"no token found"}`, body)
+	})
+}
+
+func testRequest(t *testing.T, ts *httptest.Server, authorization string) (*http.Response, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTestClientTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, strings.TrimSpace(string(respBody))
+}
@@ -0,0 +1,206 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+const (
+	Header       = "Idempotency-Key"
+	ReplayHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+
+	// MaxBodySize is the largest accepted body, it is read before the request is handled.
+	MaxBodySize = 64 * 1024
+
+	// Lease is how long a reserved key is locked for the first request. A retry of the same
+	// request takes the key over after it, the first request may have never finished.
+	Lease = 1 * time.Minute
+)
+
+type Store interface {
+	Reserve(ctx context.Context, record *entity.IdempotencyRecord) (bool, error)
+	Get(ctx context.Context, userID, key string) (*entity.IdempotencyRecord, error)
+	Save(ctx context.Context, record *entity.IdempotencyRecord) error
+	Delete(ctx context.Context, record *entity.IdempotencyRecord) error
+}
+
+// New replays the stored response of a request with the same "Idempotency-Key" header of the user
+// for the ttl. Reusing the key for another request is rejected with 422, a retry while the first
+// request is still processed gets 409 until the Lease passes. Server errors and panics are not
+// stored, so such requests can be retried. A request only saves or releases the key while it owns
+// the reservation. Bodies over MaxBodySize are rejected with 413. It must be used after the JWT
+// authenticator.
+func New(store Store, ttl time.Duration, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		l := log.With(zap.String("middleware", "idempotency"))
+
+		l.Info("added idempotency middleware")
+
+		idempotencyFn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxKeyLength {
+				writeMessage(w, r, http.StatusBadRequest, "idempotency key is too long")
+				return
+			}
+
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil || token == nil {
+				writeMessage(w, r, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					writeMessage(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+					return
+				}
+
+				writeMessage(w, r, http.StatusBadRequest, "can't read request body")
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			reservationID, err := uuid.NewV7()
+			if err != nil {
+				l.Error("can't generate reservation id", zap.Error(err))
+				writeMessage(w, r, http.StatusInternalServerError, "internal server error")
+				return
+			}
+
+			record := &entity.IdempotencyRecord{
+				ExpiresAt:     time.Now().Add(ttl),
+				LockedUntil:   time.Now().Add(Lease),
+				ReservationID: reservationID.String(),
+				UserID:        token.Subject(),
+				Key:           key,
+				RequestHash:   requestHash(r, body),
+			}
+
+			reserved, err := store.Reserve(r.Context(), record)
+			if err != nil {
+				l.Error("can't reserve idempotency key", zap.Error(err))
+				writeMessage(w, r, http.StatusInternalServerError, "internal server error")
+				return
+			}
+
+			if !reserved {
+				replay(w, r, store, record, l)
+				return
+			}
+
+			// the request context may be already canceled by the client
+			ctx := context.WithoutCancel(r.Context())
+
+			defer func() {
+				if rec := recover(); rec != nil {
+					release(ctx, store, record, l)
+					panic(rec)
+				}
+			}()
+
+			buf := &bytes.Buffer{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(buf)
+
+			next.ServeHTTP(ww, r)
+
+			if ww.Status() >= http.StatusInternalServerError {
+				release(ctx, store, record, l)
+				return
+			}
+
+			record.StatusCode = ww.Status()
+			record.ContentType = ww.Header().Get("Content-Type")
+			record.Body = buf.Bytes()
+
+			err = store.Save(ctx, record)
+			if errors.Is(err, entity.ErrIdempotencyLeaseLost) {
+				l.Warn("idempotency key was taken over, response is not saved", zap.String("key", record.Key))
+				return
+			}
+			if err != nil {
+				l.Error("can't save idempotent response", zap.Error(err))
+			}
+		}
+
+		return http.HandlerFunc(idempotencyFn)
+	}
+}
+
+// release deletes the reservation of the request, a key taken over by a retry is kept.
+func release(ctx context.Context, store Store, record *entity.IdempotencyRecord, l *zap.Logger) {
+	err := store.Delete(ctx, record)
+	if errors.Is(err, entity.ErrIdempotencyLeaseLost) {
+		l.Warn("idempotency key was taken over, it is not released", zap.String("key", record.Key))
+		return
+	}
+	if err != nil {
+		l.Error("can't release idempotency key", zap.Error(err))
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, store Store, record *entity.IdempotencyRecord, l *zap.Logger) {
+	stored, err := store.Get(r.Context(), record.UserID, record.Key)
+	if err != nil {
+		l.Error("can't get idempotent response", zap.Error(err))
+		writeMessage(w, r, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	switch {
+	case stored == nil:
+		writeMessage(w, r, http.StatusConflict, "request with this idempotency key is in progress")
+	case stored.RequestHash != record.RequestHash:
+		writeMessage(w, r, http.StatusUnprocessableEntity, "idempotency key is already used for another request")
+	case stored.StatusCode == 0:
+		writeMessage(w, r, http.StatusConflict, "request with this idempotency key is in progress")
+	default:
+		l.Info("replay stored response", zap.String("key", record.Key), zap.Int("status", stored.StatusCode))
+
+		if stored.ContentType != "" {
+			w.Header().Set("Content-Type", stored.ContentType)
+		}
+		w.Header().Set(ReplayHeader, "true")
+		w.WriteHeader(stored.StatusCode)
+		_, _ = w.Write(stored.Body)
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func writeMessage(w http.ResponseWriter, r *http.Request, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	render.JSON(w, r, render.M{"message": message})
+}
@@ -0,0 +1,278 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/middleware/bearer"
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+	"github.com/ivas1ly/gophermart/pkg/jwt"
+)
+
+const (
+	defaultLogLevel          = "info"
+	defaultTestClientTimeout = 3 * time.Second
+)
+
+type memoryStore struct {
+	records map[string]entity.IdempotencyRecord
+	mu      sync.Mutex
+}
+
+func (s *memoryStore) Reserve(_ context.Context, record *entity.IdempotencyRecord) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := record.UserID + "/" + record.Key
+	if stored, ok := s.records[id]; ok && stored.ExpiresAt.After(time.Now()) {
+		abandoned := stored.StatusCode == 0 && !stored.LockedUntil.After(time.Now()) &&
+			stored.RequestHash == record.RequestHash
+		if !abandoned {
+			return false, nil
+		}
+	}
+	s.records[id] = *record
+
+	return true, nil
+}
+
+func (s *memoryStore) Get(_ context.Context, userID, key string) (*entity.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.records[userID+"/"+key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &stored, nil
+}
+
+func (s *memoryStore) Save(_ context.Context, record *entity.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := record.UserID + "/" + record.Key
+	if !s.owns(id, record) {
+		return entity.ErrIdempotencyLeaseLost
+	}
+	s.records[id] = *record
+
+	return nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, record *entity.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := record.UserID + "/" + record.Key
+	if !s.owns(id, record) {
+		return entity.ErrIdempotencyLeaseLost
+	}
+	delete(s.records, id)
+
+	return nil
+}
+
+func (s *memoryStore) owns(id string, record *entity.IdempotencyRecord) bool {
+	stored, ok := s.records[id]
+
+	return ok && stored.StatusCode == 0 && stored.ReservationID == record.ReservationID
+}
+
+func (s *memoryStore) put(record entity.IdempotencyRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[record.UserID+"/"+record.Key] = record
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+	key, err := jwt.GenerateKey()
+	require.NoError(t, err)
+	keys, err := jwt.NewKeySet(key)
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+
+	store := &memoryStore{records: make(map[string]entity.IdempotencyRecord)}
+
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Use(bearer.New(keys, log))
+	r.Use(New(store, time.Hour, log))
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "panic" {
+			panic("handler failed")
+		}
+		if string(body) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(string(body) + " " + string(rune('0'+n))))
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	token, err := keys.NewToken("user", "session", jwt.DefaultTTL)
+	require.NoError(t, err)
+	anotherToken, err := keys.NewToken("another user", "another session", jwt.DefaultTTL)
+	require.NoError(t, err)
+
+	t.Run("replays stored response", func(t *testing.T) {
+		resp, body := testRequest(t, ts, token, "key-1", "order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "order 1", body)
+
+		resp, body = testRequest(t, ts, token, "key-1", "order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "order 1", body)
+		assert.Equal(t, "true", resp.Header.Get(ReplayHeader))
+		assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("rejects another payload", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, token, "key-1", "another order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("keys are per user", func(t *testing.T) {
+		resp, body := testRequest(t, ts, anotherToken, "key-1", "order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "order 2", body)
+	})
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, token, "key-2", "fail")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+		resp, _ = testRequest(t, ts, token, "key-2", "fail")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, int32(4), calls.Load())
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		resp, body := testRequest(t, ts, token, "", "order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "order 5", body)
+	})
+
+	t.Run("panic releases the key", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, token, "key-3", "panic")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+		stored, err := store.Get(context.Background(), "user", "key-3")
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("abandoned key is taken over after the lease", func(t *testing.T) {
+		record := entity.IdempotencyRecord{
+			ExpiresAt:   time.Now().Add(time.Hour),
+			LockedUntil: time.Now().Add(time.Minute),
+			UserID:      "user",
+			Key:         "key-4",
+			RequestHash: requestHash(httptest.NewRequest(http.MethodPost, "/", nil), []byte("order")),
+		}
+		store.put(record)
+
+		resp, _ := testRequest(t, ts, token, "key-4", "order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, "key is locked during the lease")
+
+		record.LockedUntil = time.Now().Add(-time.Second)
+		store.put(record)
+
+		resp, _ = testRequest(t, ts, token, "key-4", "another order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "only the same request takes the key over")
+
+		resp, body := testRequest(t, ts, token, "key-4", "order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "order 7", body)
+	})
+
+	t.Run("request doesn't save the key taken over by a retry", func(t *testing.T) {
+		first := &entity.IdempotencyRecord{
+			ExpiresAt:     time.Now().Add(time.Hour),
+			LockedUntil:   time.Now().Add(-time.Second),
+			ReservationID: "first",
+			UserID:        "user",
+			Key:           "key-5",
+			RequestHash:   requestHash(httptest.NewRequest(http.MethodPost, "/", nil), []byte("order")),
+		}
+		store.put(*first)
+
+		resp, body := testRequest(t, ts, token, "key-5", "order")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		first.StatusCode = http.StatusAccepted
+		first.Body = []byte("order late")
+		assert.ErrorIs(t, store.Save(context.Background(), first), entity.ErrIdempotencyLeaseLost)
+		assert.ErrorIs(t, store.Delete(context.Background(), first), entity.ErrIdempotencyLeaseLost)
+
+		stored, err := store.Get(context.Background(), "user", "key-5")
+		require.NoError(t, err)
+		assert.Equal(t, body, string(stored.Body))
+	})
+
+	t.Run("body is too large", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, token, "key-6", strings.Repeat("a", MaxBodySize+1))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		assert.Equal(t, int32(8), calls.Load())
+	})
+}
+
+func testRequest(t *testing.T, ts *httptest.Server, token, key, body string) (*http.Response, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTestClientTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, strings.TrimSpace(string(respBody))
+}
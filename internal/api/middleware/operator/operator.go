@@ -0,0 +1,39 @@
+package operator
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+const bearerPrefix = "Bearer "
+
+// New allows only requests with the "Authorization: Bearer <token>" header of the operator token.
+func New(token string, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		l := log.With(zap.String("middleware", "operator"))
+
+		l.Info("added operator middleware")
+
+		operatorFn := func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			got := strings.TrimPrefix(header, bearerPrefix)
+
+			if !strings.HasPrefix(header, bearerPrefix) || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				l.Info("invalid operator token", zap.String("remote", r.RemoteAddr))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+
+				render.JSON(w, r, render.M{"message": "invalid operator token"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(operatorFn)
+	}
+}
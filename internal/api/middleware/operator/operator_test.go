@@ -0,0 +1,78 @@
+package operator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+)
+
+const (
+	defaultLogLevel          = "info"
+	defaultTestClientTimeout = 3 * time.Second
+)
+
+func TestOperatorMiddleware(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+
+	r := chi.NewRouter()
+	r.Use(New("operator-token", log))
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	t.Run("valid token", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, "Bearer operator-token")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		resp, respBody := testRequest(t, ts, "Bearer another-token")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `{"message":"invalid operator token"}`, strings.TrimSpace(respBody))
+	})
+
+	t.Run("without bearer prefix", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, "operator-token")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("without token", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, "")
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func testRequest(t *testing.T, ts *httptest.Server, authorization string) (*http.Response, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTestClientTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(respBody)
+}
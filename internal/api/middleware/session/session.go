@@ -0,0 +1,62 @@
+package session
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/pkg/jwt"
+)
+
+type Checker interface {
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// New rejects access tokens of revoked or expired sessions, so a token stops working on logout
+// before it expires. It must be used after the JWT authenticator.
+func New(checker Checker, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		l := log.With(zap.String("middleware", "session"))
+
+		l.Info("added session middleware")
+
+		sessionFn := func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				writeMessage(w, r, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			sessionID, _ := claims[jwt.SessionClaim].(string)
+			if sessionID == "" {
+				writeMessage(w, r, http.StatusUnauthorized, "token without session")
+				return
+			}
+
+			active, err := checker.IsSessionActive(r.Context(), sessionID)
+			if err != nil {
+				l.Error("can't check session", zap.String("session_id", sessionID), zap.Error(err))
+				writeMessage(w, r, http.StatusInternalServerError, "internal server error")
+				return
+			}
+			if !active {
+				writeMessage(w, r, http.StatusUnauthorized, "session is revoked")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(sessionFn)
+	}
+}
+
+func writeMessage(w http.ResponseWriter, r *http.Request, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	render.JSON(w, r, render.M{"message": message})
+}
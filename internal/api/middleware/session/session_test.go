@@ -0,0 +1,99 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/api/middleware/bearer"
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+	"github.com/ivas1ly/gophermart/pkg/jwt"
+)
+
+const (
+	defaultLogLevel          = "info"
+	defaultTestClientTimeout = 3 * time.Second
+)
+
+type sessionChecker map[string]bool
+
+func (c sessionChecker) IsSessionActive(_ context.Context, sessionID string) (bool, error) {
+	if sessionID == "broken" {
+		return false, errors.New("database is down")
+	}
+	return c[sessionID], nil
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+	key, err := jwt.GenerateKey()
+	require.NoError(t, err)
+	keys, err := jwt.NewKeySet(key)
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(bearer.New(keys, log))
+	r.Use(New(sessionChecker{"active": true, "revoked": false}, log))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		session string
+		code    int
+		message string
+	}{
+		{name: "active session", session: "active", code: http.StatusOK},
+		{name: "revoked session", session: "revoked", code: http.StatusUnauthorized,
+			message: `{"message":"session is revoked"}`},
+		{name: "token without session", code: http.StatusUnauthorized,
+			message: `{"message":"token without session"}`},
+		{name: "session check failed", session: "broken", code: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := keys.NewToken("user", tt.session, jwt.DefaultTTL)
+			require.NoError(t, err)
+
+			resp, body := testRequest(t, ts, token)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, body)
+			}
+		})
+	}
+}
+
+func testRequest(t *testing.T, ts *httptest.Server, token string) (*http.Response, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTestClientTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, strings.TrimSpace(string(respBody))
+}
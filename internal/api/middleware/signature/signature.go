@@ -0,0 +1,125 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+const (
+	Header          = "X-Signature"
+	TimestampHeader = "X-Signature-Timestamp"
+	Prefix          = "sha256="
+
+	// MaxBodySize is the largest accepted body, it is read before the signature is checked.
+	MaxBodySize = 64 * 1024
+	// Tolerance is the maximum difference between the signed timestamp and the server time,
+	// older requests are rejected as replayed.
+	Tolerance = 5 * time.Minute
+)
+
+// New checks that the request body is signed with HMAC-SHA256 and the shared secret. The
+// signature of "<timestamp>.<body>" is sent as "X-Signature: sha256=<hex>" and the Unix time
+// in seconds as "X-Signature-Timestamp", it must be within Tolerance of the server time.
+func New(secret []byte, log *zap.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		l := log.With(zap.String("middleware", "signature"))
+
+		l.Info("added signature middleware")
+
+		signatureFn := func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					l.Info("request body is too large")
+					writeMessage(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+					return
+				}
+
+				l.Info("can't read body")
+				writeMessage(w, r, http.StatusBadRequest, "can't read request body")
+				return
+			}
+			r.Body.Close()
+
+			timestamp := r.Header.Get(TimestampHeader)
+			if !Verify(secret, body, timestamp, r.Header.Get(Header)) {
+				l.Info("invalid request signature")
+				writeMessage(w, r, http.StatusUnauthorized, "invalid request signature")
+				return
+			}
+
+			if !fresh(timestamp, time.Now()) {
+				l.Info("stale request signature", zap.String("timestamp", timestamp))
+				writeMessage(w, r, http.StatusUnauthorized, "request signature is expired")
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(signatureFn)
+	}
+}
+
+// Sign returns the timestamp and the signature header values for the body signed at the time.
+func Sign(secret []byte, signedAt time.Time, body []byte) (string, string) {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+
+	return timestamp, Prefix + hex.EncodeToString(mac(secret, timestamp, body))
+}
+
+// Verify compares the signature header value with the expected one in constant time.
+func Verify(secret, body []byte, timestamp, signature string) bool {
+	if timestamp == "" || !strings.HasPrefix(signature, Prefix) {
+		return false
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, Prefix))
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(got, mac(secret, timestamp, body))
+}
+
+func mac(secret []byte, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+
+	return h.Sum(nil)
+}
+
+// fresh reports whether the Unix timestamp is within Tolerance of now.
+func fresh(timestamp string, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	diff := now.Sub(time.Unix(seconds, 0))
+
+	return diff <= Tolerance && diff >= -Tolerance
+}
+
+func writeMessage(w http.ResponseWriter, r *http.Request, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	render.JSON(w, r, render.M{"message": message})
+}
@@ -0,0 +1,115 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+)
+
+const (
+	defaultLogLevel          = "info"
+	defaultTestClientTimeout = 3 * time.Second
+)
+
+func TestSignatureMiddleware(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+	secret := []byte("secret")
+
+	r := chi.NewRouter()
+	r.Use(New(secret, log))
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		_, _ = w.Write(b)
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	testData := `{"order":"2377225624","status":"PROCESSED","accrual":500}`
+
+	t.Run("valid signature", func(t *testing.T) {
+		timestamp, signature := Sign(secret, time.Now(), []byte(testData))
+		resp, respBody := testRequest(t, ts, timestamp, signature, testData)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, testData, respBody)
+	})
+
+	t.Run("signed with another secret", func(t *testing.T) {
+		timestamp, signature := Sign([]byte("another"), time.Now(), []byte(testData))
+		resp, respBody := testRequest(t, ts, timestamp, signature, testData)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `{"message":"invalid request signature"}`, strings.TrimSpace(respBody))
+	})
+
+	t.Run("without signature", func(t *testing.T) {
+		resp, _ := testRequest(t, ts, "", "", testData)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("malformed signature", func(t *testing.T) {
+		timestamp, _ := Sign(secret, time.Now(), []byte(testData))
+		resp, _ := testRequest(t, ts, timestamp, Prefix+"zz", testData)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("timestamp is signed", func(t *testing.T) {
+		_, signature := Sign(secret, time.Now(), []byte(testData))
+		timestamp := strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10)
+		resp, _ := testRequest(t, ts, timestamp, signature, testData)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("replayed request", func(t *testing.T) {
+		timestamp, signature := Sign(secret, time.Now().Add(-Tolerance-time.Minute), []byte(testData))
+		resp, respBody := testRequest(t, ts, timestamp, signature, testData)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `{"message":"request signature is expired"}`, strings.TrimSpace(respBody))
+	})
+
+	t.Run("body is too large", func(t *testing.T) {
+		body := strings.Repeat("a", MaxBodySize+1)
+		timestamp, signature := Sign(secret, time.Now(), []byte(body))
+		resp, _ := testRequest(t, ts, timestamp, signature, body)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+}
+
+func testRequest(t *testing.T, ts *httptest.Server, timestamp, signature, body string) (*http.Response, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTestClientTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set(Header, signature)
+	req.Header.Set(TimestampHeader, timestamp)
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(respBody)
+}
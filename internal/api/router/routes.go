@@ -2,44 +2,98 @@ package router
 
 import (
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
 
+	accrual "github.com/ivas1ly/gophermart/internal/api/controller/accrual"
 	auth "github.com/ivas1ly/gophermart/internal/api/controller/auth"
 	balance "github.com/ivas1ly/gophermart/internal/api/controller/balance"
+	jwks "github.com/ivas1ly/gophermart/internal/api/controller/jwks"
+	operator "github.com/ivas1ly/gophermart/internal/api/controller/operator"
 	order "github.com/ivas1ly/gophermart/internal/api/controller/order"
+	transfer "github.com/ivas1ly/gophermart/internal/api/controller/transfer"
+	"github.com/ivas1ly/gophermart/internal/api/middleware/bearer"
+	"github.com/ivas1ly/gophermart/internal/api/middleware/idempotency"
+	operatorauth "github.com/ivas1ly/gophermart/internal/api/middleware/operator"
+	"github.com/ivas1ly/gophermart/internal/api/middleware/session"
+	"github.com/ivas1ly/gophermart/internal/api/middleware/signature"
 	"github.com/ivas1ly/gophermart/internal/app/provider"
-	"github.com/ivas1ly/gophermart/pkg/jwt"
+	"github.com/ivas1ly/gophermart/internal/config"
 )
 
-func RegisterRoutes(router *chi.Mux, sp *provider.ServiceProvider, validate *validator.Validate) {
+func RegisterRoutes(router *chi.Mux, sp *provider.ServiceProvider, validate *validator.Validate,
+	cfg config.Config) {
 	authHandler := auth.NewAuthHandler(sp.AuthService, validate)
 	orderHandler := order.NewOrderHandler(sp.OrderService)
 	balanceHandler := balance.NewBalanceHandler(sp.BalanceService, validate)
+	transferHandler := transfer.NewTransferHandler(sp.TransferService, sp.BalanceService, validate)
+	jwksHandler := jwks.NewJWKSHandler(sp.Keys)
 
-	tokenAuth := jwtauth.New("HS256", jwt.SigningKey, nil)
+	idempotent := idempotency.New(sp.IdempotencyRepository, cfg.IdempotencyTTL, zap.L())
 
 	zap.L().Info("register routes")
+	// Public keys of access tokens for other services
+	router.Get("/.well-known/jwks.json", jwksHandler.JWKS)
+
 	router.Route("/api/user", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Post("/login", authHandler.Login)
 			r.Post("/register", authHandler.Register)
+			r.Post("/token/refresh", authHandler.Refresh)
+
+			// Password reset, enabled only with the notifier sink
+			if cfg.PasswordResetSink != "" {
+				r.Post("/password/reset-request", authHandler.RequestPasswordReset)
+				r.Post("/password/reset", authHandler.ResetPassword)
+			}
 		})
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
-			r.Use(jwtauth.Verifier(tokenAuth), jwtauth.Authenticator(tokenAuth))
+			r.Use(bearer.New(sp.Keys, zap.L()), session.New(sp.AuthService, zap.L()))
+			r.Post("/logout", authHandler.Logout)
+			r.Post("/logout/all", authHandler.LogoutAll)
+			r.Post("/password", authHandler.ChangePassword)
 			r.Route("/orders", func(r chi.Router) {
-				r.Post("/", orderHandler.Order)
+				r.With(idempotent).Post("/", orderHandler.Order)
 				r.Get("/", orderHandler.Orders)
 			})
 
 			r.Route("/balance", func(r chi.Router) {
 				r.Get("/", balanceHandler.Balance)
-				r.Post("/withdraw", balanceHandler.Withdraw)
+				r.With(idempotent).Post("/withdraw", balanceHandler.Withdraw)
+
+				// Transfers handle the Idempotency-Key header themselves
+				r.Post("/transfer", transferHandler.Transfer)
+				r.Route("/transfer/allowlist", func(r chi.Router) {
+					r.Get("/", transferHandler.Allowlist)
+					r.Put("/{username}", transferHandler.AllowSender)
+					r.Delete("/{username}", transferHandler.DisallowSender)
+				})
 			})
 			r.Get("/withdrawals", balanceHandler.Withdrawals)
+			r.Get("/transactions", balanceHandler.Transactions)
 		})
 	})
+
+	// Accrual system callbacks, enabled only with the shared secret
+	if cfg.CallbackSecret != "" {
+		accrualHandler := accrual.NewAccrualHandler(sp.AccrualService, validate)
+
+		router.Route("/api/internal/accrual", func(r chi.Router) {
+			r.Use(signature.New([]byte(cfg.CallbackSecret), zap.L()))
+			r.Post("/callback", accrualHandler.Callback)
+		})
+	}
+
+	// Operator API, enabled only with the operator token
+	if cfg.OperatorToken != "" {
+		operatorHandler := operator.NewOperatorHandler(sp.BalanceService, validate)
+
+		router.Route("/api/operator", func(r chi.Router) {
+			r.Use(operatorauth.New(string(cfg.OperatorToken), zap.L()))
+			r.Post("/withdrawals/{number}/reverse", operatorHandler.ReverseWithdrawal)
+			r.Post("/programs", operatorHandler.CreateProgram)
+		})
+	}
 }
@@ -22,7 +22,6 @@ import (
 	"github.com/ivas1ly/gophermart/internal/lib/storage/postgres"
 	"github.com/ivas1ly/gophermart/internal/repository"
 	"github.com/ivas1ly/gophermart/internal/worker"
-	"github.com/ivas1ly/gophermart/pkg/jwt"
 )
 
 type App struct {
@@ -30,6 +29,7 @@ type App struct {
 	router *chi.Mux
 	db     *postgres.DB
 	worker *worker.AccrualWorker
+	expiry *worker.ExpiryWorker
 	cfg    config.Config
 }
 
@@ -42,7 +42,6 @@ func NewApp(ctx context.Context, cfg config.Config) (*App, error) {
 		cfg: cfg,
 		log: log,
 	}
-	jwt.SigningKey = cfg.SigningKey
 
 	a.log.Info("init the database pool")
 	db, err := postgres.New(ctx, cfg.DatabaseURI, cfg.DatabaseConnAttempts, cfg.DatabaseConnTimeout)
@@ -62,20 +61,81 @@ func NewApp(ctx context.Context, cfg config.Config) (*App, error) {
 	}
 	a.log.Info("migrations up success")
 
+	reconciled, err := repository.NewLedgerRepository(db).ReconcileBalances(ctx)
+	if err != nil {
+		a.log.Error("can't reconcile user balances with the ledger", zap.Error(err))
+		return nil, err
+	}
+	if reconciled > 0 {
+		a.log.Warn("user balances reconciled with the ledger", zap.Int64("users", reconciled))
+	}
+
 	a.router = router.NewRouter(cfg.HTTP, a.log)
 
 	a.log.Info("init services")
+	keys, err := a.newKeySet()
+	if err != nil {
+		a.log.Error("can't load JWT keys", zap.Error(err))
+		return nil, err
+	}
+
+	passwordPolicy, err := a.newPasswordPolicy()
+	if err != nil {
+		a.log.Error("can't load password policy", zap.Error(err))
+		return nil, err
+	}
+
+	resetNotifier, err := a.newPasswordResetNotifier()
+	if err != nil {
+		a.log.Error("can't create password reset notifier", zap.Error(err))
+		return nil, err
+	}
+
 	// "provider" name to avoid import cycle
-	serviceProvider := provider.NewServiceProvider(db)
+	serviceProvider := provider.NewServiceProvider(db, cfg, keys, passwordPolicy, resetNotifier)
 	serviceProvider.RegisterServices()
 
 	a.log.Info("init api routes")
 	validate := validator.New(validator.WithRequiredStructEnabled())
-	router.RegisterRoutes(a.router, serviceProvider, validate)
+	router.RegisterRoutes(a.router, serviceProvider, validate, cfg)
+
+	httpClient, err := client.NewHTTPClient(client.TransportConfig{
+		CAFile:          cfg.AccrualCAFile,
+		CertFile:        cfg.AccrualCertFile,
+		KeyFile:         cfg.AccrualKeyFile,
+		Timeout:         cfg.ClientTimeout,
+		MaxIdleConns:    cfg.AccrualMaxIdleConns,
+		MaxConnsPerHost: cfg.AccrualMaxConnsPerHost,
+	})
+	if err != nil {
+		a.log.Error("can't create accrual system http client", zap.Error(err))
+		return nil, err
+	}
 
-	accrualClient := client.NewAccrualClient(cfg.AccrualSystemAddress, cfg.ClientTimeout, a.log)
-	a.worker = worker.NewAccrualWorker(accrualClient, repository.NewAccrualWorkerRepository(a.db),
-		cfg.WorkerPollInterval, a.log)
+	headers := http.Header(cfg.AccrualHeaders).Clone()
+	if cfg.AccrualToken != "" {
+		headers.Set("Authorization", "Bearer "+string(cfg.AccrualToken))
+	}
+
+	accrualClient := client.NewAccrualClient(cfg.AccrualSystemAddress, httpClient, headers, client.NewGate(), a.log)
+	a.worker = worker.NewAccrualWorker(accrualClient, a.newAccrualBreaker(), repository.NewAccrualWorkerRepository(a.db),
+		worker.Config{
+			ID:           cfg.WorkerID,
+			PollInterval: cfg.WorkerPollInterval,
+			Lease:        cfg.WorkerLease,
+			RetryBase:    cfg.WorkerRetryBase,
+			RetryMax:     cfg.WorkerRetryMax,
+			MaxAge:       cfg.WorkerMaxAge,
+			Concurrency:  cfg.WorkerConcurrency,
+			BatchSize:    cfg.WorkerBatchSize,
+			MaxAttempts:  cfg.WorkerMaxAttempts,
+		}, a.log)
+
+	a.expiry = worker.NewExpiryWorker(repository.NewLedgerRepository(a.db), worker.ExpiryConfig{
+		Policy:    cfg.ExpiryPolicy(),
+		Interval:  cfg.ExpiryInterval,
+		BatchSize: cfg.ExpiryBatchSize,
+	}, a.log)
 
 	return a, nil
 }
@@ -91,7 +151,12 @@ func (a *App) Run(ctx context.Context) error {
 	notifyCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 	defer stop()
 
+	a.startMetrics(notifyCtx)
+	a.startIdempotencyCleanup(notifyCtx)
+	a.startSessionCleanup(notifyCtx)
+
 	go a.worker.Run(ctx)
+	go a.expiry.Run(ctx)
 
 	if err := a.startHTTP(notifyCtx); err != nil {
 		a.log.Error("unexpected server error", zap.Error(err))
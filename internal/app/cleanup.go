@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/repository"
+)
+
+const (
+	idempotencyCleanupInterval = 1 * time.Hour
+	sessionCleanupInterval     = 1 * time.Hour
+)
+
+// startIdempotencyCleanup periodically removes expired idempotency keys, expired keys are
+// already ignored by the middleware, so it only keeps the table small.
+func (a *App) startIdempotencyCleanup(ctx context.Context) {
+	repo := repository.NewIdempotencyRepository(a.db)
+
+	go func() {
+		ticker := time.NewTicker(idempotencyCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := repo.DeleteExpired(ctx, time.Now())
+				if err != nil {
+					a.log.Warn("can't delete expired idempotency keys", zap.Error(err))
+					continue
+				}
+				if deleted > 0 {
+					a.log.Info("expired idempotency keys deleted", zap.Int64("count", deleted))
+				}
+			}
+		}
+	}()
+}
+
+// startSessionCleanup periodically removes expired and revoked sessions with their refresh tokens,
+// such sessions are already rejected, so it only keeps the tables small.
+func (a *App) startSessionCleanup(ctx context.Context) {
+	repo := repository.NewSessionRepository(a.db)
+
+	go func() {
+		ticker := time.NewTicker(sessionCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := repo.DeleteExpired(ctx, time.Now())
+				if err != nil {
+					a.log.Warn("can't delete expired sessions", zap.Error(err))
+					continue
+				}
+				if deleted > 0 {
+					a.log.Info("expired sessions deleted", zap.Int64("count", deleted))
+				}
+			}
+		}
+	}()
+}
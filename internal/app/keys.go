@@ -0,0 +1,47 @@
+package app
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/pkg/jwt"
+)
+
+var errNoKeyFiles = errors.New("no JWT key files, set them or enable the ephemeral key for development")
+
+// newKeySet loads the token keys. Key files are required, all instances must share them. Only
+// with the ephemeral key enabled a key is generated on every start, then access tokens are
+// rejected by other instances and after a restart.
+func (a *App) newKeySet() (*jwt.KeySet, error) {
+	if len(a.cfg.JWTKeyFiles) == 0 {
+		if !a.cfg.JWTEphemeralKey {
+			return nil, errNoKeyFiles
+		}
+
+		a.log.Warn("ephemeral JWT key is generated, access tokens won't survive a restart")
+
+		key, err := jwt.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		return jwt.NewKeySet(key)
+	}
+
+	keys := make([]*jwt.Key, 0, len(a.cfg.JWTKeyFiles))
+
+	for _, path := range a.cfg.JWTKeyFiles {
+		key, err := jwt.LoadKey(path)
+		if err != nil {
+			return nil, err
+		}
+
+		a.log.Info("JWT key loaded", zap.String("kid", key.ID), zap.String("alg", key.Algorithm.String()),
+			zap.Bool("signing", key.Signer != nil))
+
+		keys = append(keys, key)
+	}
+
+	return jwt.NewKeySet(keys...)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/pkg/breaker"
+)
+
+var (
+	accrualBreakerState       = expvar.NewString("accrual_breaker_state")
+	accrualBreakerTransitions = expvar.NewMap("accrual_breaker_transitions")
+)
+
+func (a *App) newAccrualBreaker() *breaker.Breaker {
+	accrualBreakerState.Set(breaker.StateClosed.String())
+
+	return breaker.New(breaker.Settings{
+		FailureThreshold: a.cfg.BreakerFailures,
+		OpenTimeout:      a.cfg.BreakerOpenTimeout,
+		HalfOpenRequests: a.cfg.BreakerProbes,
+		OnStateChange: func(from, to breaker.State) {
+			a.log.Warn("accrual system circuit breaker state changed",
+				zap.String("from", from.String()), zap.String("to", to.String()))
+
+			accrualBreakerState.Set(to.String())
+			accrualBreakerTransitions.Add(to.String(), 1)
+		},
+	})
+}
+
+// startMetrics serves expvar metrics except the command line, it may contain secrets.
+func (a *App) startMetrics(ctx context.Context) {
+	if a.cfg.MetricsAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/vars", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		_, _ = fmt.Fprint(w, "{")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if kv.Key == "cmdline" {
+				return
+			}
+			if !first {
+				_, _ = fmt.Fprint(w, ",")
+			}
+			first = false
+			_, _ = fmt.Fprintf(w, "%q:%s", kv.Key, kv.Value)
+		})
+		_, _ = fmt.Fprint(w, "}")
+	})
+
+	server := &http.Server{
+		Addr:              a.cfg.MetricsAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: a.cfg.ReadHeaderTimeout,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.log.Error("unexpected metrics server error", zap.Error(err))
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			a.log.Error("can't close metrics server", zap.Error(err))
+		}
+	}()
+
+	a.log.Info("metrics server started", zap.String("addr", a.cfg.MetricsAddress))
+}
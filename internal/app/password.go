@@ -0,0 +1,95 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/notifier"
+	"github.com/ivas1ly/gophermart/internal/service"
+	"github.com/ivas1ly/gophermart/pkg/argon2id"
+)
+
+// newPasswordPolicy loads the breached passwords file, one password per line.
+func (a *App) newPasswordPolicy() (entity.PasswordPolicy, error) {
+	policy := entity.PasswordPolicy{
+		MinLength: a.cfg.PasswordMinLength,
+		ResetTTL:  a.cfg.PasswordResetTTL,
+	}
+
+	hashParams, err := a.newPasswordHashParams()
+	if err != nil {
+		return policy, err
+	}
+	policy.HashParams = hashParams
+
+	if a.cfg.BreachedPasswordsFile == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(a.cfg.BreachedPasswordsFile)
+	if err != nil {
+		return policy, fmt.Errorf("can't open breached passwords file: %w", err)
+	}
+	defer file.Close()
+
+	policy.Breached = make(map[string]struct{})
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		password := strings.TrimSpace(scanner.Text())
+		if password != "" {
+			policy.Breached[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return policy, fmt.Errorf("can't read breached passwords file: %w", err)
+	}
+
+	a.log.Info("breached passwords loaded", zap.Int("count", len(policy.Breached)))
+
+	return policy, nil
+}
+
+// newPasswordHashParams returns the configured argon2id params, salt and key lengths are
+// always the default ones.
+func (a *App) newPasswordHashParams() (*argon2id.Params, error) {
+	// Compared as uint64, math.MaxUint32 overflows int on 32-bit platforms.
+	if a.cfg.PasswordHashMemory <= 0 || uint64(a.cfg.PasswordHashMemory) > math.MaxUint32 ||
+		a.cfg.PasswordHashIterations <= 0 || uint64(a.cfg.PasswordHashIterations) > math.MaxUint32 ||
+		a.cfg.PasswordHashParallelism <= 0 || a.cfg.PasswordHashParallelism > math.MaxUint8 {
+		return nil, fmt.Errorf("%w: memory, iterations or parallelism is out of range", argon2id.ErrInvalidParams)
+	}
+
+	params := &argon2id.Params{
+		Memory:      uint32(a.cfg.PasswordHashMemory),
+		Iterations:  uint32(a.cfg.PasswordHashIterations),
+		Parallelism: uint8(a.cfg.PasswordHashParallelism),
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	a.log.Info("password hash params", zap.Uint32("memory", params.Memory),
+		zap.Uint32("iterations", params.Iterations), zap.Uint8("parallelism", params.Parallelism))
+
+	return params, nil
+}
+
+// newPasswordResetNotifier returns nil if password reset is disabled.
+func (a *App) newPasswordResetNotifier() (service.PasswordResetNotifier, error) {
+	if a.cfg.PasswordResetSink == "" {
+		return nil, nil
+	}
+
+	a.log.Warn("password reset tokens are written to the local sink", zap.String("sink", a.cfg.PasswordResetSink))
+
+	return notifier.New(a.cfg.PasswordResetSink, a.log)
+}
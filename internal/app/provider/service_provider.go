@@ -2,27 +2,49 @@ package provider
 
 import (
 	"context"
+	"time"
 
+	"github.com/ivas1ly/gophermart/internal/config"
 	"github.com/ivas1ly/gophermart/internal/entity"
 	"github.com/ivas1ly/gophermart/internal/lib/storage/postgres"
 	"github.com/ivas1ly/gophermart/internal/repository"
 	"github.com/ivas1ly/gophermart/internal/service"
+	"github.com/ivas1ly/gophermart/pkg/jwt"
 )
 
 type AuthService interface {
 	Register(ctx context.Context, username, password string) (*entity.User, error)
-	Login(ctx context.Context, username, password string) (*entity.User, error)
+	Login(ctx context.Context, loginInfo *entity.LoginInfo) (*entity.User, error)
+	NewSession(ctx context.Context, userID string) (*entity.Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (*entity.Tokens, error)
+	Logout(ctx context.Context, userID, sessionID string) error
+	LogoutAll(ctx context.Context, userID string) (int64, error)
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+	ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (*entity.Tokens, error)
+	RequestPasswordReset(ctx context.Context, username, remoteIP string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
 }
 
 type OrderService interface {
 	AddOrder(ctx context.Context, orderInfo *entity.OrderInfo) (*entity.Order, error)
-	GetOrders(ctx context.Context, userID string) ([]entity.Order, error)
+	GetOrders(ctx context.Context, filter *entity.OrderFilter) ([]entity.Order, bool, error)
 }
 
 type BalanceService interface {
 	GetCurrentBalance(ctx context.Context, userID string) (*entity.Balance, error)
 	AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo) error
-	GetWithdrawals(ctx context.Context, userID string) ([]entity.Withdraw, error)
+	GetWithdrawals(ctx context.Context, filter *entity.PageFilter) ([]entity.Withdraw, bool, error)
+	GetTransactions(ctx context.Context, filter *entity.TransactionFilter) ([]entity.Transaction, bool, error)
+	ReverseWithdrawal(ctx context.Context, reversalInfo *entity.ReversalInfo) (*entity.Withdraw, error)
+	GetProgram(ctx context.Context, programID string) (*entity.Program, error)
+	AddProgram(ctx context.Context, program *entity.Program) (*entity.Program, error)
+}
+
+type TransferService interface {
+	Transfer(ctx context.Context, transferInfo *entity.TransferInfo) (*entity.Transfer, bool, error)
+	AllowSender(ctx context.Context, userID, username string) error
+	DisallowSender(ctx context.Context, userID, username string) error
+	GetAllowedSenders(ctx context.Context, userID string) ([]string, error)
 }
 
 type AccrualWorkerService interface {
@@ -30,39 +52,98 @@ type AccrualWorkerService interface {
 	UpdateOrders(ctx context.Context, orders ...entity.Order) error
 }
 
+type AccrualService interface {
+	ApplyCallback(ctx context.Context, accrualInfo *entity.AccrualInfo) error
+}
+
 type AuthRepository interface {
 	AddUser(ctx context.Context, userInfo *entity.UserInfo) (*entity.User, error)
 	FindUser(ctx context.Context, username string) (*entity.User, error)
+	FindUserByID(ctx context.Context, userID string) (*entity.User, error)
+	UpdatePassword(ctx context.Context, userID, hash string) error
+	RehashPassword(ctx context.Context, userID, oldHash, newHash string) error
+	AddPasswordReset(ctx context.Context, reset *entity.PasswordReset) error
+	FindPasswordReset(ctx context.Context, tokenHash string) (*entity.User, error)
+	ResetPassword(ctx context.Context, tokenHash, hash string) error
+}
+
+type SessionRepository interface {
+	AddSession(ctx context.Context, sessionInfo *entity.SessionInfo) (*entity.Session, error)
+	RotateRefreshToken(ctx context.Context, refreshInfo *entity.RefreshInfo) (*entity.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	RevokeUserSessions(ctx context.Context, userID string) (int64, error)
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
 }
 
 type OrderRepository interface {
 	AddOrder(ctx context.Context, orderInfo *entity.OrderInfo) (*entity.Order, error)
-	GetOrders(ctx context.Context, userID string) ([]entity.Order, error)
+	GetOrders(ctx context.Context, filter *entity.OrderFilter) ([]entity.Order, error)
 }
 
 type BalanceRepository interface {
 	GetUserBalance(ctx context.Context, userID string) (*entity.Balance, error)
-	AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo) error
-	GetWithdrawals(ctx context.Context, userID string) ([]entity.Withdraw, error)
+	AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo, check entity.WithdrawalCheck) error
+	GetWithdrawals(ctx context.Context, filter *entity.PageFilter) ([]entity.Withdraw, error)
+	GetTransactions(ctx context.Context, filter *entity.TransactionFilter) ([]entity.Transaction, error)
+	ReverseWithdrawal(ctx context.Context, reversalInfo *entity.ReversalInfo) (*entity.Withdraw, error)
+	GetExpiringPoints(ctx context.Context, userID string, creditedBefore time.Time) (map[string]int64, error)
+	GetPendingAccrual(ctx context.Context, userID string) (map[string]entity.PendingAccrual, error)
+	GetProgram(ctx context.Context, programID string) (*entity.Program, error)
+	AddProgram(ctx context.Context, program *entity.Program) (*entity.Program, error)
+}
+
+type TransferRepository interface {
+	AddTransfer(ctx context.Context, transferInfo *entity.TransferInfo, check entity.TransferCheck) (*entity.Transfer,
+		bool, error)
+	AllowSender(ctx context.Context, userID, username string) error
+	DisallowSender(ctx context.Context, userID, username string) error
+	GetAllowedSenders(ctx context.Context, userID string) ([]string, error)
+}
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, record *entity.IdempotencyRecord) (bool, error)
+	Get(ctx context.Context, userID, key string) (*entity.IdempotencyRecord, error)
+	Save(ctx context.Context, record *entity.IdempotencyRecord) error
+	Delete(ctx context.Context, record *entity.IdempotencyRecord) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
 }
 
 type AccrualWorkerRepository interface {
-	GetOrdersToProcess(ctx context.Context) ([]entity.Order, error)
+	GetOrdersToProcess(ctx context.Context, claim *entity.ClaimInfo) ([]entity.Order, error)
 	UpdateOrderAndUserBalance(ctx context.Context, order entity.Order) error
+	RescheduleOrder(ctx context.Context, retry *entity.RetryInfo) error
+	FailOrder(ctx context.Context, workerID string, order entity.Order, reason string) error
+	GetOrderByNumber(ctx context.Context, number string) (*entity.Order, error)
+	UpdateAccrualStatus(ctx context.Context, order entity.Order, nextAttemptAt time.Time) error
 }
 
 type ServiceProvider struct {
 	OrderService         OrderService
 	AuthService          AuthService
 	BalanceService       BalanceService
+	TransferService      TransferService
 	AccrualWorkerService AccrualWorkerService
+	AccrualService       AccrualService
+
+	IdempotencyRepository IdempotencyRepository
+
+	Keys *jwt.KeySet
+
+	passwordPolicy entity.PasswordPolicy
+	resetNotifier  service.PasswordResetNotifier
 
-	db *postgres.DB
+	db  *postgres.DB
+	cfg config.Config
 }
 
-func NewServiceProvider(db *postgres.DB) *ServiceProvider {
+func NewServiceProvider(db *postgres.DB, cfg config.Config, keys *jwt.KeySet, passwordPolicy entity.PasswordPolicy,
+	resetNotifier service.PasswordResetNotifier) *ServiceProvider {
 	return &ServiceProvider{
-		db: db,
+		Keys:           keys,
+		passwordPolicy: passwordPolicy,
+		resetNotifier:  resetNotifier,
+		db:             db,
+		cfg:            cfg,
 	}
 }
 
@@ -70,15 +151,33 @@ func (s *ServiceProvider) RegisterServices() {
 	s.NewOrderService()
 	s.NewAuthService()
 	s.NewBalanceService()
+	s.NewTransferService()
+	s.NewAccrualService()
+	s.NewIdempotencyRepository()
+}
+
+// pollDelay is the time given to the accrual system to push the order status by callback
+// before the worker polls it.
+func (s *ServiceProvider) pollDelay() time.Duration {
+	if s.cfg.CallbackSecret == "" {
+		return 0
+	}
+
+	return s.cfg.CallbackTimeout
 }
 
 func (s *ServiceProvider) newAuthRepository() AuthRepository {
 	return repository.NewAuthRepository(s.db)
 }
 
+func (s *ServiceProvider) newSessionRepository() SessionRepository {
+	return repository.NewSessionRepository(s.db)
+}
+
 func (s *ServiceProvider) NewAuthService() AuthService {
 	if s.AuthService == nil {
-		s.AuthService = service.NewAuthService(s.newAuthRepository())
+		s.AuthService = service.NewAuthService(s.newAuthRepository(), s.newSessionRepository(), s.Keys,
+			s.resetNotifier, s.cfg.SessionPolicy(), s.cfg.LoginPolicy, s.passwordPolicy)
 	}
 
 	return s.AuthService
@@ -90,7 +189,7 @@ func (s *ServiceProvider) newOrderRepository() OrderRepository {
 
 func (s *ServiceProvider) NewOrderService() OrderService {
 	if s.OrderService == nil {
-		s.OrderService = service.NewOrderService(s.newOrderRepository())
+		s.OrderService = service.NewOrderService(s.newOrderRepository(), s.pollDelay())
 	}
 
 	return s.OrderService
@@ -102,8 +201,41 @@ func (s *ServiceProvider) newBalanceRepository() BalanceRepository {
 
 func (s *ServiceProvider) NewBalanceService() BalanceService {
 	if s.BalanceService == nil {
-		s.BalanceService = service.NewBalanceService(s.newBalanceRepository())
+		s.BalanceService = service.NewBalanceService(s.newBalanceRepository(), s.cfg.ExpiryPolicy(),
+			s.cfg.WithdrawalRules)
 	}
 
 	return s.BalanceService
 }
+
+func (s *ServiceProvider) newTransferRepository() TransferRepository {
+	return repository.NewTransferRepository(s.db)
+}
+
+func (s *ServiceProvider) NewTransferService() TransferService {
+	if s.TransferService == nil {
+		s.TransferService = service.NewTransferService(s.newTransferRepository(), s.cfg.TransferRules)
+	}
+
+	return s.TransferService
+}
+
+func (s *ServiceProvider) newAccrualWorkerRepository() AccrualWorkerRepository {
+	return repository.NewAccrualWorkerRepository(s.db)
+}
+
+func (s *ServiceProvider) NewAccrualService() AccrualService {
+	if s.AccrualService == nil {
+		s.AccrualService = service.NewAccrualService(s.newAccrualWorkerRepository(), s.pollDelay())
+	}
+
+	return s.AccrualService
+}
+
+func (s *ServiceProvider) NewIdempotencyRepository() IdempotencyRepository {
+	if s.IdempotencyRepository == nil {
+		s.IdempotencyRepository = repository.NewIdempotencyRepository(s.db)
+	}
+
+	return s.IdempotencyRepository
+}
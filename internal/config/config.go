@@ -5,8 +5,18 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/argon2id"
 )
 
 const (
@@ -24,24 +34,98 @@ const (
 	defaultIdleTimeout          = 1 * time.Minute
 	defaultShutdownTimeout      = 5 * time.Second
 	defaultWorkerPollInterval   = 10 * time.Second
+	defaultWorkerConcurrency    = 4
+	defaultWorkerBatchSize      = 20
+	defaultWorkerLease          = 2 * time.Minute
+	defaultWorkerRetryBase      = 10 * time.Second
+	defaultWorkerRetryMax       = 1 * time.Hour
+	defaultWorkerMaxAge         = 7 * 24 * time.Hour
+	defaultWorkerMaxAttempts    = 50
 	defaultClientTimeout        = 5 * time.Second
-)
-
-var (
-	defaultSigningKey = []byte("36626d331c8c44f2d72f348f36323743598e267e86b3e4aca27c5b433247ea72")
+	defaultAccrualMaxIdleConns  = 100
+	defaultBreakerFailures      = 5
+	defaultBreakerOpenTimeout   = 30 * time.Second
+	defaultBreakerProbes        = 1
+	defaultCallbackTimeout      = 1 * time.Minute
+	defaultExpiryInterval       = 1 * time.Hour
+	defaultExpirySoonWindow     = 30 * 24 * time.Hour
+	defaultExpiryBatchSize      = 100
+	defaultIdempotencyTTL       = 24 * time.Hour
+	defaultAccessTokenTTL       = 15 * time.Minute
+	defaultRefreshTokenTTL      = 30 * 24 * time.Hour
+	defaultLoginFreeAttempts    = 3
+	defaultLoginMaxAttempts     = 10
+	defaultLoginIPFreeAttempts  = 20
+	defaultLoginIPMaxAttempts   = 100
+	defaultLoginBaseDelay       = 1 * time.Second
+	defaultLoginLockout         = 15 * time.Minute
+	defaultHashingWait          = 2 * time.Second
+	defaultPasswordMinLength    = 9
+	defaultPasswordResetTTL     = 1 * time.Hour
 )
 
 type Config struct {
 	DB
 	App
 	HTTP
+	Accrual
+}
+
+// Secret hides sensitive values when the config is printed.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// SecretHeader hides header values when the config is printed, they may carry credentials.
+type SecretHeader http.Header
+
+func (h SecretHeader) String() string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name+": [REDACTED]")
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, "; ")
 }
 
 type App struct {
-	LogLevel             string
-	AccrualSystemAddress string
-	SigningKey           []byte
-	WorkerPollInterval   time.Duration
+	LogLevel                string
+	AccrualSystemAddress    string
+	JWTKeyFiles             []string
+	JWTEphemeralKey         bool
+	AccessTokenTTL          time.Duration
+	RefreshTokenTTL         time.Duration
+	LoginPolicy             entity.LoginPolicy
+	PasswordMinLength       int
+	PasswordResetTTL        time.Duration
+	PasswordResetSink       string
+	BreachedPasswordsFile   string
+	PasswordHashMemory      int
+	PasswordHashIterations  int
+	PasswordHashParallelism int
+	WorkerPollInterval      time.Duration
+	WorkerConcurrency       int
+	WorkerBatchSize         int
+	WorkerID                string
+	WorkerLease             time.Duration
+	WorkerRetryBase         time.Duration
+	WorkerRetryMax          time.Duration
+	WorkerMaxAge            time.Duration
+	WorkerMaxAttempts       int
+	OperatorToken           Secret
+	PointsExpiryMonths      int
+	ExpiryInterval          time.Duration
+	ExpirySoonWindow        time.Duration
+	ExpiryBatchSize         int
+	IdempotencyTTL          time.Duration
+	WithdrawalRules         entity.WithdrawalRules
+	TransferRules           entity.TransferRules
 }
 
 type DB struct {
@@ -59,6 +143,23 @@ type HTTP struct {
 	IdleTimeout       time.Duration
 	ShutdownTimeout   time.Duration
 	ClientTimeout     time.Duration
+	MetricsAddress    string
+}
+
+// Accrual configures the connection to the accrual system.
+type Accrual struct {
+	AccrualHeaders         SecretHeader
+	AccrualCAFile          string
+	AccrualCertFile        string
+	AccrualKeyFile         string
+	AccrualToken           Secret
+	AccrualMaxIdleConns    int
+	AccrualMaxConnsPerHost int
+	BreakerOpenTimeout     time.Duration
+	BreakerFailures        int
+	BreakerProbes          int
+	CallbackSecret         Secret
+	CallbackTimeout        time.Duration
 }
 
 func New() Config {
@@ -66,9 +167,29 @@ func New() Config {
 
 	cfg := Config{
 		App: App{
-			LogLevel:           defaultLogLevel,
-			SigningKey:         defaultSigningKey,
+			LogLevel:        defaultLogLevel,
+			AccessTokenTTL:  defaultAccessTokenTTL,
+			RefreshTokenTTL: defaultRefreshTokenTTL,
+			LoginPolicy: entity.LoginPolicy{
+				UserFreeAttempts: defaultLoginFreeAttempts,
+				IPFreeAttempts:   defaultLoginIPFreeAttempts,
+				BaseDelay:        defaultLoginBaseDelay,
+				HashingWait:      defaultHashingWait,
+			},
+			PasswordMinLength:  defaultPasswordMinLength,
+			PasswordResetTTL:   defaultPasswordResetTTL,
 			WorkerPollInterval: defaultWorkerPollInterval,
+			WorkerConcurrency:  defaultWorkerConcurrency,
+			WorkerBatchSize:    defaultWorkerBatchSize,
+			WorkerLease:        defaultWorkerLease,
+			WorkerRetryBase:    defaultWorkerRetryBase,
+			WorkerRetryMax:     defaultWorkerRetryMax,
+			WorkerMaxAge:       defaultWorkerMaxAge,
+			WorkerMaxAttempts:  defaultWorkerMaxAttempts,
+			ExpiryInterval:     defaultExpiryInterval,
+			ExpirySoonWindow:   defaultExpirySoonWindow,
+			ExpiryBatchSize:    defaultExpiryBatchSize,
+			IdempotencyTTL:     defaultIdempotencyTTL,
 		},
 		HTTP: HTTP{
 			CompressLevel:     defaultCompressLevel,
@@ -83,6 +204,14 @@ func New() Config {
 			DatabaseConnTimeout:  defaultDatabaseConnTimeout,
 			DatabaseConnAttempts: defaultDatabaseConnAttempts,
 		},
+		Accrual: Accrual{
+			AccrualHeaders:      SecretHeader{},
+			AccrualMaxIdleConns: defaultAccrualMaxIdleConns,
+			BreakerOpenTimeout:  defaultBreakerOpenTimeout,
+			BreakerFailures:     defaultBreakerFailures,
+			BreakerProbes:       defaultBreakerProbes,
+			CallbackTimeout:     defaultCallbackTimeout,
+		},
 	}
 
 	runAddressUsage := fmt.Sprintf("HTTP server endpoint, example: %q or %q",
@@ -95,6 +224,15 @@ func New() Config {
 	accrualSystemAddressUsage := fmt.Sprintf("Accrual system endpoint, example: %q", exampleAccrualSystemAddress)
 	flag.StringVar(&cfg.AccrualSystemAddress, "r", "", accrualSystemAddressUsage)
 
+	flag.StringVar(&cfg.MetricsAddress, "metrics-address", "",
+		`Endpoint of the metrics server with "/debug/vars", disabled if empty`)
+
+	cfg.authFlags()
+	cfg.passwordFlags()
+	cfg.workerFlags()
+	cfg.accrualFlags()
+	cfg.limitsFlags()
+
 	flag.Parse()
 
 	if runAddress := os.Getenv("RUN_ADDRESS"); runAddress != "" {
@@ -109,7 +247,385 @@ func New() Config {
 		cfg.AccrualSystemAddress = accrualSystemAddress
 	}
 
+	if metricsAddress := os.Getenv("METRICS_ADDRESS"); metricsAddress != "" {
+		cfg.MetricsAddress = metricsAddress
+	}
+
+	cfg.authFromEnv()
+	cfg.passwordFromEnv()
+	cfg.workerFromEnv()
+	cfg.accrualFromEnv()
+	cfg.limitsFromEnv()
+
 	log.Println("loaded config:", fmt.Sprintf("%+v", cfg))
 
 	return cfg
 }
+
+// authFlags registers the flags of access tokens, sessions, logins and the operator API.
+func (cfg *Config) authFlags() {
+	flag.Func("jwt-key-file", "PEM file of an RS256 or EdDSA key of access tokens, can be repeated. "+
+		"The first private key signs tokens, other keys only verify them, so the previous key can be kept "+
+		"after a rotation until its tokens expire",
+		func(value string) error {
+			cfg.JWTKeyFiles = append(cfg.JWTKeyFiles, value)
+			return nil
+		})
+	flag.BoolVar(&cfg.JWTEphemeralKey, "jwt-ephemeral-key", false,
+		"Generate a temporary key of access tokens if no key files are set, for development only. "+
+			"Tokens of one instance are rejected by others and after a restart")
+	flag.DurationVar(&cfg.AccessTokenTTL, "access-token-ttl", defaultAccessTokenTTL,
+		"Lifetime of access tokens")
+	flag.DurationVar(&cfg.RefreshTokenTTL, "refresh-token-ttl", defaultRefreshTokenTTL,
+		"How long a session lasts without a token refresh")
+	flag.IntVar(&cfg.LoginPolicy.UserMaxAttempts, "login-max-attempts", defaultLoginMaxAttempts,
+		fmt.Sprintf("Failed logins of a username that lock it out, attempts after the first %d failures are delayed",
+			defaultLoginFreeAttempts))
+	flag.IntVar(&cfg.LoginPolicy.IPMaxAttempts, "login-max-ip-attempts", defaultLoginIPMaxAttempts,
+		fmt.Sprintf("Failed logins from an IP that lock it out, attempts after the first %d failures are delayed",
+			defaultLoginIPFreeAttempts))
+	flag.DurationVar(&cfg.LoginPolicy.LockoutDuration, "login-lockout", defaultLoginLockout,
+		"How long a username or an IP is locked out after too many failed logins")
+	flag.IntVar(&cfg.LoginPolicy.MaxHashing, "max-password-hashing", runtime.NumCPU(),
+		"Maximum number of password hashes computed at once")
+	flag.Func("operator-token", "Bearer token of the operator API, the API is disabled if empty",
+		func(value string) error {
+			cfg.OperatorToken = Secret(value)
+			return nil
+		})
+}
+
+// authFromEnv overrides the auth flags with the environment variables.
+func (cfg *Config) authFromEnv() {
+	// JWT_KEY_FILES="/keys/new.pem,/keys/old.pem"
+	if keyFiles := os.Getenv("JWT_KEY_FILES"); keyFiles != "" {
+		cfg.JWTKeyFiles = strings.Split(keyFiles, ",")
+	}
+
+	boolFromEnv("JWT_EPHEMERAL_KEY", &cfg.JWTEphemeralKey)
+
+	durationFromEnv("ACCESS_TOKEN_TTL", &cfg.AccessTokenTTL)
+	durationFromEnv("REFRESH_TOKEN_TTL", &cfg.RefreshTokenTTL)
+
+	intFromEnv("LOGIN_MAX_ATTEMPTS", &cfg.LoginPolicy.UserMaxAttempts)
+	intFromEnv("LOGIN_MAX_IP_ATTEMPTS", &cfg.LoginPolicy.IPMaxAttempts)
+	durationFromEnv("LOGIN_LOCKOUT", &cfg.LoginPolicy.LockoutDuration)
+	intFromEnv("MAX_PASSWORD_HASHING", &cfg.LoginPolicy.MaxHashing)
+
+	if operatorToken := os.Getenv("OPERATOR_TOKEN"); operatorToken != "" {
+		cfg.OperatorToken = Secret(operatorToken)
+	}
+}
+
+// passwordFlags registers the flags of the password policy, reset and hashing.
+func (cfg *Config) passwordFlags() {
+	flag.IntVar(&cfg.PasswordMinLength, "password-min-length", defaultPasswordMinLength,
+		"Minimum length of new passwords")
+	flag.StringVar(&cfg.BreachedPasswordsFile, "breached-passwords-file", "",
+		"File of passwords known from data breaches, one per line, they can't be used as new passwords")
+	flag.StringVar(&cfg.PasswordResetSink, "password-reset-sink", "",
+		`Where password reset tokens are sent for local use, "log" or "file:<path>", reset is disabled if empty`)
+	flag.DurationVar(&cfg.PasswordResetTTL, "password-reset-ttl", defaultPasswordResetTTL,
+		"How long a password reset token is valid")
+	flag.IntVar(&cfg.PasswordHashMemory, "password-hash-memory", int(argon2id.DefaultParams.Memory),
+		"Memory of argon2id password hashes in KiB, hashes with other params are replaced on login")
+	flag.IntVar(&cfg.PasswordHashIterations, "password-hash-iterations", int(argon2id.DefaultParams.Iterations),
+		"Iterations of argon2id password hashes")
+	flag.IntVar(&cfg.PasswordHashParallelism, "password-hash-parallelism",
+		int(argon2id.DefaultParams.Parallelism), "Threads of argon2id password hashes")
+}
+
+// passwordFromEnv overrides the password flags with the environment variables.
+func (cfg *Config) passwordFromEnv() {
+	intFromEnv("PASSWORD_MIN_LENGTH", &cfg.PasswordMinLength)
+
+	if breachedFile := os.Getenv("BREACHED_PASSWORDS_FILE"); breachedFile != "" {
+		cfg.BreachedPasswordsFile = breachedFile
+	}
+
+	if resetSink := os.Getenv("PASSWORD_RESET_SINK"); resetSink != "" {
+		cfg.PasswordResetSink = resetSink
+	}
+
+	durationFromEnv("PASSWORD_RESET_TTL", &cfg.PasswordResetTTL)
+
+	intFromEnv("PASSWORD_HASH_MEMORY", &cfg.PasswordHashMemory)
+	intFromEnv("PASSWORD_HASH_ITERATIONS", &cfg.PasswordHashIterations)
+	intFromEnv("PASSWORD_HASH_PARALLELISM", &cfg.PasswordHashParallelism)
+}
+
+// workerFlags registers the flags of the accrual and points expiry workers.
+func (cfg *Config) workerFlags() {
+	flag.DurationVar(&cfg.WorkerPollInterval, "worker-poll-interval", defaultWorkerPollInterval,
+		"Interval between accrual worker polls of new orders")
+	flag.IntVar(&cfg.WorkerConcurrency, "worker-concurrency", defaultWorkerConcurrency,
+		"Number of concurrent accrual system requests")
+	flag.IntVar(&cfg.WorkerBatchSize, "worker-batch-size", defaultWorkerBatchSize,
+		"Maximum number of orders taken per worker poll")
+	flag.StringVar(&cfg.WorkerID, "worker-id", defaultWorkerID(),
+		"Unique accrual worker name, used to lease orders between several instances")
+	flag.DurationVar(&cfg.WorkerLease, "worker-lease", defaultWorkerLease,
+		"How long a claimed order stays leased to the worker")
+	flag.DurationVar(&cfg.WorkerRetryBase, "worker-retry-base", defaultWorkerRetryBase,
+		"First delay before retrying a failed accrual request, doubled on every attempt")
+	flag.DurationVar(&cfg.WorkerRetryMax, "worker-retry-max", defaultWorkerRetryMax,
+		"Maximum delay between retries of a failed accrual request")
+	flag.DurationVar(&cfg.WorkerMaxAge, "worker-max-age", defaultWorkerMaxAge,
+		"Order age after which a failed order is marked as invalid, 0 to disable")
+	flag.IntVar(&cfg.WorkerMaxAttempts, "worker-max-attempts", defaultWorkerMaxAttempts,
+		"Failed attempts after which the order is marked as invalid, 0 to disable")
+	flag.IntVar(&cfg.PointsExpiryMonths, "points-expiry-months", 0,
+		"Months after which credited points expire, 0 means they never expire")
+	flag.DurationVar(&cfg.ExpiryInterval, "expiry-interval", defaultExpiryInterval,
+		"Interval between runs of the points expiry job")
+	flag.DurationVar(&cfg.ExpirySoonWindow, "expiry-soon-window", defaultExpirySoonWindow,
+		"Points expiring within this window are shown as expiring soon in the balance")
+	flag.IntVar(&cfg.ExpiryBatchSize, "expiry-batch-size", defaultExpiryBatchSize,
+		"Maximum number of lots expired per batch")
+}
+
+// workerFromEnv overrides the worker flags with the environment variables.
+func (cfg *Config) workerFromEnv() {
+	durationFromEnv("WORKER_POLL_INTERVAL", &cfg.WorkerPollInterval)
+	intFromEnv("WORKER_CONCURRENCY", &cfg.WorkerConcurrency)
+	intFromEnv("WORKER_BATCH_SIZE", &cfg.WorkerBatchSize)
+	durationFromEnv("WORKER_LEASE", &cfg.WorkerLease)
+	durationFromEnv("WORKER_RETRY_BASE", &cfg.WorkerRetryBase)
+	durationFromEnv("WORKER_RETRY_MAX", &cfg.WorkerRetryMax)
+	durationFromEnv("WORKER_MAX_AGE", &cfg.WorkerMaxAge)
+	intFromEnv("WORKER_MAX_ATTEMPTS", &cfg.WorkerMaxAttempts)
+
+	if workerID := os.Getenv("WORKER_ID"); workerID != "" {
+		cfg.WorkerID = workerID
+	}
+
+	intFromEnv("POINTS_EXPIRY_MONTHS", &cfg.PointsExpiryMonths)
+	durationFromEnv("EXPIRY_INTERVAL", &cfg.ExpiryInterval)
+	durationFromEnv("EXPIRY_SOON_WINDOW", &cfg.ExpirySoonWindow)
+	intFromEnv("EXPIRY_BATCH_SIZE", &cfg.ExpiryBatchSize)
+}
+
+// accrualFlags registers the flags of the accrual system client, circuit breaker and callbacks.
+func (cfg *Config) accrualFlags() {
+	flag.StringVar(&cfg.AccrualCAFile, "accrual-ca-file", "",
+		"PEM bundle to verify the accrual system certificate")
+	flag.StringVar(&cfg.AccrualCertFile, "accrual-cert-file", "",
+		"PEM client certificate for mutual TLS with the accrual system")
+	flag.StringVar(&cfg.AccrualKeyFile, "accrual-key-file", "",
+		"PEM client key for mutual TLS with the accrual system")
+	flag.Func("accrual-token", "Bearer token sent to the accrual system", func(value string) error {
+		cfg.AccrualToken = Secret(value)
+		return nil
+	})
+	flag.Func("accrual-header", `Extra header sent to the accrual system, example: "X-Client: gophermart"`,
+		func(value string) error {
+			return addHeader(http.Header(cfg.AccrualHeaders), value)
+		})
+	flag.IntVar(&cfg.AccrualMaxIdleConns, "accrual-max-idle-conns", defaultAccrualMaxIdleConns,
+		"Maximum idle connections to the accrual system")
+	flag.IntVar(&cfg.AccrualMaxConnsPerHost, "accrual-max-conns", 0,
+		"Maximum connections to the accrual system, 0 means no limit")
+	flag.IntVar(&cfg.BreakerFailures, "breaker-failures", defaultBreakerFailures,
+		"Consecutive accrual system failures that open the circuit breaker")
+	flag.DurationVar(&cfg.BreakerOpenTimeout, "breaker-open-timeout", defaultBreakerOpenTimeout,
+		"How long the circuit breaker stays open before probing the accrual system")
+	flag.IntVar(&cfg.BreakerProbes, "breaker-probes", defaultBreakerProbes,
+		"Successful probe requests needed to close the circuit breaker")
+	flag.Func("accrual-callback-secret", "HMAC secret of accrual system callbacks, callbacks are disabled if empty",
+		func(value string) error {
+			cfg.CallbackSecret = Secret(value)
+			return nil
+		})
+	flag.DurationVar(&cfg.CallbackTimeout, "accrual-callback-timeout", defaultCallbackTimeout,
+		"How long to wait for an accrual system callback before polling the order")
+}
+
+// accrualFromEnv overrides the accrual flags with the environment variables.
+func (cfg *Config) accrualFromEnv() {
+	if caFile := os.Getenv("ACCRUAL_CA_FILE"); caFile != "" {
+		cfg.AccrualCAFile = caFile
+	}
+
+	if certFile := os.Getenv("ACCRUAL_CERT_FILE"); certFile != "" {
+		cfg.AccrualCertFile = certFile
+	}
+
+	if keyFile := os.Getenv("ACCRUAL_KEY_FILE"); keyFile != "" {
+		cfg.AccrualKeyFile = keyFile
+	}
+
+	if token := os.Getenv("ACCRUAL_TOKEN"); token != "" {
+		cfg.AccrualToken = Secret(token)
+	}
+
+	// ACCRUAL_HEADERS="X-Client: gophermart; X-Region: eu"
+	if headers := os.Getenv("ACCRUAL_HEADERS"); headers != "" {
+		for _, header := range strings.Split(headers, ";") {
+			if err := addHeader(http.Header(cfg.AccrualHeaders), header); err != nil {
+				log.Printf("can't parse ACCRUAL_HEADERS: %s", err.Error())
+			}
+		}
+	}
+
+	intFromEnv("ACCRUAL_MAX_IDLE_CONNS", &cfg.AccrualMaxIdleConns)
+	intFromEnv("ACCRUAL_MAX_CONNS", &cfg.AccrualMaxConnsPerHost)
+	intFromEnv("BREAKER_FAILURES", &cfg.BreakerFailures)
+	durationFromEnv("BREAKER_OPEN_TIMEOUT", &cfg.BreakerOpenTimeout)
+	intFromEnv("BREAKER_PROBES", &cfg.BreakerProbes)
+
+	if callbackSecret := os.Getenv("ACCRUAL_CALLBACK_SECRET"); callbackSecret != "" {
+		cfg.CallbackSecret = Secret(callbackSecret)
+	}
+
+	durationFromEnv("ACCRUAL_CALLBACK_TIMEOUT", &cfg.CallbackTimeout)
+}
+
+// limitsFlags registers the flags of idempotency, withdrawal and transfer limits.
+func (cfg *Config) limitsFlags() {
+	flag.DurationVar(&cfg.IdempotencyTTL, "idempotency-ttl", defaultIdempotencyTTL,
+		"How long responses of requests with the Idempotency-Key header are replayed")
+	flag.Func("withdrawal-max-sum", "Maximum sum of a single withdrawal in points, 0 means no limit",
+		func(value string) error {
+			return parsePoints(value, &cfg.WithdrawalRules.MaxPerWithdrawal)
+		})
+	flag.Func("withdrawal-max-per-day", "Maximum sum withdrawn by a user within 24 hours in points, 0 means no limit",
+		func(value string) error {
+			return parsePoints(value, &cfg.WithdrawalRules.MaxPerDay)
+		})
+	flag.Func("withdrawal-max-per-week", "Maximum sum withdrawn by a user within 7 days in points, 0 means no limit",
+		func(value string) error {
+			return parsePoints(value, &cfg.WithdrawalRules.MaxPerWeek)
+		})
+	flag.DurationVar(&cfg.WithdrawalRules.MinAccountAge, "withdrawal-min-account-age", 0,
+		"Minimum account age before the user can withdraw points, 0 to disable")
+	flag.IntVar(&cfg.WithdrawalRules.MaxPerHour, "withdrawal-max-per-hour", 0,
+		"Maximum number of withdrawals by a user within an hour, 0 means no limit")
+	flag.Func("transfer-max-sum", "Maximum sum of a single transfer in points, 0 means no limit",
+		func(value string) error {
+			return parsePoints(value, &cfg.TransferRules.MaxPerTransfer)
+		})
+	flag.Func("transfer-max-per-day", "Maximum sum transferred by a user within 24 hours in points, 0 means no limit",
+		func(value string) error {
+			return parsePoints(value, &cfg.TransferRules.MaxPerDay)
+		})
+}
+
+// limitsFromEnv overrides the limits flags with the environment variables.
+func (cfg *Config) limitsFromEnv() {
+	durationFromEnv("IDEMPOTENCY_TTL", &cfg.IdempotencyTTL)
+
+	pointsFromEnv("WITHDRAWAL_MAX_SUM", &cfg.WithdrawalRules.MaxPerWithdrawal)
+	pointsFromEnv("WITHDRAWAL_MAX_PER_DAY", &cfg.WithdrawalRules.MaxPerDay)
+	pointsFromEnv("WITHDRAWAL_MAX_PER_WEEK", &cfg.WithdrawalRules.MaxPerWeek)
+	durationFromEnv("WITHDRAWAL_MIN_ACCOUNT_AGE", &cfg.WithdrawalRules.MinAccountAge)
+	intFromEnv("WITHDRAWAL_MAX_PER_HOUR", &cfg.WithdrawalRules.MaxPerHour)
+	pointsFromEnv("TRANSFER_MAX_SUM", &cfg.TransferRules.MaxPerTransfer)
+	pointsFromEnv("TRANSFER_MAX_PER_DAY", &cfg.TransferRules.MaxPerDay)
+}
+
+// ExpiryPolicy returns the points expiry policy of the app.
+func (a App) ExpiryPolicy() entity.ExpiryPolicy {
+	return entity.ExpiryPolicy{
+		SoonWindow: a.ExpirySoonWindow,
+		Months:     a.PointsExpiryMonths,
+	}
+}
+
+// SessionPolicy returns the lifetime of access tokens and sessions.
+func (a App) SessionPolicy() entity.SessionPolicy {
+	return entity.SessionPolicy{
+		AccessTTL:  a.AccessTokenTTL,
+		RefreshTTL: a.RefreshTokenTTL,
+	}
+}
+
+func addHeader(headers http.Header, value string) error {
+	name, headerValue, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q must be in \"Name: value\" format", value)
+	}
+
+	headers.Add(strings.TrimSpace(name), strings.TrimSpace(headerValue))
+
+	return nil
+}
+
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gophermart"
+	}
+
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func durationFromEnv(key string, value *time.Duration) {
+	env := os.Getenv(key)
+	if env == "" {
+		return
+	}
+
+	duration, err := time.ParseDuration(env)
+	if err != nil {
+		log.Printf("can't parse %s, using %s: %s", key, *value, err.Error())
+		return
+	}
+
+	*value = duration
+}
+
+func boolFromEnv(key string, value *bool) {
+	env := os.Getenv(key)
+	if env == "" {
+		return
+	}
+
+	enabled, err := strconv.ParseBool(env)
+	if err != nil {
+		log.Printf("can't parse %s, using %t: %s", key, *value, err.Error())
+		return
+	}
+
+	*value = enabled
+}
+
+func intFromEnv(key string, value *int) {
+	env := os.Getenv(key)
+	if env == "" {
+		return
+	}
+
+	number, err := strconv.Atoi(env)
+	if err != nil {
+		log.Printf("can't parse %s, using %d: %s", key, *value, err.Error())
+		return
+	}
+
+	*value = number
+}
+
+// parsePoints converts a points amount like "1000.50" to hundredths of a point.
+func parsePoints(value string, points *int64) error {
+	amount, err := decimal.NewFromString(value)
+	if err != nil {
+		return err
+	}
+	if amount.IsNegative() {
+		return fmt.Errorf("points amount %q must not be negative", value)
+	}
+
+	*points = amount.Mul(decimal.NewFromInt(entity.DecimalPartDiv)).IntPart()
+
+	return nil
+}
+
+func pointsFromEnv(key string, value *int64) {
+	env := os.Getenv(key)
+	if env == "" {
+		return
+	}
+
+	if err := parsePoints(env, value); err != nil {
+		log.Printf("can't parse %s, using %d: %s", key, *value, err.Error())
+	}
+}
@@ -6,25 +6,57 @@ const (
 	DecimalPartDiv = 100
 )
 
+// Balance is the user balance of the default program, Programs lists the accounts in all
+// loyalty programs including the default one.
 type Balance struct {
-	ID        string
-	Balance   int64
-	Withdrawn int64
+	ID            string
+	Balance       int64
+	Withdrawn     int64
+	ExpiringSoon  int64
+	Pending       int64
+	PendingOrders int
+	Programs      []ProgramBalance
 }
 
+// PendingAccrual is the expected accrual of the user orders in a program that are not final
+// yet, Sum is in hundredths of a point of the accrual system.
+type PendingAccrual struct {
+	Sum    int64
+	Orders int
+}
+
+const (
+	WithdrawStatusWithdrawn = "WITHDRAWN"
+	WithdrawStatusReversed  = "REVERSED"
+)
+
 type Withdraw struct {
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   *time.Time
-	ID          string
-	UserID      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time
+	ReversedAt     *time.Time
+	ID             string
+	UserID         string
+	OrderNumber    string
+	Status         string
+	ReversalReason string
+	ProgramID      string
+	Withdrawn      int64
+	Precision      int32
+}
+
+// ReversalInfo describes the withdrawal returned to the user, for example when the shop
+// cancels the order.
+type ReversalInfo struct {
 	OrderNumber string
-	Withdrawn   int64
+	Reason      string
 }
 
+// WithdrawInfo describes a new withdrawal, Sum is in the smallest units of the program.
 type WithdrawInfo struct {
 	ID          string
 	UserID      string
 	OrderNumber string
+	ProgramID   string
 	Sum         int64
 }
@@ -6,14 +6,38 @@ var (
 	ErrUsernameUniqueViolation  = errors.New("username already exists")
 	ErrUsernameNotFound         = errors.New("username not found")
 	ErrIncorrectLoginOrPassword = errors.New("incorrect login or password")
+	ErrInvalidRefreshToken      = errors.New("invalid refresh token")
+	ErrRefreshTokenReused       = errors.New("refresh token is already used")
+	ErrSessionNotFound          = errors.New("session not found")
+	ErrTooManyAttempts          = errors.New("too many attempts, try again later")
+	ErrIncorrectPassword        = errors.New("incorrect current password")
+	ErrWeakPassword             = errors.New("password doesn't meet the password policy")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
 
 	ErrOrderUniqueViolation  = errors.New("order already exists")
 	ErrUploadedByThisUser    = errors.New("already uploaded by this user")
 	ErrUploadedByAnotherUser = errors.New("already uploaded by another user")
 	ErrNoOrdersFound         = errors.New("no orders found")
+	ErrOrderNotFound         = errors.New("order not found")
+	ErrOrderAlreadyFinal     = errors.New("order status is already final")
+	ErrUnknownOrderStatus    = errors.New("unknown order status")
 
 	ErrNotEnoughPointsToWithdraw = errors.New("not enough points to withdraw")
 	ErrNoWithdrawalsFound        = errors.New("no withdrawals found")
+	ErrWithdrawalNotFound        = errors.New("withdrawal not found")
+	ErrWithdrawalAlreadyReversed = errors.New("withdrawal is already reversed")
+	ErrRuleViolation             = errors.New("limit rule violated")
+
+	ErrRecipientNotFound   = errors.New("recipient not found")
+	ErrTransferToSelf      = errors.New("can't transfer points to yourself")
+	ErrTransferNotAllowed  = errors.New("recipient doesn't accept transfers from this user")
+	ErrTransferKeyReused   = errors.New("idempotency key is already used for another transfer")
+	ErrAllowedUserNotFound = errors.New("user to allow not found")
+
+	ErrProgramNotFound        = errors.New("loyalty program not found")
+	ErrProgramUniqueViolation = errors.New("loyalty program already exists")
+
+	ErrIdempotencyLeaseLost = errors.New("idempotency key is reserved by another request")
 
 	ErrCanNotUpdateOrder       = errors.New("can't update order")
 	ErrCanNotUpdateUserBalance = errors.New("can't update user balance")
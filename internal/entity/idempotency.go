@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// IdempotencyRecord is the response stored for the idempotency key of the user. StatusCode is
+// zero while the first request with the key is still processed, the key is locked for it
+// until LockedUntil. ReservationID is owned by the request that reserved the key.
+type IdempotencyRecord struct {
+	ExpiresAt     time.Time
+	LockedUntil   time.Time
+	ReservationID string
+	UserID        string
+	Key           string
+	RequestHash   string
+	ContentType   string
+	Body          []byte
+	StatusCode    int
+}
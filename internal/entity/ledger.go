@@ -0,0 +1,70 @@
+package entity
+
+import "time"
+
+// EntryKind is the reason of a ledger entry.
+type EntryKind string
+
+const (
+	EntryAccrual     EntryKind = "accrual"
+	EntryWithdrawal  EntryKind = "withdrawal"
+	EntryAdjustment  EntryKind = "adjustment"
+	EntryReversal    EntryKind = "reversal"
+	EntryExpiration  EntryKind = "expiration"
+	EntryTransferIn  EntryKind = "transfer_in"
+	EntryTransferOut EntryKind = "transfer_out"
+)
+
+// LedgerEntry is an append-only change of the user balance in a loyalty program, Amount is signed
+// and in the smallest units of the program. ReferenceID points to the reversed entry.
+type LedgerEntry struct {
+	CreatedAt   time.Time
+	ID          string
+	UserID      string
+	ProgramID   string
+	OrderNumber string
+	ReferenceID string
+	Reason      string
+	Kind        EntryKind
+	Amount      int64
+}
+
+// ParseEntryKind reports whether the value is a known ledger entry kind.
+func ParseEntryKind(value string) (EntryKind, bool) {
+	switch kind := EntryKind(value); kind {
+	case EntryAccrual, EntryWithdrawal, EntryAdjustment, EntryReversal, EntryExpiration, EntryTransferIn,
+		EntryTransferOut:
+		return kind, true
+	}
+	return "", false
+}
+
+// Transaction is a ledger entry with the user balance in the program right after it.
+type Transaction struct {
+	LedgerEntry
+	Balance   int64
+	Precision int32
+}
+
+// TransactionFilter selects a page of user transactions.
+type TransactionFilter struct {
+	PageFilter
+	Kinds []EntryKind
+}
+
+// ExpiryPolicy describes when credited points expire. Points are spent from the oldest
+// credit and expire Months after it, zero Months means they never expire.
+type ExpiryPolicy struct {
+	SoonWindow time.Duration
+	Months     int
+}
+
+// Enabled reports whether points expire at all.
+func (p ExpiryPolicy) Enabled() bool {
+	return p.Months > 0
+}
+
+// CreditedBefore returns the time before which points credited are expired at now.
+func (p ExpiryPolicy) CreditedBefore(now time.Time) time.Time {
+	return now.AddDate(0, -p.Months, 0)
+}
@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// LoginInfo is a login attempt from the RemoteIP.
+type LoginInfo struct {
+	Username string
+	Password string
+	RemoteIP string
+}
+
+// LoginPolicy limits failed login attempts per username and per IP and the number of password
+// hashes computed at once. Failures over the free attempts delay the next attempt, the delay is
+// doubled on every failure until the lockout.
+type LoginPolicy struct {
+	UserFreeAttempts int
+	UserMaxAttempts  int
+	IPFreeAttempts   int
+	IPMaxAttempts    int
+	BaseDelay        time.Duration
+	LockoutDuration  time.Duration
+	MaxHashing       int
+	HashingWait      time.Duration
+}
+
+// ThrottleError is returned while the login attempts are blocked or the password hashing
+// is busy, the request can be retried after RetryAfter.
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *ThrottleError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
@@ -3,14 +3,22 @@ package entity
 import "time"
 
 type Order struct {
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt *time.Time
-	ID        string
-	UserID    string
-	Number    string
-	Status    string
-	Accrual   int64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	NextAttemptAt   time.Time
+	DeletedAt       *time.Time
+	CheckedAt       *time.Time
+	ID              string
+	UserID          string
+	Number          string
+	AccrualStatus   string
+	LastError       string
+	ProgramID       string
+	Accrual         int64
+	ExpectedAccrual int64
+	Status          Status
+	Attempts        int
+	Precision       int32
 }
 
 type Status int
@@ -20,6 +28,8 @@ const (
 	StatusProcessing
 	StatusInvalid
 	StatusProcessed
+	StatusRegistered
+	StatusUnknown
 )
 
 func (s Status) String() string {
@@ -32,12 +42,65 @@ func (s Status) String() string {
 		return "INVALID"
 	case StatusProcessed:
 		return "PROCESSED"
+	case StatusRegistered:
+		return "REGISTERED"
 	}
 	return "UNKNOWN"
 }
 
+// IsFinal reports whether the status will not change anymore.
+func (s Status) IsFinal() bool {
+	return s == StatusInvalid || s == StatusProcessed
+}
+
+// ParseStatus converts an order status of gophermart or the accrual system to Status,
+// unexpected values are reported as StatusUnknown.
+func ParseStatus(status string) Status {
+	switch status {
+	case "NEW":
+		return StatusNew
+	case "PROCESSING":
+		return StatusProcessing
+	case "INVALID":
+		return StatusInvalid
+	case "PROCESSED":
+		return StatusProcessed
+	case "REGISTERED":
+		return StatusRegistered
+	}
+	return StatusUnknown
+}
+
 type OrderInfo struct {
-	ID     string
-	UserID string
-	Number string
+	NextAttemptAt time.Time
+	ID            string
+	UserID        string
+	Number        string
+	ProgramID     string
+}
+
+// ClaimInfo describes a batch of orders leased by a worker.
+type ClaimInfo struct {
+	WorkerID string
+	Lease    time.Duration
+	Count    int
+}
+
+// RetryInfo describes when a leased order should be polled again.
+type RetryInfo struct {
+	NextAttemptAt   time.Time
+	CheckedAt       *time.Time
+	OrderID         string
+	WorkerID        string
+	AccrualStatus   string
+	LastError       string
+	ExpectedAccrual int64
+	Attempts        int
+}
+
+// AccrualInfo is the order status pushed by the accrual system, Accrual is in hundredths of a point.
+type AccrualInfo struct {
+	Number  string
+	Status  string
+	Accrual int64
 }
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// PageFilter selects a page of user entities sorted by creation time. From is inclusive,
+// To is exclusive, the page starts after the AfterTime and AfterID entity.
+type PageFilter struct {
+	From       time.Time
+	To         time.Time
+	AfterTime  time.Time
+	UserID     string
+	AfterID    string
+	Limit      int
+	Descending bool
+}
+
+type OrderFilter struct {
+	PageFilter
+	Statuses []Status
+}
@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ivas1ly/gophermart/pkg/argon2id"
+)
+
+const (
+	PasswordRuleMinLength        = "min_length"
+	PasswordRuleBreached         = "breached"
+	PasswordRuleContainsUsername = "contains_username"
+
+	// minUsernameInPassword is the shortest username checked in passwords, shorter ones
+	// would forbid too many passwords.
+	minUsernameInPassword = 3
+)
+
+// PasswordPolicy is checked for new passwords. Breached contains lowercased passwords known
+// from leaks. HashParams are used for new hashes, stored hashes with other params are
+// replaced on login.
+type PasswordPolicy struct {
+	Breached   map[string]struct{}
+	HashParams *argon2id.Params
+	MinLength  int
+	ResetTTL   time.Duration
+}
+
+// Check returns PasswordViolation if the password of the user doesn't meet the policy.
+func (p PasswordPolicy) Check(username, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &PasswordViolation{
+			Rule:    PasswordRuleMinLength,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		}
+	}
+
+	lowered := strings.ToLower(password)
+
+	if _, ok := p.Breached[lowered]; ok {
+		return &PasswordViolation{
+			Rule:    PasswordRuleBreached,
+			Message: "password is known from data breaches",
+		}
+	}
+
+	if utf8.RuneCountInString(username) >= minUsernameInPassword &&
+		strings.Contains(lowered, strings.ToLower(username)) {
+		return &PasswordViolation{
+			Rule:    PasswordRuleContainsUsername,
+			Message: "password must not contain the username",
+		}
+	}
+
+	return nil
+}
+
+// PasswordViolation names the violated password rule.
+type PasswordViolation struct {
+	Rule    string
+	Message string
+}
+
+func (v *PasswordViolation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+func (v *PasswordViolation) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// PasswordReset is a one-time token to set a new password without the current one.
+// Token is sent to the user by the notifier, only TokenHash is stored.
+type PasswordReset struct {
+	ExpiresAt time.Time
+	UserID    string
+	Username  string
+	Token     string
+	TokenHash string
+}
@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultProgramID is the program of orders and withdrawals that don't name one.
+	DefaultProgramID = "default"
+	// DefaultPrecision is the number of decimal places of the default program and the accrual system.
+	DefaultPrecision = 2
+)
+
+// Program is a loyalty program with its own points. Amounts of the program are stored in its
+// smallest units, Precision is the number of decimal places of the points. ConversionRate is
+// the number of program points credited for one point of the accrual system.
+type Program struct {
+	CreatedAt      time.Time
+	ConversionRate decimal.Decimal
+	ID             string
+	Name           string
+	Precision      int32
+}
+
+// ToPoints converts an amount in the smallest units of the program to points.
+func (p Program) ToPoints(amount int64) decimal.Decimal {
+	return ToPoints(amount, p.Precision)
+}
+
+// FromPoints converts points to the smallest units of the program, extra decimal places are truncated.
+func (p Program) FromPoints(points decimal.Decimal) int64 {
+	return points.Shift(p.Precision).IntPart()
+}
+
+// Convert converts an accrual in hundredths of a point of the accrual system to the smallest
+// units of the program, rounding down.
+func (p Program) Convert(accrual int64) int64 {
+	return ToPoints(accrual, DefaultPrecision).Mul(p.ConversionRate).Shift(p.Precision).IntPart()
+}
+
+// ToPoints converts an amount in the smallest units to points with the precision.
+func ToPoints(amount int64, precision int32) decimal.Decimal {
+	return decimal.New(amount, -precision)
+}
+
+// ProgramBalance is the user account in a loyalty program. ExpiringSoon and Pending are
+// in the smallest units of the program too.
+type ProgramBalance struct {
+	Program       Program
+	Balance       int64
+	Withdrawn     int64
+	ExpiringSoon  int64
+	Pending       int64
+	PendingOrders int
+}
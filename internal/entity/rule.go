@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	RuleMaxPerWithdrawal = "max_per_withdrawal"
+	RuleMaxPerDay        = "max_per_day"
+	RuleMaxPerWeek       = "max_per_week"
+	RuleMinAccountAge    = "min_account_age"
+	RuleMaxPerHour       = "max_withdrawals_per_hour"
+
+	RuleMaxPerTransfer    = "max_per_transfer"
+	RuleMaxTransferPerDay = "max_transfer_per_day"
+)
+
+// WithdrawalRules limits withdrawals of a user, zero values disable the rules. Sums are
+// in hundredths of a point of the accrual system, they are converted to points of the program
+// by its conversion rate. Day and week are rolling windows.
+type WithdrawalRules struct {
+	MinAccountAge    time.Duration
+	MaxPerWithdrawal int64
+	MaxPerDay        int64
+	MaxPerWeek       int64
+	MaxPerHour       int
+}
+
+// WithdrawalStats is the user history the withdrawal rules are checked against. Reversed
+// withdrawals are not counted.
+type WithdrawalStats struct {
+	Program       Program
+	UserCreatedAt time.Time
+	Now           time.Time
+	DaySum        int64
+	WeekSum       int64
+	HourCount     int
+}
+
+// WithdrawalCheck is called in the withdrawal transaction with the locked user history.
+type WithdrawalCheck func(stats *WithdrawalStats) error
+
+// RuleViolation names the violated withdrawal rule and its limit.
+type RuleViolation struct {
+	Rule    string
+	Limit   string
+	Message string
+}
+
+func (v *RuleViolation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+func (v *RuleViolation) Is(target error) bool {
+	return target == ErrRuleViolation
+}
@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+// Session is a login of the user. Access tokens of the session are rejected once it is revoked,
+// it expires when its refresh token isn't used in time.
+type Session struct {
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	ID        string
+	UserID    string
+}
+
+// SessionInfo is a new session with the hash of its first refresh token.
+type SessionInfo struct {
+	ExpiresAt        time.Time
+	ID               string
+	UserID           string
+	RefreshTokenHash string
+}
+
+// RefreshInfo replaces the refresh token of the session with a new one, the session is extended
+// to ExpiresAt.
+type RefreshInfo struct {
+	ExpiresAt    time.Time
+	TokenHash    string
+	NewTokenHash string
+}
+
+// Tokens are issued on login and refresh, the refresh token is returned to the user only once.
+type Tokens struct {
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+	SessionID        string
+	AccessToken      string
+	RefreshToken     string
+}
+
+// SessionPolicy sets the lifetime of access tokens and of sessions without a refresh.
+type SessionPolicy struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
@@ -0,0 +1,51 @@
+package entity
+
+import "time"
+
+// Transfer moves points of a loyalty program between users, it is written to the ledger
+// of both users. IdempotencyKey is unique per sender.
+type Transfer struct {
+	CreatedAt      time.Time
+	ID             string
+	SenderID       string
+	Sender         string
+	RecipientID    string
+	Recipient      string
+	ProgramID      string
+	IdempotencyKey string
+	Amount         int64
+	Precision      int32
+}
+
+// TransferInfo describes a new transfer to the recipient username, Sum is in the smallest
+// units of the program.
+type TransferInfo struct {
+	ID             string
+	SenderID       string
+	Recipient      string
+	ProgramID      string
+	IdempotencyKey string
+	Sum            int64
+}
+
+// Matches reports whether a repeated request with the same idempotency key describes the transfer.
+func (t *Transfer) Matches(info *TransferInfo) bool {
+	return t.Recipient == info.Recipient && t.ProgramID == info.ProgramID && t.Amount == info.Sum
+}
+
+// TransferRules limits transfers of a user, zero values disable the rules. Sums are in hundredths
+// of a point of the accrual system, they are converted to points of the program by its
+// conversion rate. The day is a rolling window.
+type TransferRules struct {
+	MaxPerTransfer int64
+	MaxPerDay      int64
+}
+
+// TransferStats is the sender history the transfer rules are checked against.
+type TransferStats struct {
+	Program Program
+	DaySum  int64
+}
+
+// TransferCheck is called in the transfer transaction with the locked sender history.
+type TransferCheck func(stats *TransferStats) error
@@ -3,9 +3,13 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -13,135 +17,159 @@ import (
 )
 
 const (
-	attempts = 3
-	divValue = 100
+	divValue          = 100
+	defaultRetryAfter = 60 * time.Second
+	maxErrorBodySize  = 1024
 )
 
+var (
+	ErrNotRegistered = errors.New("order is not registered in the accrual system")
+	ErrRateLimited   = errors.New("accrual system rate limit exceeded")
+	ErrUpstream      = errors.New("accrual system error")
+)
+
+// RateLimitError is returned when the accrual system asks to slow down, it matches ErrRateLimited.
+type RateLimitError struct {
+	RetryAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry at %s", ErrRateLimited, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
 type AccrualClient struct {
+	httpClient       *http.Client
+	headers          http.Header
 	log              *zap.Logger
-	retryAt          *time.Time
+	gate             *Gate
 	accrualSystemURL string
-	clientTimeout    time.Duration
 }
 
-func NewAccrualClient(accrualSystemURL string, timeout time.Duration, log *zap.Logger) *AccrualClient {
+// NewAccrualClient creates a client of the accrual system, headers are added to every request.
+// Requests are refused while the gate is paused, so one 429 response pauses every caller sharing it.
+func NewAccrualClient(accrualSystemURL string, httpClient *http.Client, headers http.Header, gate *Gate,
+	log *zap.Logger) *AccrualClient {
 	return &AccrualClient{
+		httpClient:       httpClient,
+		headers:          headers.Clone(),
 		log:              log.With(zap.String("client", "http")),
-		clientTimeout:    timeout,
-		accrualSystemURL: accrualSystemURL,
+		gate:             gate,
+		accrualSystemURL: strings.TrimRight(accrualSystemURL, "/"),
 	}
 }
 
 type OrderStatusResponse struct {
-	Status  string          `json:"status"`
-	Accrual decimal.Decimal `json:"accrual"`
+	Accrual *decimal.Decimal `json:"accrual,omitempty"`
+	Order   string           `json:"order"`
+	Status  string           `json:"status"`
 }
 
-func (ac *AccrualClient) GetOrderStatus(id string) (string, int64, error) {
-	addr := fmt.Sprintf("%s/api/orders/%s", ac.accrualSystemURL, id)
-
-	var response *http.Response
-	var err error
-
-	for i := 0; i < attempts; i++ {
-		response, err = ac.get(addr)
-		if err != nil {
-			ac.log.Warn("can't make request", zap.Error(err))
-			response.Body.Close()
-			continue
-		}
+// OrderAccrual is the order status in the accrual system, Accrual is in hundredths of a point.
+type OrderAccrual struct {
+	Number  string
+	Status  string
+	Accrual int64
+}
 
-		var after *time.Time
-		after, err = ac.canDoRequest()
-		if err != nil {
-			ac.log.Info("retry check at", zap.String("time", err.Error()))
-			time.Sleep(time.Until(*after))
-			response.Body.Close()
-			continue
-		}
-		continue
+// GetOrderStatus requests the order status once. It returns ErrNotRegistered if the accrual
+// system doesn't know the order, a *RateLimitError if requests are paused and ErrUpstream
+// for transport errors and unexpected responses.
+func (ac *AccrualClient) GetOrderStatus(ctx context.Context, number string) (*OrderAccrual, error) {
+	if until, paused := ac.gate.Until(); paused {
+		return nil, &RateLimitError{RetryAt: until}
 	}
-	defer response.Body.Close()
 
-	err = ac.checkResponse(response)
-	if err != nil {
-		ac.log.Info("check result", zap.String("result", err.Error()))
-		return "", 0, err
-	}
+	addr := fmt.Sprintf("%s/api/orders/%s", ac.accrualSystemURL, url.PathEscape(number))
 
-	body, err := io.ReadAll(response.Body)
+	response, err := ac.get(ctx, addr)
 	if err != nil {
-		ac.log.Info("can't read response body")
-		return "", 0, err
+		return nil, err
 	}
+	defer response.Body.Close()
 
-	var res *OrderStatusResponse
-	if response.StatusCode == http.StatusOK {
-		err = json.Unmarshal(body, &res)
-		if err != nil {
-			ac.log.Info("can't unmarshal json, skip order", zap.Error(err))
-			return "", 0, err
-		}
+	switch response.StatusCode {
+	case http.StatusOK:
+		return ac.decodeOrderStatus(number, response.Body)
+	case http.StatusNoContent:
+		return nil, ErrNotRegistered
+	case http.StatusTooManyRequests:
+		retryAt := time.Now().Add(parseRetryAfter(response.Header.Get("Retry-After"), time.Now()))
+		ac.gate.PauseUntil(retryAt)
+
+		ac.log.Info("rate limit exceeded, pause requests", zap.Time("retry at", retryAt))
+		return nil, &RateLimitError{RetryAt: retryAt}
 	}
-	if response.StatusCode == http.StatusNoContent {
-		ac.log.Info("no content status, skip order")
-		return "", 0, fmt.Errorf("no content in response")
-	}
-
-	ac.log.Info("order status", zap.String("status", fmt.Sprintf("%+v", res)))
 
-	accrual := res.Accrual.Mul(decimal.NewFromInt(divValue)).IntPart()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
 
-	return res.Status, accrual, nil
+	return nil, fmt.Errorf("%w: unexpected status %q: %s", ErrUpstream, response.Status,
+		strings.TrimSpace(string(body)))
 }
 
-func (ac *AccrualClient) get(url string) (*http.Response, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), ac.clientTimeout)
-	defer cancel()
-
-	ac.log.Info("new request", zap.String("url", url), zap.Duration("timeout", ac.clientTimeout))
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (ac *AccrualClient) get(ctx context.Context, addr string) (*http.Response, error) {
+	ac.log.Debug("new request", zap.String("url", addr), zap.Duration("timeout", ac.httpClient.Timeout))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	ac.log.Info("trying to do request")
-	resp, err := http.DefaultClient.Do(req)
+	for key, values := range ac.headers {
+		req.Header[key] = values
+	}
+
+	resp, err := ac.httpClient.Do(req)
 	if err != nil {
-		ac.log.Info("http client", zap.Error(err))
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrUpstream, err)
 	}
 
 	return resp, nil
 }
 
-func (ac *AccrualClient) checkResponse(resp *http.Response) error {
-	if resp.StatusCode == http.StatusTooManyRequests {
-		ac.log.Info("request", zap.String("status", resp.Status))
+func (ac *AccrualClient) decodeOrderStatus(number string, body io.Reader) (*OrderAccrual, error) {
+	var res OrderStatusResponse
 
-		retryAfter, err := time.ParseDuration(fmt.Sprintf("%ss", resp.Header.Get("Retry-After")))
-		if err != nil {
-			return err
-		}
+	err := json.NewDecoder(body).Decode(&res)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't decode response: %w", ErrUpstream, err)
+	}
+	if res.Status == "" {
+		return nil, fmt.Errorf("%w: empty order status", ErrUpstream)
+	}
 
-		*ac.retryAt = time.Now().Add(retryAfter)
+	ac.log.Debug("order status", zap.String("order", number), zap.String("status", res.Status))
+
+	orderAccrual := &OrderAccrual{
+		Number: number,
+		Status: res.Status,
+	}
+	if res.Accrual != nil {
+		orderAccrual.Accrual = res.Accrual.Mul(decimal.NewFromInt(divValue)).IntPart()
 	}
 
-	return nil
+	return orderAccrual, nil
 }
 
-func (ac *AccrualClient) canDoRequest() (*time.Time, error) {
-	if ac.retryAt == nil {
-		ac.log.Info("ok, can do request")
-		return nil, nil
+// parseRetryAfter reads the Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return defaultRetryAfter
 	}
 
-	if time.Now().After(*ac.retryAt) {
-		ac.log.Info("now time after retry time, reset retry time")
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return defaultRetryAfter
+		}
+		return time.Duration(seconds) * time.Second
+	}
 
-		ac.retryAt = nil
-		return nil, nil
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
 	}
 
-	return ac.retryAt, fmt.Errorf("can't send request in %s", time.Until(*ac.retryAt).String())
+	return defaultRetryAfter
 }
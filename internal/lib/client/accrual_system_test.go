@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+)
+
+const (
+	defaultLogLevel          = "info"
+	defaultTestClientTimeout = 3 * time.Second
+)
+
+func TestGetOrderStatus(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+
+	var requests atomic.Int32
+
+	r := chi.NewRouter()
+	r.Get("/api/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		switch chi.URLParam(r, "number") {
+		case "2377225624":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"order":"2377225624","status":"PROCESSED","accrual":729.98}`))
+		case "41632078327500":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"order":"41632078327500","status":"REGISTERED"}`))
+		case "45444541846":
+			w.WriteHeader(http.StatusNoContent)
+		case "333207722682":
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("No more than N requests per minute allowed"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	newClient := func() *AccrualClient {
+		return NewAccrualClient(ts.URL, ts.Client(), nil, NewGate(), log)
+	}
+
+	t.Run("processed order", func(t *testing.T) {
+		orderAccrual, err := newClient().GetOrderStatus(context.Background(), "2377225624")
+		require.NoError(t, err)
+		assert.Equal(t, "PROCESSED", orderAccrual.Status)
+		assert.Equal(t, int64(72998), orderAccrual.Accrual)
+	})
+
+	t.Run("order without accrual", func(t *testing.T) {
+		orderAccrual, err := newClient().GetOrderStatus(context.Background(), "41632078327500")
+		require.NoError(t, err)
+		assert.Equal(t, "REGISTERED", orderAccrual.Status)
+		assert.Equal(t, int64(0), orderAccrual.Accrual)
+	})
+
+	t.Run("not registered order", func(t *testing.T) {
+		_, err := newClient().GetOrderStatus(context.Background(), "45444541846")
+		assert.ErrorIs(t, err, ErrNotRegistered)
+	})
+
+	t.Run("upstream error", func(t *testing.T) {
+		_, err := newClient().GetOrderStatus(context.Background(), "836361")
+		assert.ErrorIs(t, err, ErrUpstream)
+	})
+
+	t.Run("rate limit pauses the shared gate", func(t *testing.T) {
+		gate := NewGate()
+		first := NewAccrualClient(ts.URL, ts.Client(), nil, gate, log)
+		second := NewAccrualClient(ts.URL, ts.Client(), nil, gate, log)
+
+		_, err := first.GetOrderStatus(context.Background(), "333207722682")
+		assert.ErrorIs(t, err, ErrRateLimited)
+
+		before := requests.Load()
+
+		_, err = second.GetOrderStatus(context.Background(), "2377225624")
+		var rateLimit *RateLimitError
+		require.ErrorAs(t, err, &rateLimit)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), rateLimit.RetryAt, 5*time.Second)
+		assert.Equal(t, before, requests.Load())
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := newClient().GetOrderStatus(ctx, "2377225624")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.February, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("seconds", func(t *testing.T) {
+		assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	})
+
+	t.Run("http date", func(t *testing.T) {
+		assert.Equal(t, 90*time.Second, parseRetryAfter("Sat, 10 Feb 2024 12:01:30 GMT", now))
+	})
+
+	t.Run("date in the past", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), parseRetryAfter("Sat, 10 Feb 2024 11:00:00 GMT", now))
+	})
+
+	t.Run("empty or invalid", func(t *testing.T) {
+		assert.Equal(t, defaultRetryAfter, parseRetryAfter("", now))
+		assert.Equal(t, defaultRetryAfter, parseRetryAfter("soon", now))
+		assert.Equal(t, defaultRetryAfter, parseRetryAfter("-1", now))
+	})
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// Gate is a thread-safe "pause until" state shared by all requests to the accrual system.
+type Gate struct {
+	until time.Time
+	mu    sync.RWMutex
+}
+
+func NewGate() *Gate {
+	return &Gate{}
+}
+
+// PauseUntil stops requests until the given time, a longer pause is never shortened.
+func (g *Gate) PauseUntil(until time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if until.After(g.until) {
+		g.until = until
+	}
+}
+
+// Until returns the end of the current pause and false if requests are allowed.
+func (g *Gate) Until() (time.Time, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.until, time.Now().Before(g.until)
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+
+	var authorization string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"order":"2377225624","status":"PROCESSING"}`))
+	}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ts.Certificate().Raw,
+	}), 0o600)
+	require.NoError(t, err)
+
+	t.Run("custom CA bundle and headers", func(t *testing.T) {
+		httpClient, err := NewHTTPClient(TransportConfig{
+			CAFile:  caFile,
+			Timeout: defaultTestClientTimeout,
+		})
+		require.NoError(t, err)
+
+		headers := http.Header{}
+		headers.Set("Authorization", "Bearer secret")
+
+		ac := NewAccrualClient(ts.URL, httpClient, headers, NewGate(), log)
+		orderAccrual, err := ac.GetOrderStatus(context.Background(), "2377225624")
+		require.NoError(t, err)
+		assert.Equal(t, "PROCESSING", orderAccrual.Status)
+		assert.Equal(t, "Bearer secret", authorization)
+	})
+
+	t.Run("unknown CA", func(t *testing.T) {
+		httpClient, err := NewHTTPClient(TransportConfig{Timeout: defaultTestClientTimeout})
+		require.NoError(t, err)
+
+		ac := NewAccrualClient(ts.URL, httpClient, nil, NewGate(), log)
+		_, err = ac.GetOrderStatus(context.Background(), "2377225624")
+		assert.ErrorIs(t, err, ErrUpstream)
+	})
+
+	t.Run("missing CA bundle", func(t *testing.T) {
+		_, err := NewHTTPClient(TransportConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+		assert.Error(t, err)
+	})
+}
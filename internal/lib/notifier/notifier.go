@@ -0,0 +1,100 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+const (
+	SinkLog        = "log"
+	sinkFilePrefix = "file:"
+)
+
+// New returns the notifier of the sink, "log" or "file:<path>". Both sinks expose the tokens
+// and are meant for local use only.
+func New(sink string, log *zap.Logger) (PasswordResetNotifier, error) {
+	switch {
+	case sink == SinkLog:
+		return NewLogNotifier(log), nil
+	case strings.HasPrefix(sink, sinkFilePrefix) && len(sink) > len(sinkFilePrefix):
+		return NewFileNotifier(strings.TrimPrefix(sink, sinkFilePrefix)), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier sink %q, use %q or %q", sink, SinkLog, sinkFilePrefix+"<path>")
+	}
+}
+
+type PasswordResetNotifier interface {
+	NotifyPasswordReset(ctx context.Context, reset *entity.PasswordReset) error
+}
+
+// LogNotifier writes password reset tokens to the log.
+type LogNotifier struct {
+	log *zap.Logger
+}
+
+func NewLogNotifier(log *zap.Logger) *LogNotifier {
+	return &LogNotifier{
+		log: log.With(zap.String("notifier", "log")),
+	}
+}
+
+func (n *LogNotifier) NotifyPasswordReset(_ context.Context, reset *entity.PasswordReset) error {
+	n.log.Info("password reset requested", zap.String("username", reset.Username),
+		zap.String("token", reset.Token), zap.Time("expires_at", reset.ExpiresAt))
+
+	return nil
+}
+
+// FileNotifier appends password reset tokens to the file as JSON lines.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{
+		path: path,
+	}
+}
+
+type passwordResetLine struct {
+	Username  string `json:"username"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+func (n *FileNotifier) NotifyPasswordReset(_ context.Context, reset *entity.PasswordReset) error {
+	line, err := json.Marshal(passwordResetLine{
+		Username:  reset.Username,
+		Token:     reset.Token,
+		ExpiresAt: reset.ExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("can't open notifier file: %w", err)
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("can't write notifier file: %w", err)
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestNew(t *testing.T) {
+	n, err := New("log", zap.NewNop())
+	require.NoError(t, err)
+	assert.IsType(t, &LogNotifier{}, n)
+
+	n, err = New("file:/tmp/resets.jsonl", zap.NewNop())
+	require.NoError(t, err)
+	assert.IsType(t, &FileNotifier{}, n)
+
+	for _, sink := range []string{"", "file:", "smtp://localhost"} {
+		_, err = New(sink, zap.NewNop())
+		assert.Error(t, err, sink)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resets.jsonl")
+	n := NewFileNotifier(path)
+
+	expiresAt := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, token := range []string{"first", "second"} {
+		require.NoError(t, n.NotifyPasswordReset(context.Background(), &entity.PasswordReset{
+			ExpiresAt: expiresAt,
+			Username:  "gopher",
+			Token:     token,
+		}))
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"username":"gopher","token":"second","expires_at":"2024-02-01T10:00:00Z"}`, lines[1])
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
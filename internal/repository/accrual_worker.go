@@ -23,7 +23,10 @@ func NewAccrualWorkerRepository(db *postgres.DB) *AccrualWorkerRepository {
 	}
 }
 
-func (r *AccrualWorkerRepository) GetOrdersToProcess(ctx context.Context, count int) ([]entity.Order, error) {
+// GetOrdersToProcess leases new and processing orders to the worker. Rows locked by another
+// transaction are skipped, orders with an expired lease are claimed again.
+func (r *AccrualWorkerRepository) GetOrdersToProcess(ctx context.Context, claim *entity.ClaimInfo) ([]entity.Order,
+	error) {
 	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
 		return nil, err
@@ -35,12 +38,12 @@ func (r *AccrualWorkerRepository) GetOrdersToProcess(ctx context.Context, count
 		}
 	}(tx)
 
-	newOrders, err := r.getNewOrders(ctx, tx, count)
+	newOrders, err := r.getNewOrders(ctx, tx, claim.Count)
 	if err != nil {
 		return nil, err
 	}
 
-	toProcess, err := r.updateOrderStatus(ctx, tx, newOrders)
+	toProcess, err := r.updateOrderStatus(ctx, tx, claim, newOrders)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +58,8 @@ func (r *AccrualWorkerRepository) GetOrdersToProcess(ctx context.Context, count
 
 func (r *AccrualWorkerRepository) getNewOrders(ctx context.Context, tx pgx.Tx, count int) ([]entity.Order, error) {
 	querySelect := r.db.Builder.
-		Select("id, user_id, number, status, accrual, created_at, updated_at, deleted_at").
+		Select("id, user_id, number, status, accrual, attempts, next_attempt_at, last_error",
+			"created_at, updated_at, deleted_at").
 		From("orders").
 		Where(sq.Or{
 			sq.Eq{
@@ -65,8 +69,16 @@ func (r *AccrualWorkerRepository) getNewOrders(ctx context.Context, tx pgx.Tx, c
 				"status": entity.StatusProcessing.String(),
 			},
 		}).
-		OrderBy("created_at ASC").
-		Limit(uint64(count))
+		Where(sq.Or{
+			sq.Eq{
+				"locked_until": nil,
+			},
+			sq.Expr("locked_until < now()"),
+		}).
+		Where(sq.Expr("next_attempt_at <= now()")).
+		OrderBy("next_attempt_at ASC", "created_at ASC").
+		Limit(uint64(count)).
+		Suffix("FOR UPDATE SKIP LOCKED")
 
 	sql, args, err := querySelect.ToSql()
 	if err != nil {
@@ -90,6 +102,9 @@ func (r *AccrualWorkerRepository) getNewOrders(ctx context.Context, tx pgx.Tx, c
 			&order.Number,
 			&order.Status,
 			&order.Accrual,
+			&order.Attempts,
+			&order.NextAttemptAt,
+			&order.LastError,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 			&order.DeletedAt,
@@ -107,14 +122,16 @@ func (r *AccrualWorkerRepository) getNewOrders(ctx context.Context, tx pgx.Tx, c
 	return repoEntity.ToOrdersFromRepo(orders), nil
 }
 
-func (r *AccrualWorkerRepository) updateOrderStatus(ctx context.Context, tx pgx.Tx,
+func (r *AccrualWorkerRepository) updateOrderStatus(ctx context.Context, tx pgx.Tx, claim *entity.ClaimInfo,
 	orders []entity.Order) ([]entity.Order, error) {
 	queryUpdate := r.db.Builder.
 		Update("orders").
 		SetMap(
 			sq.Eq{
-				"status":     entity.StatusProcessing.String(),
-				"updated_at": time.Now(),
+				"status":       entity.StatusProcessing.String(),
+				"locked_by":    claim.WorkerID,
+				"locked_until": sq.Expr("now() + make_interval(secs => ?)", claim.Lease.Seconds()),
+				"updated_at":   time.Now(),
 			},
 		)
 
@@ -125,7 +142,8 @@ func (r *AccrualWorkerRepository) updateOrderStatus(ctx context.Context, tx pgx.
 
 	queryUpdate = queryUpdate.
 		Where(ordersToUpdate).
-		Suffix("RETURNING id, user_id, number, status, accrual, created_at, updated_at, deleted_at")
+		Suffix("RETURNING id, user_id, number, status, accrual, attempts, next_attempt_at, last_error, " +
+			"created_at, updated_at, deleted_at")
 
 	sql, args, err := queryUpdate.ToSql()
 	if err != nil {
@@ -149,6 +167,9 @@ func (r *AccrualWorkerRepository) updateOrderStatus(ctx context.Context, tx pgx.
 			&order.Number,
 			&order.Status,
 			&order.Accrual,
+			&order.Attempts,
+			&order.NextAttemptAt,
+			&order.LastError,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 			&order.DeletedAt,
@@ -159,13 +180,22 @@ func (r *AccrualWorkerRepository) updateOrderStatus(ctx context.Context, tx pgx.
 
 		updatedOrders = append(updatedOrders, order)
 	}
-	if len(orders) == 0 {
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(updatedOrders) == 0 {
 		return nil, entity.ErrNoOrdersFound
 	}
 
 	return repoEntity.ToOrdersFromRepo(updatedOrders), nil
 }
 
+// UpdateOrderAndUserBalance saves the final order status and credits the accrual to the user
+// with a ledger entry. The accrual of the order is in hundredths of a point of the accrual
+// system, it is converted to the points of the order program.
+// Only an order with a non-final status is updated and the accrual is marked as applied in the
+// same transaction, so applying the same result twice returns entity.ErrOrderAlreadyFinal
+// instead of crediting the user again.
 func (r *AccrualWorkerRepository) UpdateOrderAndUserBalance(ctx context.Context, order entity.Order) error {
 	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
@@ -178,15 +208,29 @@ func (r *AccrualWorkerRepository) UpdateOrderAndUserBalance(ctx context.Context,
 		}
 	}(tx)
 
+	program, err := r.getOrderProgram(ctx, tx, order.ID)
+	if err != nil {
+		return err
+	}
+
 	queryUpdateOrders := r.db.Builder.Update("orders").
 		SetMap(sq.Eq{
-			"accrual": order.Accrual,
-			"status":  order.Status,
+			"accrual":            program.Convert(order.Accrual),
+			"status":             order.Status.String(),
+			"accrual_status":     order.AccrualStatus,
+			"accrual_checked_at": order.CheckedAt,
+			"last_error":         nil,
+			"locked_by":          nil,
+			"locked_until":       nil,
+			"accrual_applied_at": sq.Expr("now()"),
+			"updated_at":         time.Now(),
 		}).
 		Where(sq.Eq{
-			"id": order.ID,
+			"id":                 order.ID,
+			"status":             []string{entity.StatusNew.String(), entity.StatusProcessing.String()},
+			"accrual_applied_at": nil,
 		}).
-		Suffix("RETURNING id, user_id, number, status, accrual, created_at, updated_at, deleted_at")
+		Suffix("RETURNING id, user_id, program_id, number, status, accrual, created_at, updated_at, deleted_at")
 
 	sqlUpdate, args, err := queryUpdateOrders.ToSql()
 	if err != nil {
@@ -195,11 +239,12 @@ func (r *AccrualWorkerRepository) UpdateOrderAndUserBalance(ctx context.Context,
 
 	rowUpdate := tx.QueryRow(ctx, sqlUpdate, args...)
 
-	var updateOrderResult entity.Order
+	var updateOrderResult repoEntity.Order
 
 	err = rowUpdate.Scan(
 		&updateOrderResult.ID,
 		&updateOrderResult.UserID,
+		&updateOrderResult.ProgramID,
 		&updateOrderResult.Number,
 		&updateOrderResult.Status,
 		&updateOrderResult.Accrual,
@@ -208,47 +253,214 @@ func (r *AccrualWorkerRepository) UpdateOrderAndUserBalance(ctx context.Context,
 		&updateOrderResult.DeletedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return err
+		return entity.ErrOrderAlreadyFinal
 	}
 	if err != nil {
 		return errors.Join(err, entity.ErrCanNotUpdateOrder)
 	}
 
-	queryUpdateBalance := r.db.Builder.
-		Update("users").
+	if updateOrderResult.Accrual > 0 {
+		err = insertLedgerEntry(ctx, tx, r.db.Builder, &entity.LedgerEntry{
+			UserID:      updateOrderResult.UserID,
+			ProgramID:   updateOrderResult.ProgramID,
+			OrderNumber: updateOrderResult.Number,
+			Kind:        entity.EntryAccrual,
+			Amount:      updateOrderResult.Accrual,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// getOrderProgram returns the loyalty program the order accrual is credited to.
+func (r *AccrualWorkerRepository) getOrderProgram(ctx context.Context, tx pgx.Tx, orderID string) (*entity.Program,
+	error) {
+	program := &repoEntity.Program{}
+
+	query := r.db.Builder.
+		Select("programs.id, programs.name, programs.decimal_places, programs.conversion_rate, programs.created_at").
+		From("programs").
+		Join("orders ON orders.program_id = programs.id").
+		Where(sq.Eq{
+			"orders.id": orderID,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(
+		&program.ID,
+		&program.Name,
+		&program.Precision,
+		&program.ConversionRate,
+		&program.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, entity.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return repoEntity.ToProgramFromRepo(program), nil
+}
+
+// RescheduleOrder returns the lease on an order that is not final yet and sets the time
+// of the next attempt, the order will not be claimed before it.
+func (r *AccrualWorkerRepository) RescheduleOrder(ctx context.Context, retry *entity.RetryInfo) error {
+	var lastError *string
+	if retry.LastError != "" {
+		lastError = &retry.LastError
+	}
+
+	values := sq.Eq{
+		"attempts":        retry.Attempts,
+		"next_attempt_at": retry.NextAttemptAt,
+		"last_error":      lastError,
+		"locked_by":       nil,
+		"locked_until":    nil,
+		"updated_at":      time.Now(),
+	}
+	if retry.AccrualStatus != "" {
+		values["accrual_status"] = retry.AccrualStatus
+		values["accrual_checked_at"] = retry.CheckedAt
+		values["expected_accrual"] = retry.ExpectedAccrual
+	}
+
+	query := r.db.Builder.
+		Update("orders").
+		SetMap(values).
+		Where(sq.Eq{
+			"id":        retry.OrderID,
+			"locked_by": retry.WorkerID,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FailOrder moves the leased order to the final INVALID status, it is never polled again.
+func (r *AccrualWorkerRepository) FailOrder(ctx context.Context, workerID string, order entity.Order,
+	reason string) error {
+	query := r.db.Builder.
+		Update("orders").
 		SetMap(sq.Eq{
-			"current_balance": sq.Expr("current_balance + ?", order.Accrual),
+			"status":       entity.StatusInvalid.String(),
+			"last_error":   reason,
+			"locked_by":    nil,
+			"locked_until": nil,
+			"updated_at":   time.Now(),
 		}).
 		Where(sq.Eq{
-			"id": updateOrderResult.UserID,
-		}).
-		Suffix("RETURNING id, current_balance, username")
+			"id":        order.ID,
+			"locked_by": workerID,
+		})
 
-	sqlUpdate, args, err = queryUpdateBalance.ToSql()
+	sql, args, err := query.ToSql()
 	if err != nil {
 		return err
 	}
 
-	rowUpdate = tx.QueryRow(ctx, sqlUpdate, args...)
+	_, err = r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
 
-	var updateUserBalanceResult entity.User
+	return nil
+}
 
-	err = rowUpdate.Scan(
-		&updateUserBalanceResult.ID,
-		&updateUserBalanceResult.Balance,
-		&updateUserBalanceResult.Username,
+func (r *AccrualWorkerRepository) GetOrderByNumber(ctx context.Context, number string) (*entity.Order, error) {
+	order := &repoEntity.Order{}
+
+	query := r.db.Builder.
+		Select("id, user_id, number, status, accrual, accrual_status, accrual_checked_at",
+			"created_at, updated_at, deleted_at").
+		From("orders").
+		Where(sq.Eq{
+			"number": number,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	row := r.db.Pool.QueryRow(ctx, sql, args...)
+
+	err = row.Scan(
+		&order.ID,
+		&order.UserID,
+		&order.Number,
+		&order.Status,
+		&order.Accrual,
+		&order.AccrualStatus,
+		&order.CheckedAt,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+		&order.DeletedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return err
+		return nil, entity.ErrOrderNotFound
 	}
 	if err != nil {
-		return errors.Join(err, entity.ErrCanNotUpdateUserBalance)
+		return nil, err
 	}
 
-	err = tx.Commit(ctx)
+	return repoEntity.ToOrderFromRepo(order), nil
+}
+
+// UpdateAccrualStatus saves the intermediate accrual system status of the order and
+// postpones its next poll.
+func (r *AccrualWorkerRepository) UpdateAccrualStatus(ctx context.Context, order entity.Order,
+	nextAttemptAt time.Time) error {
+	query := r.db.Builder.
+		Update("orders").
+		SetMap(sq.Eq{
+			"status":             entity.StatusProcessing.String(),
+			"accrual_status":     order.AccrualStatus,
+			"accrual_checked_at": order.CheckedAt,
+			"expected_accrual":   order.ExpectedAccrual,
+			"next_attempt_at":    nextAttemptAt,
+			"updated_at":         time.Now(),
+		}).
+		Where(sq.Eq{
+			"id": order.ID,
+		}).
+		Where(sq.NotEq{
+			"status": []string{entity.StatusInvalid.String(), entity.StatusProcessed.String()},
+		})
+
+	sql, args, err := query.ToSql()
 	if err != nil {
 		return err
 	}
 
+	tag, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrOrderAlreadyFinal
+	}
+
 	return nil
 }
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestUpdateOrderAndUserBalanceConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "user-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+		ID:     uuid.NewString(),
+		UserID: user.ID,
+		Number: uuid.NewString(),
+	})
+	require.NoError(t, err)
+
+	checkedAt := time.Now()
+	order.Status = entity.StatusProcessed
+	order.AccrualStatus = entity.StatusProcessed.String()
+	order.CheckedAt = &checkedAt
+	order.Accrual = 72998
+
+	repo := NewAccrualWorkerRepository(db)
+
+	const appliers = 10
+
+	var wg sync.WaitGroup
+	errs := make([]error, appliers)
+	for i := 0; i < appliers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = repo.UpdateOrderAndUserBalance(ctx, *order)
+		}(i)
+	}
+	wg.Wait()
+
+	var applied int
+	for _, err := range errs {
+		if err == nil {
+			applied++
+			continue
+		}
+		assert.ErrorIs(t, err, entity.ErrOrderAlreadyFinal)
+	}
+	assert.Equal(t, 1, applied)
+
+	balance, err := NewBalanceRepository(db).GetUserBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(72998), balance.Balance)
+}
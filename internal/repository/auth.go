@@ -77,14 +77,182 @@ func (r *AuthRepository) AddUser(ctx context.Context, userInfo *entity.UserInfo)
 }
 
 func (r *AuthRepository) FindUser(ctx context.Context, username string) (*entity.User, error) {
+	return r.findUser(ctx, sq.Eq{"username": username})
+}
+
+func (r *AuthRepository) FindUserByID(ctx context.Context, userID string) (*entity.User, error) {
+	return r.findUser(ctx, sq.Eq{"id": userID})
+}
+
+// UpdatePassword saves the new password hash and revokes all sessions of the user.
+func (r *AuthRepository) UpdatePassword(ctx context.Context, userID, hash string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(tx pgx.Tx) {
+		err = tx.Rollback(ctx)
+		if errors.Is(err, pgx.ErrTxClosed) {
+			return
+		}
+	}(tx)
+
+	err = r.updatePassword(ctx, tx, userID, hash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RehashPassword replaces the hash of the same password created with outdated params, sessions
+// are kept. Nothing is updated if the password was changed since oldHash was read.
+func (r *AuthRepository) RehashPassword(ctx context.Context, userID, oldHash, newHash string) error {
+	query := r.db.Builder.
+		Update("users").
+		Set("password_hash", newHash).
+		Where(sq.Eq{
+			"id":            userID,
+			"password_hash": oldHash,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Pool.Exec(ctx, sql, args...)
+
+	return err
+}
+
+// AddPasswordReset saves the hash of the reset token.
+func (r *AuthRepository) AddPasswordReset(ctx context.Context, reset *entity.PasswordReset) error {
+	query := r.db.Builder.
+		Insert("password_resets").
+		Columns("token_hash, user_id, expires_at").
+		Values(reset.TokenHash, reset.UserID, reset.ExpiresAt)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Pool.Exec(ctx, sql, args...)
+
+	return err
+}
+
+// FindPasswordReset returns the user of the unused and not expired reset token.
+func (r *AuthRepository) FindPasswordReset(ctx context.Context, tokenHash string) (*entity.User, error) {
+	user, err := r.findUser(ctx, sq.Expr("id = (SELECT user_id FROM password_resets "+
+		"WHERE token_hash = ? AND used_at IS NULL AND expires_at > now())", tokenHash))
+	if errors.Is(err, entity.ErrUsernameNotFound) {
+		return nil, entity.ErrInvalidResetToken
+	}
+
+	return user, err
+}
+
+// ResetPassword uses the reset token to save the new password hash. All reset tokens and
+// sessions of the user are revoked.
+func (r *AuthRepository) ResetPassword(ctx context.Context, tokenHash, hash string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(tx pgx.Tx) {
+		err = tx.Rollback(ctx)
+		if errors.Is(err, pgx.ErrTxClosed) {
+			return
+		}
+	}(tx)
+
+	queryUseToken := r.db.Builder.
+		Update("password_resets").
+		Set("used_at", sq.Expr("now()")).
+		Where(sq.Eq{
+			"token_hash": tokenHash,
+			"used_at":    nil,
+		}).
+		Where(sq.Expr("expires_at > now()")).
+		Suffix("RETURNING user_id")
+
+	sql, args, err := queryUseToken.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var userID string
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entity.ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	queryRevokeTokens := r.db.Builder.
+		Update("password_resets").
+		Set("used_at", sq.Expr("now()")).
+		Where(sq.Eq{
+			"user_id": userID,
+			"used_at": nil,
+		})
+
+	sql, args, err = queryRevokeTokens.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	err = r.updatePassword(ctx, tx, userID, hash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *AuthRepository) updatePassword(ctx context.Context, tx pgx.Tx, userID, hash string) error {
+	query := r.db.Builder.
+		Update("users").
+		Set("password_hash", hash).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{
+			"id": userID,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrUsernameNotFound
+	}
+
+	_, err = revokeSessions(ctx, tx, r.db.Builder, sq.Eq{"user_id": userID})
+
+	return err
+}
+
+func (r *AuthRepository) findUser(ctx context.Context, where sq.Sqlizer) (*entity.User, error) {
 	user := &repoEntity.User{}
 
 	query := r.db.Builder.
 		Select("id, username, password_hash, created_at, updated_at, deleted_at").
 		From("users").
-		Where(sq.Eq{
-			"username": username,
-		})
+		Where(where)
 
 	sql, args, err := query.ToSql()
 	if err != nil {
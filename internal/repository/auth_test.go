@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestPasswordReset(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	authRepo := NewAuthRepository(db)
+	sessionRepo := NewSessionRepository(db)
+
+	user, err := authRepo.AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "reset-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	session, err := sessionRepo.AddSession(ctx, &entity.SessionInfo{
+		ExpiresAt:        time.Now().Add(time.Hour),
+		ID:               uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: uuid.NewString(),
+	})
+	require.NoError(t, err)
+
+	tokenHash, anotherTokenHash := uuid.NewString(), uuid.NewString()
+	for _, hash := range []string{tokenHash, anotherTokenHash} {
+		require.NoError(t, authRepo.AddPasswordReset(ctx, &entity.PasswordReset{
+			ExpiresAt: time.Now().Add(time.Hour),
+			UserID:    user.ID,
+			TokenHash: hash,
+		}))
+	}
+
+	found, err := authRepo.FindPasswordReset(ctx, tokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	require.NoError(t, authRepo.ResetPassword(ctx, tokenHash, "new hash"))
+
+	updated, err := authRepo.FindUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new hash", updated.Hash)
+
+	active, err := sessionRepo.IsSessionActive(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, active, "sessions must be revoked")
+
+	for _, hash := range []string{tokenHash, anotherTokenHash} {
+		assert.ErrorIs(t, authRepo.ResetPassword(ctx, hash, "another hash"), entity.ErrInvalidResetToken)
+
+		_, err = authRepo.FindPasswordReset(ctx, hash)
+		assert.ErrorIs(t, err, entity.ErrInvalidResetToken)
+	}
+}
+
+func TestRehashPassword(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	authRepo := NewAuthRepository(db)
+	sessionRepo := NewSessionRepository(db)
+
+	user, err := authRepo.AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "rehash-" + uuid.NewString(),
+		Hash:     "old hash",
+	})
+	require.NoError(t, err)
+
+	session, err := sessionRepo.AddSession(ctx, &entity.SessionInfo{
+		ExpiresAt:        time.Now().Add(time.Hour),
+		ID:               uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: uuid.NewString(),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, authRepo.RehashPassword(ctx, user.ID, "old hash", "new hash"))
+
+	updated, err := authRepo.FindUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new hash", updated.Hash)
+
+	active, err := sessionRepo.IsSessionActive(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, active, "sessions must be kept")
+
+	require.NoError(t, authRepo.RehashPassword(ctx, user.ID, "old hash", "stale hash"))
+
+	updated, err = authRepo.FindUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new hash", updated.Hash, "changed password must not be overwritten")
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgerrcode"
@@ -24,38 +25,139 @@ func NewBalanceRepository(db *postgres.DB) *BalanceRepository {
 	}
 }
 
+// GetUserBalance derives the balance and the withdrawn sum of every loyalty program from the ledger,
+// reversed withdrawals are not counted as withdrawn.
 func (r *BalanceRepository) GetUserBalance(ctx context.Context, userID string) (*entity.Balance, error) {
-	userBalance := &repoEntity.Balance{}
-
 	query := r.db.Builder.
-		Select("users.id, users.current_balance, COALESCE(SUM(withdrawals.withdrawn), 0)").
-		From("users").
-		LeftJoin("withdrawals ON withdrawals.user_id = users.id").
+		Select("programs.id, programs.name, programs.decimal_places, programs.conversion_rate",
+			"programs.created_at",
+			"COALESCE(SUM(ledger_entries.amount), 0)",
+			"COALESCE(-SUM(ledger_entries.amount) "+
+				"FILTER (WHERE ledger_entries.kind IN ('withdrawal', 'reversal')), 0)").
+		From("programs").
+		LeftJoin("ledger_entries ON ledger_entries.program_id = programs.id AND ledger_entries.user_id = ?",
+			userID).
+		GroupBy("programs.id").
+		OrderBy("programs.created_at", "programs.id")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := make([]repoEntity.ProgramBalance, 0)
+
+	for rows.Next() {
+		programBalance := repoEntity.ProgramBalance{}
+
+		err = rows.Scan(
+			&programBalance.ID,
+			&programBalance.Name,
+			&programBalance.Precision,
+			&programBalance.ConversionRate,
+			&programBalance.CreatedAt,
+			&programBalance.Balance,
+			&programBalance.Withdrawn,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		programs = append(programs, programBalance)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return repoEntity.ToUserBalanceFromRepo(userID, programs), nil
+}
+
+func (r *BalanceRepository) GetProgram(ctx context.Context, programID string) (*entity.Program, error) {
+	return getProgram(ctx, r.db.Pool, r.db.Builder, programID)
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func getProgram(ctx context.Context, db queryRower, builder sq.StatementBuilderType,
+	programID string) (*entity.Program, error) {
+	program := &repoEntity.Program{}
+
+	query := builder.
+		Select("id, name, decimal_places, conversion_rate, created_at").
+		From("programs").
 		Where(sq.Eq{
-			"users.id": userID,
-		}).
-		GroupBy("users.id")
+			"id": programID,
+		})
 
 	sql, args, err := query.ToSql()
 	if err != nil {
 		return nil, err
 	}
 
-	row := r.db.Pool.QueryRow(ctx, sql, args...)
+	err = db.QueryRow(ctx, sql, args...).Scan(
+		&program.ID,
+		&program.Name,
+		&program.Precision,
+		&program.ConversionRate,
+		&program.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, entity.ErrProgramNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return repoEntity.ToProgramFromRepo(program), nil
+}
+
+// AddProgram creates a loyalty program. The precision of a program can't be changed later,
+// its amounts are already stored in the smallest units.
+func (r *BalanceRepository) AddProgram(ctx context.Context, program *entity.Program) (*entity.Program, error) {
+	created := &repoEntity.Program{}
+
+	query := r.db.Builder.
+		Insert("programs").
+		Columns("id, name, decimal_places, conversion_rate").
+		Values(program.ID, program.Name, program.Precision, program.ConversionRate).
+		Suffix("RETURNING id, name, decimal_places, conversion_rate, created_at")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
 
-	err = row.Scan(
-		&userBalance.ID,
-		&userBalance.Balance,
-		&userBalance.Withdrawn,
+	err = r.db.Pool.QueryRow(ctx, sql, args...).Scan(
+		&created.ID,
+		&created.Name,
+		&created.Precision,
+		&created.ConversionRate,
+		&created.CreatedAt,
 	)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return nil, entity.ErrProgramUniqueViolation
+		}
 		return nil, err
 	}
 
-	return repoEntity.ToUserBalanceFromRepo(userBalance), nil
+	return repoEntity.ToProgramFromRepo(created), nil
 }
 
-func (r *BalanceRepository) AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo) error {
+// AddWithdrawal locks the user and calls check with the user withdrawal history before
+// the withdrawal is added, so concurrent withdrawals can't bypass the rules. The check is
+// skipped if it is nil.
+func (r *BalanceRepository) AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo,
+	check entity.WithdrawalCheck) error {
 	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
 		return err
@@ -67,36 +169,40 @@ func (r *BalanceRepository) AddWithdrawal(ctx context.Context, withdrawInfo *ent
 		}
 	}(tx)
 
-	queryUpdateBalance := r.db.Builder.
-		Update("users").
-		SetMap(sq.Eq{
-			"current_balance": sq.Expr("current_balance - ?", withdrawInfo.Sum),
-		}).
-		Where(sq.Eq{
-			"id": withdrawInfo.UserID,
-		})
-
-	sql, args, err := queryUpdateBalance.ToSql()
-	if err != nil {
-		return err
+	if withdrawInfo.ProgramID == "" {
+		withdrawInfo.ProgramID = entity.DefaultProgramID
 	}
 
-	_, err = tx.Exec(ctx, sql, args...)
+	if check != nil {
+		stats, err := r.getWithdrawalStats(ctx, tx, withdrawInfo.UserID, withdrawInfo.ProgramID)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
-			return entity.ErrNotEnoughPointsToWithdraw
+		err = check(stats)
+		if err != nil {
+			return err
 		}
+	}
+
+	err = insertLedgerEntry(ctx, tx, r.db.Builder, &entity.LedgerEntry{
+		UserID:      withdrawInfo.UserID,
+		ProgramID:   withdrawInfo.ProgramID,
+		OrderNumber: withdrawInfo.OrderNumber,
+		Kind:        entity.EntryWithdrawal,
+		Amount:      -withdrawInfo.Sum,
+	})
+	if err != nil {
 		return err
 	}
 
 	queryNewWithdrawal := r.db.Builder.
 		Insert("withdrawals").
-		Columns("id, user_id, order_number, withdrawn").
-		Values(withdrawInfo.ID, withdrawInfo.UserID, withdrawInfo.OrderNumber, withdrawInfo.Sum)
+		Columns("id, user_id, program_id, order_number, withdrawn").
+		Values(withdrawInfo.ID, withdrawInfo.UserID, withdrawInfo.ProgramID, withdrawInfo.OrderNumber,
+			withdrawInfo.Sum)
 
-	sql, args, err = queryNewWithdrawal.ToSql()
+	sql, args, err := queryNewWithdrawal.ToSql()
 	if err != nil {
 		return err
 	}
@@ -114,15 +220,74 @@ func (r *BalanceRepository) AddWithdrawal(ctx context.Context, withdrawInfo *ent
 	return nil
 }
 
-func (r *BalanceRepository) GetWithdrawals(ctx context.Context, userID string) ([]entity.Withdraw, error) {
-	query := r.db.Builder.
-		Select("id, user_id, order_number, withdrawn, created_at, updated_at, deleted_at").
+// getWithdrawalStats locks the user row until the end of the transaction and sums
+// the withdrawals of the program that are not reversed within the last day and week.
+// Withdrawals within the last hour are counted in all programs.
+func (r *BalanceRepository) getWithdrawalStats(ctx context.Context, tx pgx.Tx,
+	userID, programID string) (*entity.WithdrawalStats, error) {
+	program, err := getProgram(ctx, tx, r.db.Builder, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &entity.WithdrawalStats{Program: *program}
+
+	queryUser := r.db.Builder.
+		Select("created_at, now()").
+		From("users").
+		Where(sq.Eq{"id": userID}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := queryUser.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&stats.UserCreatedAt, &stats.Now)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, entity.ErrUsernameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	queryStats := r.db.Builder.
+		Select().
+		Column(sq.Expr("COALESCE(SUM(withdrawn) "+
+			"FILTER (WHERE created_at > now() - interval '1 day' AND program_id = ?), 0)", programID)).
+		Column(sq.Expr("COALESCE(SUM(withdrawn) FILTER (WHERE program_id = ?), 0)", programID)).
+		Column("COUNT(*) FILTER (WHERE created_at > now() - interval '1 hour')").
 		From("withdrawals").
 		Where(sq.Eq{
 			"user_id": userID,
+			"status":  entity.WithdrawStatusWithdrawn,
 		}).
-		OrderBy("created_at ASC").
-		Limit(DefaultEntityCap)
+		Where("created_at > now() - interval '7 days'")
+
+	sql, args, err = queryStats.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&stats.DaySum, &stats.WeekSum, &stats.HourCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (r *BalanceRepository) GetWithdrawals(ctx context.Context, filter *entity.PageFilter) ([]entity.Withdraw,
+	error) {
+	query := r.db.Builder.
+		Select("id, user_id, program_id, order_number, withdrawn, status, reversed_at, reversal_reason",
+			"created_at, updated_at, deleted_at", programPrecision("withdrawals")).
+		From("withdrawals").
+		Where(sq.Eq{
+			"user_id": filter.UserID,
+		})
+
+	query = withPage(query, *filter)
 
 	sql, args, err := query.ToSql()
 	if err != nil {
@@ -135,7 +300,7 @@ func (r *BalanceRepository) GetWithdrawals(ctx context.Context, userID string) (
 	}
 	defer rows.Close()
 
-	withdrawals := make([]repoEntity.Withdraw, 0, DefaultEntityCap)
+	withdrawals := make([]repoEntity.Withdraw, 0, filter.Limit)
 
 	for rows.Next() {
 		withdraw := repoEntity.Withdraw{}
@@ -143,11 +308,16 @@ func (r *BalanceRepository) GetWithdrawals(ctx context.Context, userID string) (
 		err = rows.Scan(
 			&withdraw.ID,
 			&withdraw.UserID,
+			&withdraw.ProgramID,
 			&withdraw.OrderNumber,
 			&withdraw.Withdrawn,
+			&withdraw.Status,
+			&withdraw.ReversedAt,
+			&withdraw.ReversalReason,
 			&withdraw.CreatedAt,
 			&withdraw.UpdatedAt,
 			&withdraw.DeletedAt,
+			&withdraw.Precision,
 		)
 		if err != nil {
 			return nil, err
@@ -161,3 +331,298 @@ func (r *BalanceRepository) GetWithdrawals(ctx context.Context, userID string) (
 
 	return repoEntity.ToWithdrawalsFromRepo(withdrawals), nil
 }
+
+// GetTransactions returns the user ledger entries matching the filter. The running balance is
+// calculated over the whole ledger of the user in the program, so it doesn't depend on the filter.
+func (r *BalanceRepository) GetTransactions(ctx context.Context,
+	filter *entity.TransactionFilter) ([]entity.Transaction, error) {
+	entries := r.db.Builder.
+		Select("id, program_id, kind, amount, order_number, reference_id, reason, created_at",
+			"SUM(amount) OVER (PARTITION BY program_id ORDER BY created_at, id) AS balance",
+			programPrecision("ledger_entries")+" AS decimal_places").
+		From("ledger_entries").
+		Where(sq.Eq{
+			"user_id": filter.UserID,
+		})
+
+	query := r.db.Builder.
+		Select("id, program_id, kind, amount, order_number, reference_id, reason, created_at, balance",
+			"decimal_places").
+		FromSelect(entries, "entries")
+
+	if len(filter.Kinds) > 0 {
+		kinds := make([]string, 0, len(filter.Kinds))
+		for _, kind := range filter.Kinds {
+			kinds = append(kinds, string(kind))
+		}
+		query = query.Where(sq.Eq{"kind": kinds})
+	}
+
+	query = withPage(query, filter.PageFilter)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make([]repoEntity.Transaction, 0, filter.Limit)
+
+	for rows.Next() {
+		transaction := repoEntity.Transaction{}
+
+		err = rows.Scan(
+			&transaction.ID,
+			&transaction.ProgramID,
+			&transaction.Kind,
+			&transaction.Amount,
+			&transaction.OrderNumber,
+			&transaction.ReferenceID,
+			&transaction.Reason,
+			&transaction.CreatedAt,
+			&transaction.Balance,
+			&transaction.Precision,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		transaction.UserID = filter.UserID
+		transactions = append(transactions, transaction)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return repoEntity.ToTransactionsFromRepo(transactions), nil
+}
+
+// ReverseWithdrawal marks the withdrawal as reversed and returns the points to the user with
+// a reversal ledger entry referencing the original withdrawal entry.
+func (r *BalanceRepository) ReverseWithdrawal(ctx context.Context, reversalInfo *entity.ReversalInfo) (*entity.Withdraw,
+	error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx pgx.Tx) {
+		err = tx.Rollback(ctx)
+		if errors.Is(err, pgx.ErrTxClosed) {
+			return
+		}
+	}(tx)
+
+	queryUpdateWithdrawal := r.db.Builder.
+		Update("withdrawals").
+		SetMap(sq.Eq{
+			"status":          entity.WithdrawStatusReversed,
+			"reversed_at":     sq.Expr("now()"),
+			"reversal_reason": reversalInfo.Reason,
+			"updated_at":      time.Now(),
+		}).
+		Where(sq.Eq{
+			"order_number": reversalInfo.OrderNumber,
+			"status":       entity.WithdrawStatusWithdrawn,
+		}).
+		Suffix("RETURNING id, user_id, program_id, order_number, withdrawn, status, reversed_at, reversal_reason, " +
+			"created_at, updated_at, deleted_at, " + programPrecision("withdrawals"))
+
+	sql, args, err := queryUpdateWithdrawal.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	withdraw := &repoEntity.Withdraw{}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(
+		&withdraw.ID,
+		&withdraw.UserID,
+		&withdraw.ProgramID,
+		&withdraw.OrderNumber,
+		&withdraw.Withdrawn,
+		&withdraw.Status,
+		&withdraw.ReversedAt,
+		&withdraw.ReversalReason,
+		&withdraw.CreatedAt,
+		&withdraw.UpdatedAt,
+		&withdraw.DeletedAt,
+		&withdraw.Precision,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, r.withdrawalNotReversible(ctx, tx, reversalInfo.OrderNumber)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	queryWithdrawalEntry := r.db.Builder.
+		Select("id").
+		From("ledger_entries").
+		Where(sq.Eq{
+			"kind":         string(entity.EntryWithdrawal),
+			"order_number": withdraw.OrderNumber,
+		})
+
+	sql, args, err = queryWithdrawalEntry.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var referenceID string
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&referenceID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	err = insertLedgerEntry(ctx, tx, r.db.Builder, &entity.LedgerEntry{
+		UserID:      withdraw.UserID,
+		ProgramID:   withdraw.ProgramID,
+		OrderNumber: withdraw.OrderNumber,
+		ReferenceID: referenceID,
+		Reason:      reversalInfo.Reason,
+		Kind:        entity.EntryReversal,
+		Amount:      withdraw.Withdrawn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repoEntity.ToWithdrawFromRepo(withdraw), nil
+}
+
+func (r *BalanceRepository) withdrawalNotReversible(ctx context.Context, tx pgx.Tx, orderNumber string) error {
+	query := r.db.Builder.
+		Select("status").
+		From("withdrawals").
+		Where(sq.Eq{
+			"order_number": orderNumber,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var status string
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entity.ErrWithdrawalNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return entity.ErrWithdrawalAlreadyReversed
+}
+
+// GetExpiringPoints returns the remaining points of the user lots credited before the time
+// by program.
+func (r *BalanceRepository) GetExpiringPoints(ctx context.Context, userID string,
+	creditedBefore time.Time) (map[string]int64, error) {
+	query := r.db.Builder.
+		Select("program_id, SUM(remaining)").
+		From("accrual_lots").
+		Where(sq.Eq{
+			"user_id": userID,
+		}).
+		Where(sq.Lt{
+			"credited_at": creditedBefore,
+		}).
+		Where(sq.Gt{
+			"remaining": 0,
+		}).
+		GroupBy("program_id")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expiring := make(map[string]int64)
+
+	for rows.Next() {
+		var (
+			programID string
+			sum       int64
+		)
+
+		err = rows.Scan(&programID, &sum)
+		if err != nil {
+			return nil, err
+		}
+
+		expiring[programID] = sum
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return expiring, nil
+}
+
+// GetPendingAccrual returns the expected accrual of the user orders that are not final yet
+// by program.
+func (r *BalanceRepository) GetPendingAccrual(ctx context.Context, userID string) (map[string]entity.PendingAccrual,
+	error) {
+	query := r.db.Builder.
+		Select("program_id, COALESCE(SUM(expected_accrual), 0), COUNT(*)").
+		From("orders").
+		Where(sq.Eq{
+			"user_id":    userID,
+			"deleted_at": nil,
+		}).
+		Where(sq.NotEq{
+			"status": []string{entity.StatusInvalid.String(), entity.StatusProcessed.String()},
+		}).
+		GroupBy("program_id")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := make(map[string]entity.PendingAccrual)
+
+	for rows.Next() {
+		var (
+			programID string
+			accrual   entity.PendingAccrual
+		)
+
+		err = rows.Scan(&programID, &accrual.Sum, &accrual.Orders)
+		if err != nil {
+			return nil, err
+		}
+
+		pending[programID] = accrual
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
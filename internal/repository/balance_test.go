@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestReverseWithdrawal(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "user-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+		ID:     uuid.NewString(),
+		UserID: user.ID,
+		Number: uuid.NewString(),
+	})
+	require.NoError(t, err)
+
+	order.Status = entity.StatusProcessed
+	order.Accrual = 10000
+	require.NoError(t, NewAccrualWorkerRepository(db).UpdateOrderAndUserBalance(ctx, *order))
+
+	balanceRepo := NewBalanceRepository(db)
+	withdrawalNumber := uuid.NewString()
+
+	err = balanceRepo.AddWithdrawal(ctx, &entity.WithdrawInfo{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		OrderNumber: withdrawalNumber,
+		Sum:         4000,
+	}, nil)
+	require.NoError(t, err)
+
+	withdraw, err := balanceRepo.ReverseWithdrawal(ctx, &entity.ReversalInfo{
+		OrderNumber: withdrawalNumber,
+		Reason:      "order canceled by the shop",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, entity.WithdrawStatusReversed, withdraw.Status)
+	assert.Equal(t, "order canceled by the shop", withdraw.ReversalReason)
+	assert.NotNil(t, withdraw.ReversedAt)
+
+	balance, err := balanceRepo.GetUserBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), balance.Balance)
+	assert.Equal(t, int64(0), balance.Withdrawn)
+
+	withdrawals, err := balanceRepo.GetWithdrawals(ctx, &entity.PageFilter{UserID: user.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, entity.WithdrawStatusReversed, withdrawals[0].Status)
+
+	_, err = balanceRepo.ReverseWithdrawal(ctx, &entity.ReversalInfo{OrderNumber: withdrawalNumber, Reason: "again"})
+	assert.ErrorIs(t, err, entity.ErrWithdrawalAlreadyReversed)
+
+	_, err = balanceRepo.ReverseWithdrawal(ctx, &entity.ReversalInfo{OrderNumber: uuid.NewString(), Reason: "unknown"})
+	assert.ErrorIs(t, err, entity.ErrWithdrawalNotFound)
+}
+
+func TestAccrualLots(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "user-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	accrualRepo := NewAccrualWorkerRepository(db)
+	for _, accrual := range []int64{3000, 5000} {
+		order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+			ID:     uuid.NewString(),
+			UserID: user.ID,
+			Number: uuid.NewString(),
+		})
+		require.NoError(t, err)
+
+		order.Status = entity.StatusProcessed
+		order.Accrual = accrual
+		require.NoError(t, accrualRepo.UpdateOrderAndUserBalance(ctx, *order))
+	}
+
+	_, err = db.Pool.Exec(ctx, `UPDATE accrual_lots SET credited_at = now() - interval '1 year'
+		WHERE user_id = $1 AND amount = 3000`, user.ID)
+	require.NoError(t, err)
+
+	balanceRepo := NewBalanceRepository(db)
+
+	err = balanceRepo.AddWithdrawal(ctx, &entity.WithdrawInfo{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		OrderNumber: uuid.NewString(),
+		Sum:         2000,
+	}, nil)
+	require.NoError(t, err)
+
+	monthAgo := time.Now().AddDate(0, -1, 0)
+
+	expiring, err := balanceRepo.GetExpiringPoints(ctx, user.ID, monthAgo)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), expiring[entity.DefaultProgramID], "withdrawal spends the oldest lot first")
+
+	_, err = NewLedgerRepository(db).ExpireLots(ctx, monthAgo, DefaultEntityCap)
+	require.NoError(t, err)
+
+	balance, err := balanceRepo.GetUserBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), balance.Balance)
+	assert.Equal(t, int64(2000), balance.Withdrawn)
+
+	expiring, err = balanceRepo.GetExpiringPoints(ctx, user.ID, monthAgo)
+	require.NoError(t, err)
+	assert.Empty(t, expiring)
+}
+
+func TestGetPendingAccrual(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "user-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	partner, err := NewBalanceRepository(db).AddProgram(ctx, &entity.Program{
+		ID:             "partner-" + uuid.NewString()[:8],
+		Name:           "Partner",
+		ConversionRate: decimal.NewFromInt(10),
+	})
+	require.NoError(t, err)
+
+	orders := make([]*entity.Order, 0, 4)
+	for _, programID := range []string{"", "", "", partner.ID} {
+		order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+			ID:        uuid.NewString(),
+			UserID:    user.ID,
+			Number:    uuid.NewString(),
+			ProgramID: programID,
+		})
+		require.NoError(t, err)
+		orders = append(orders, order)
+	}
+
+	accrualRepo := NewAccrualWorkerRepository(db)
+
+	orders[0].AccrualStatus = entity.StatusProcessing.String()
+	orders[0].ExpectedAccrual = 2500
+	require.NoError(t, accrualRepo.UpdateAccrualStatus(ctx, *orders[0], time.Now()))
+
+	orders[1].Status = entity.StatusProcessed
+	orders[1].Accrual = 1000
+	require.NoError(t, accrualRepo.UpdateOrderAndUserBalance(ctx, *orders[1]))
+
+	orders[3].AccrualStatus = entity.StatusProcessing.String()
+	orders[3].ExpectedAccrual = 700
+	require.NoError(t, accrualRepo.UpdateAccrualStatus(ctx, *orders[3], time.Now()))
+
+	pending, err := NewBalanceRepository(db).GetPendingAccrual(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PendingAccrual{Sum: 2500, Orders: 2}, pending[entity.DefaultProgramID])
+	assert.Equal(t, entity.PendingAccrual{Sum: 700, Orders: 1}, pending[partner.ID])
+}
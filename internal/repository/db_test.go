@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/ivas1ly/gophermart/internal/lib/migrate"
+	"github.com/ivas1ly/gophermart/internal/lib/storage/postgres"
+)
+
+const (
+	defaultTestConnAttempts = 1
+	defaultTestConnTimeout  = time.Second
+	defaultTestImage        = "postgres:16-alpine"
+	defaultStartupTimeout   = 1 * time.Minute
+)
+
+var (
+	containerOnce sync.Once
+	container     *tcpostgres.PostgresContainer
+	containerDSN  string
+	containerErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	if container != nil {
+		if err := container.Terminate(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "can't terminate postgres container: %s\n", err)
+		}
+	}
+
+	os.Exit(code)
+}
+
+// newTestDB connects to the database from TEST_DATABASE_URI and applies migrations. If it is
+// not set, a Postgres container is started once for all tests of the package, the test is
+// skipped only if Docker is not available.
+func newTestDB(t *testing.T) *postgres.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		containerOnce.Do(func() {
+			containerDSN, containerErr = startPostgres(context.Background())
+		})
+		if containerErr != nil {
+			t.Skipf("TEST_DATABASE_URI is not set and postgres container can't be started: %s", containerErr)
+		}
+		dsn = containerDSN
+	}
+
+	ctx := context.Background()
+
+	db, err := postgres.New(ctx, dsn, defaultTestConnAttempts, defaultTestConnTimeout)
+	require.NoError(t, err)
+	t.Cleanup(db.Pool.Close)
+
+	require.NoError(t, migrate.Run(ctx, db.Pool))
+
+	return db
+}
+
+// startPostgres runs a disposable Postgres container and returns its connection string.
+// Testcontainers panics if there is no Docker host, it is returned as an error.
+func startPostgres(ctx context.Context) (dsn string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("docker is not available: %v", r)
+		}
+	}()
+
+	container, err = tcpostgres.RunContainer(ctx,
+		testcontainers.WithImage(defaultTestImage),
+		tcpostgres.WithDatabase("gophermart"),
+		tcpostgres.WithUsername("postgres"),
+		tcpostgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(defaultStartupTimeout)),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return container.ConnectionString(ctx, "sslmode=disable")
+}
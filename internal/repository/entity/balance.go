@@ -4,32 +4,75 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
 
 	"github.com/ivas1ly/gophermart/internal/entity"
 )
 
-type Balance struct {
-	ID        string
+type Program struct {
+	CreatedAt      time.Time
+	ConversionRate decimal.Decimal
+	ID             string
+	Name           string
+	Precision      int32
+}
+
+func ToProgramFromRepo(program *Program) *entity.Program {
+	return &entity.Program{
+		CreatedAt:      program.CreatedAt,
+		ConversionRate: program.ConversionRate,
+		ID:             program.ID,
+		Name:           program.Name,
+		Precision:      program.Precision,
+	}
+}
+
+type ProgramBalance struct {
+	Program
 	Balance   int64
 	Withdrawn int64
 }
 
-func ToUserBalanceFromRepo(userBalance *Balance) *entity.Balance {
-	return &entity.Balance{
-		ID:        userBalance.ID,
-		Balance:   userBalance.Balance,
-		Withdrawn: userBalance.Withdrawn,
+// ToUserBalanceFromRepo fills the top level balance from the default program.
+func ToUserBalanceFromRepo(userID string, programs []ProgramBalance) *entity.Balance {
+	balance := &entity.Balance{
+		ID:       userID,
+		Programs: make([]entity.ProgramBalance, 0, len(programs)),
 	}
+
+	for _, program := range programs {
+		if program.ID == entity.DefaultProgramID {
+			balance.Balance = program.Balance
+			balance.Withdrawn = program.Withdrawn
+		}
+
+		balance.Programs = append(balance.Programs, entity.ProgramBalance{
+			Program:   *ToProgramFromRepo(&program.Program),
+			Balance:   program.Balance,
+			Withdrawn: program.Withdrawn,
+		})
+	}
+
+	return balance
 }
 
 type Withdraw struct {
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   pgtype.Timestamptz
-	ID          string
-	UserID      string
-	OrderNumber string
-	Withdrawn   int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      pgtype.Timestamptz
+	ReversedAt     pgtype.Timestamptz
+	ReversalReason pgtype.Text
+	ID             string
+	UserID         string
+	OrderNumber    string
+	Status         string
+	ProgramID      string
+	Withdrawn      int64
+	Precision      int32
+}
+
+func ToWithdrawFromRepo(withdraw *Withdraw) *entity.Withdraw {
+	return &ToWithdrawalsFromRepo([]Withdraw{*withdraw})[0]
 }
 
 func ToWithdrawalsFromRepo(withdrawals []Withdraw) []entity.Withdraw {
@@ -42,14 +85,25 @@ func ToWithdrawalsFromRepo(withdrawals []Withdraw) []entity.Withdraw {
 			deletedAt = &deleted.Time
 		}
 
+		var reversedAt *time.Time
+		if withdraw.ReversedAt.Valid {
+			reversed := withdraw.ReversedAt.Time
+			reversedAt = &reversed
+		}
+
 		entities = append(entities, entity.Withdraw{
-			CreatedAt:   withdraw.CreatedAt,
-			UpdatedAt:   withdraw.UpdatedAt,
-			DeletedAt:   deletedAt,
-			ID:          withdraw.ID,
-			UserID:      withdraw.UserID,
-			OrderNumber: withdraw.OrderNumber,
-			Withdrawn:   withdraw.Withdrawn,
+			CreatedAt:      withdraw.CreatedAt,
+			UpdatedAt:      withdraw.UpdatedAt,
+			DeletedAt:      deletedAt,
+			ID:             withdraw.ID,
+			UserID:         withdraw.UserID,
+			OrderNumber:    withdraw.OrderNumber,
+			Withdrawn:      withdraw.Withdrawn,
+			Status:         withdraw.Status,
+			ReversedAt:     reversedAt,
+			ReversalReason: withdraw.ReversalReason.String,
+			ProgramID:      withdraw.ProgramID,
+			Precision:      withdraw.Precision,
 		})
 	}
 
@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type Transaction struct {
+	CreatedAt   time.Time
+	OrderNumber pgtype.Text
+	ReferenceID pgtype.Text
+	Reason      pgtype.Text
+	ID          string
+	UserID      string
+	ProgramID   string
+	Kind        string
+	Amount      int64
+	Balance     int64
+	Precision   int32
+}
+
+func ToTransactionsFromRepo(transactions []Transaction) []entity.Transaction {
+	entities := make([]entity.Transaction, 0, len(transactions))
+
+	for _, transaction := range transactions {
+		entities = append(entities, entity.Transaction{
+			LedgerEntry: entity.LedgerEntry{
+				CreatedAt:   transaction.CreatedAt,
+				ID:          transaction.ID,
+				UserID:      transaction.UserID,
+				ProgramID:   transaction.ProgramID,
+				OrderNumber: transaction.OrderNumber.String,
+				ReferenceID: transaction.ReferenceID.String,
+				Reason:      transaction.Reason.String,
+				Kind:        entity.EntryKind(transaction.Kind),
+				Amount:      transaction.Amount,
+			},
+			Balance:   transaction.Balance,
+			Precision: transaction.Precision,
+		})
+	}
+
+	return entities
+}
@@ -9,14 +9,21 @@ import (
 )
 
 type Order struct {
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt pgtype.Timestamptz
-	ID        string
-	UserID    string
-	Number    string
-	Status    string
-	Accrual   int64
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	NextAttemptAt time.Time
+	DeletedAt     pgtype.Timestamptz
+	CheckedAt     pgtype.Timestamptz
+	LastError     pgtype.Text
+	AccrualStatus pgtype.Text
+	ID            string
+	UserID        string
+	Number        string
+	Status        string
+	ProgramID     string
+	Accrual       int64
+	Attempts      int
+	Precision     int32
 }
 
 func ToOrderFromRepo(order *Order) *entity.Order {
@@ -25,15 +32,27 @@ func ToOrderFromRepo(order *Order) *entity.Order {
 		deletedAt = &order.DeletedAt.Time
 	}
 
+	var checkedAt *time.Time
+	if order.CheckedAt.Valid {
+		checkedAt = &order.CheckedAt.Time
+	}
+
 	return &entity.Order{
-		CreatedAt: order.CreatedAt,
-		UpdatedAt: order.UpdatedAt,
-		DeletedAt: deletedAt,
-		Accrual:   order.Accrual,
-		ID:        order.ID,
-		UserID:    order.UserID,
-		Number:    order.Number,
-		Status:    order.Status,
+		CreatedAt:     order.CreatedAt,
+		UpdatedAt:     order.UpdatedAt,
+		NextAttemptAt: order.NextAttemptAt,
+		DeletedAt:     deletedAt,
+		CheckedAt:     checkedAt,
+		Accrual:       order.Accrual,
+		ID:            order.ID,
+		UserID:        order.UserID,
+		Number:        order.Number,
+		Status:        entity.ParseStatus(order.Status),
+		AccrualStatus: order.AccrualStatus.String,
+		LastError:     order.LastError.String,
+		Attempts:      order.Attempts,
+		ProgramID:     order.ProgramID,
+		Precision:     order.Precision,
 	}
 }
 
@@ -47,15 +66,28 @@ func ToOrdersFromRepo(orders []Order) []entity.Order {
 			deletedAt = &deleted.Time
 		}
 
+		var checkedAt *time.Time
+		if order.CheckedAt.Valid {
+			checked := order.CheckedAt
+			checkedAt = &checked.Time
+		}
+
 		entities = append(entities, entity.Order{
-			CreatedAt: order.CreatedAt,
-			UpdatedAt: order.UpdatedAt,
-			DeletedAt: deletedAt,
-			ID:        order.ID,
-			UserID:    order.UserID,
-			Number:    order.Number,
-			Status:    order.Status,
-			Accrual:   order.Accrual,
+			CreatedAt:     order.CreatedAt,
+			UpdatedAt:     order.UpdatedAt,
+			NextAttemptAt: order.NextAttemptAt,
+			DeletedAt:     deletedAt,
+			CheckedAt:     checkedAt,
+			ID:            order.ID,
+			UserID:        order.UserID,
+			Number:        order.Number,
+			Status:        entity.ParseStatus(order.Status),
+			AccrualStatus: order.AccrualStatus.String,
+			LastError:     order.LastError.String,
+			Accrual:       order.Accrual,
+			Attempts:      order.Attempts,
+			ProgramID:     order.ProgramID,
+			Precision:     order.Precision,
 		})
 	}
 
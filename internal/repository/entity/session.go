@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type Session struct {
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt pgtype.Timestamptz
+	ID        string
+	UserID    string
+}
+
+func ToSessionFromRepo(session *Session) *entity.Session {
+	var revokedAt *time.Time
+	if session.RevokedAt.Valid {
+		revokedAt = &session.RevokedAt.Time
+	}
+
+	return &entity.Session{
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+		RevokedAt: revokedAt,
+		ID:        session.ID,
+		UserID:    session.UserID,
+	}
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/storage/postgres"
+)
+
+type IdempotencyRepository struct {
+	db *postgres.DB
+}
+
+func NewIdempotencyRepository(db *postgres.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: db,
+	}
+}
+
+// Reserve saves the key without a response. It returns false if a not expired record with
+// the key already exists. An expired record is replaced, so is a record of the same request
+// without a response after its lock. The record's ReservationID becomes the owner of the key.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, record *entity.IdempotencyRecord) (bool, error) {
+	query := r.db.Builder.
+		Insert("idempotency_keys").
+		Columns("user_id, key, request_hash, expires_at, locked_until, reservation_id").
+		Values(record.UserID, record.Key, record.RequestHash, record.ExpiresAt, record.LockedUntil,
+			record.ReservationID).
+		Suffix("ON CONFLICT (user_id, key) DO UPDATE SET " +
+			"request_hash = EXCLUDED.request_hash, status_code = 0, content_type = '', response_body = NULL, " +
+			"created_at = now(), expires_at = EXCLUDED.expires_at, locked_until = EXCLUDED.locked_until, " +
+			"reservation_id = EXCLUDED.reservation_id " +
+			"WHERE idempotency_keys.expires_at <= now() OR (idempotency_keys.status_code = 0 " +
+			"AND idempotency_keys.locked_until <= now() AND idempotency_keys.request_hash = EXCLUDED.request_hash)")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	tag, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Get returns the not expired record of the key or nil if there is none.
+func (r *IdempotencyRepository) Get(ctx context.Context, userID, key string) (*entity.IdempotencyRecord, error) {
+	query := r.db.Builder.
+		Select("user_id, key, request_hash, status_code, content_type, response_body, expires_at").
+		From("idempotency_keys").
+		Where(sq.Eq{
+			"user_id": userID,
+			"key":     key,
+		}).
+		Where(sq.Expr("expires_at > now()"))
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &entity.IdempotencyRecord{}
+
+	err = r.db.Pool.QueryRow(ctx, sql, args...).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.Body,
+		&record.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// Save stores the response of the reserved key. It returns entity.ErrIdempotencyLeaseLost if
+// the key was taken over by another request.
+func (r *IdempotencyRepository) Save(ctx context.Context, record *entity.IdempotencyRecord) error {
+	query := r.db.Builder.
+		Update("idempotency_keys").
+		SetMap(sq.Eq{
+			"status_code":   record.StatusCode,
+			"content_type":  record.ContentType,
+			"response_body": record.Body,
+			"locked_until":  nil,
+		}).
+		Where(sq.Eq{
+			"user_id":        record.UserID,
+			"key":            record.Key,
+			"reservation_id": record.ReservationID,
+			"status_code":    0,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrIdempotencyLeaseLost
+	}
+
+	return nil
+}
+
+// Delete releases the reserved key, so the request can be retried. It returns
+// entity.ErrIdempotencyLeaseLost if the key was taken over by another request.
+func (r *IdempotencyRepository) Delete(ctx context.Context, record *entity.IdempotencyRecord) error {
+	query := r.db.Builder.
+		Delete("idempotency_keys").
+		Where(sq.Eq{
+			"user_id":        record.UserID,
+			"key":            record.Key,
+			"reservation_id": record.ReservationID,
+			"status_code":    0,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrIdempotencyLeaseLost
+	}
+
+	return nil
+}
+
+// DeleteExpired removes records expired before the time and returns their number.
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := r.db.Builder.
+		Delete("idempotency_keys").
+		Where(sq.Lt{
+			"expires_at": before,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
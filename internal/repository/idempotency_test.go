@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestIdempotencyReservation(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "idempotency-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	idempotencyRepo := NewIdempotencyRepository(db)
+
+	first := &entity.IdempotencyRecord{
+		ExpiresAt:     time.Now().Add(time.Hour),
+		LockedUntil:   time.Now().Add(-time.Second),
+		ReservationID: uuid.NewString(),
+		UserID:        user.ID,
+		Key:           "key",
+		RequestHash:   "hash",
+	}
+
+	reserved, err := idempotencyRepo.Reserve(ctx, first)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	retry := *first
+	retry.LockedUntil = time.Now().Add(time.Minute)
+	retry.ReservationID = uuid.NewString()
+
+	reserved, err = idempotencyRepo.Reserve(ctx, &retry)
+	require.NoError(t, err)
+	require.True(t, reserved, "the retry takes the key over after the lease")
+
+	first.StatusCode, first.Body = 200, []byte("first")
+	assert.ErrorIs(t, idempotencyRepo.Save(ctx, first), entity.ErrIdempotencyLeaseLost)
+	assert.ErrorIs(t, idempotencyRepo.Delete(ctx, first), entity.ErrIdempotencyLeaseLost)
+
+	retry.StatusCode, retry.Body = 200, []byte("retry")
+	require.NoError(t, idempotencyRepo.Save(ctx, &retry))
+
+	stored, err := idempotencyRepo.Get(ctx, user.ID, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("retry"), stored.Body)
+}
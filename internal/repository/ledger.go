@@ -0,0 +1,543 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/storage/postgres"
+)
+
+type LedgerRepository struct {
+	db *postgres.DB
+}
+
+func NewLedgerRepository(db *postgres.DB) *LedgerRepository {
+	return &LedgerRepository{
+		db: db,
+	}
+}
+
+// ReconcileBalances rebuilds the cached program account balances from the ledger for accounts
+// that differ or are missing and returns the number of fixed accounts.
+func (r *LedgerRepository) ReconcileBalances(ctx context.Context) (int64, error) {
+	ledgerBalances := r.db.Builder.
+		Select("user_id, program_id, SUM(amount)").
+		From("ledger_entries").
+		GroupBy("user_id", "program_id")
+
+	queryUpsert := r.db.Builder.
+		Insert("program_accounts").
+		Columns("user_id, program_id, balance").
+		Select(ledgerBalances).
+		Suffix("ON CONFLICT (user_id, program_id) DO UPDATE " +
+			"SET balance = EXCLUDED.balance, updated_at = now() " +
+			"WHERE program_accounts.balance <> EXCLUDED.balance")
+
+	sql, args, err := queryUpsert.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	upserted, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	queryReset := r.db.Builder.
+		Update("program_accounts").
+		SetMap(sq.Eq{
+			"balance":    0,
+			"updated_at": sq.Expr("now()"),
+		}).
+		Where(sq.NotEq{
+			"balance": 0,
+		}).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.user_id = program_accounts.user_id " +
+			"AND ledger_entries.program_id = program_accounts.program_id)")
+
+	sql, args, err = queryReset.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	reset, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return upserted.RowsAffected() + reset.RowsAffected(), nil
+}
+
+// ExpireLots writes off the remaining points of at most limit lots credited before the time
+// and returns the number of expired lots. Each lot is expired in its own transaction.
+func (r *LedgerRepository) ExpireLots(ctx context.Context, creditedBefore time.Time, limit int) (int, error) {
+	query := r.db.Builder.
+		Select("id, user_id, program_id").
+		From("accrual_lots").
+		Where(sq.Gt{
+			"remaining": 0,
+		}).
+		Where(sq.Lt{
+			"credited_at": creditedBefore,
+		}).
+		OrderBy("credited_at ASC").
+		Limit(uint64(limit))
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	type lot struct {
+		id        string
+		userID    string
+		programID string
+	}
+
+	lots := make([]lot, 0, limit)
+
+	for rows.Next() {
+		var l lot
+
+		err = rows.Scan(&l.id, &l.userID, &l.programID)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var expired int
+	for _, l := range lots {
+		ok, err := r.expireLot(ctx, l.id, l.userID, l.programID)
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// expireLot locks the user before the lot in the same order as spendLots does, the lot may be
+// already spent by a concurrent withdrawal.
+func (r *LedgerRepository) expireLot(ctx context.Context, lotID, userID, programID string) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func(tx pgx.Tx) {
+		err = tx.Rollback(ctx)
+		if errors.Is(err, pgx.ErrTxClosed) {
+			return
+		}
+	}(tx)
+
+	queryLockUser := r.db.Builder.
+		Select("id").
+		From("users").
+		Where(sq.Eq{
+			"id": userID,
+		}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := queryLockUser.ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, err
+	}
+
+	querySelectLot := r.db.Builder.
+		Select("remaining, entry_id").
+		From("accrual_lots").
+		Where(sq.Eq{
+			"id": lotID,
+		}).
+		Where(sq.Gt{
+			"remaining": 0,
+		}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err = querySelectLot.ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		remaining int64
+		entryID   pgtype.Text
+	)
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&remaining, &entryID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	queryExpireLot := r.db.Builder.
+		Update("accrual_lots").
+		SetMap(sq.Eq{
+			"remaining":  0,
+			"expired_at": sq.Expr("now()"),
+		}).
+		Where(sq.Eq{
+			"id": lotID,
+		})
+
+	sql, args, err = queryExpireLot.ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, err
+	}
+
+	err = insertLedgerEntry(ctx, tx, r.db.Builder, &entity.LedgerEntry{
+		UserID:      userID,
+		ProgramID:   programID,
+		ReferenceID: entryID.String,
+		Reason:      "points expired",
+		Kind:        entity.EntryExpiration,
+		Amount:      -remaining,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// insertLedgerEntry appends the entry and updates the cached program account balance in the same
+// transaction. The balance can't become negative, entity.ErrNotEnoughPointsToWithdraw
+// is returned instead. A credit opens a new accrual lot and a debit spends the oldest lots,
+// expiration entries close their lots themselves. Points returned by a reversal or received
+// by a transfer keep the credit time of the lots the referenced debit was taken from. The user
+// row is locked first, so the user, the account and the lots are always locked in the same order.
+func insertLedgerEntry(ctx context.Context, tx pgx.Tx, builder sq.StatementBuilderType,
+	entry *entity.LedgerEntry) error {
+	if entry.ID == "" {
+		entryUUID, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		entry.ID = entryUUID.String()
+	}
+	if entry.ProgramID == "" {
+		entry.ProgramID = entity.DefaultProgramID
+	}
+
+	queryLockUser := builder.
+		Select("id").
+		From("users").
+		Where(sq.Eq{
+			"id": entry.UserID,
+		}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := queryLockUser.ToSql()
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Join(err, entity.ErrCanNotUpdateUserBalance)
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrCanNotUpdateUserBalance
+	}
+
+	queryUpdateBalance := builder.
+		Insert("program_accounts").
+		Columns("user_id, program_id, balance").
+		Values(entry.UserID, entry.ProgramID, entry.Amount).
+		Suffix("ON CONFLICT (user_id, program_id) DO UPDATE " +
+			"SET balance = program_accounts.balance + EXCLUDED.balance, updated_at = now()")
+
+	sql, args, err = queryUpdateBalance.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return entity.ErrNotEnoughPointsToWithdraw
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return entity.ErrProgramNotFound
+		}
+		return errors.Join(err, entity.ErrCanNotUpdateUserBalance)
+	}
+
+	queryInsertEntry := builder.
+		Insert("ledger_entries").
+		Columns("id, user_id, program_id, kind, amount, order_number, reference_id, reason").
+		Values(entry.ID, entry.UserID, entry.ProgramID, string(entry.Kind), entry.Amount,
+			nullString(entry.OrderNumber), nullString(entry.ReferenceID), nullString(entry.Reason)).
+		Suffix("RETURNING created_at")
+
+	sql, args, err = queryInsertEntry.ToSql()
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case entry.Amount > 0 && entry.ReferenceID != "" &&
+		(entry.Kind == entity.EntryReversal || entry.Kind == entity.EntryTransferIn):
+		return insertReturnedLots(ctx, tx, builder, entry)
+	case entry.Amount > 0:
+		return insertLot(ctx, tx, builder, entry, entry.Amount, entry.CreatedAt)
+	case entry.Kind != entity.EntryExpiration:
+		return spendLots(ctx, tx, builder, entry, -entry.Amount)
+	}
+
+	return nil
+}
+
+func insertLot(ctx context.Context, tx pgx.Tx, builder sq.StatementBuilderType, entry *entity.LedgerEntry,
+	amount int64, creditedAt time.Time) error {
+	lotUUID, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	query := builder.
+		Insert("accrual_lots").
+		Columns("id, user_id, program_id, entry_id, amount, remaining, credited_at").
+		Values(lotUUID.String(), entry.UserID, entry.ProgramID, entry.ID, amount, amount, creditedAt)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+
+	return err
+}
+
+// insertReturnedLots opens lots with the credit times of the lots the referenced debit was taken
+// from, so moving points back and forth doesn't delay their expiry. Debits made before the spent
+// lots were recorded are credited at the time of the debit.
+func insertReturnedLots(ctx context.Context, tx pgx.Tx, builder sq.StatementBuilderType,
+	entry *entity.LedgerEntry) error {
+	query := builder.
+		Select("accrual_lots.credited_at, lot_spendings.amount").
+		From("lot_spendings").
+		Join("accrual_lots ON accrual_lots.id = lot_spendings.lot_id").
+		Where(sq.Eq{
+			"lot_spendings.entry_id": entry.ReferenceID,
+		}).
+		OrderBy("accrual_lots.credited_at ASC", "accrual_lots.id ASC")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	type spending struct {
+		creditedAt time.Time
+		amount     int64
+	}
+
+	spendings := make([]spending, 0)
+
+	for rows.Next() {
+		var sp spending
+
+		err = rows.Scan(&sp.creditedAt, &sp.amount)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		spendings = append(spendings, sp)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	left := entry.Amount
+
+	for _, sp := range spendings {
+		if left == 0 {
+			break
+		}
+
+		amount := min(sp.amount, left)
+		err = insertLot(ctx, tx, builder, entry, amount, sp.creditedAt)
+		if err != nil {
+			return err
+		}
+		left -= amount
+	}
+
+	if left == 0 {
+		return nil
+	}
+
+	queryDebit := builder.
+		Select("created_at").
+		From("ledger_entries").
+		Where(sq.Eq{
+			"id": entry.ReferenceID,
+		})
+
+	sql, args, err = queryDebit.ToSql()
+	if err != nil {
+		return err
+	}
+
+	creditedAt := entry.CreatedAt
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&creditedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	return insertLot(ctx, tx, builder, entry, left, creditedAt)
+}
+
+// spendLots takes the amount from the oldest lots of the user in the program and records them
+// as spent by the entry. The user row must be already locked by insertLedgerEntry, so lots are
+// always locked after it.
+func spendLots(ctx context.Context, tx pgx.Tx, builder sq.StatementBuilderType, entry *entity.LedgerEntry,
+	amount int64) error {
+	query := builder.
+		Select("id, remaining").
+		From("accrual_lots").
+		Where(sq.Eq{
+			"user_id":    entry.UserID,
+			"program_id": entry.ProgramID,
+		}).
+		Where(sq.Gt{
+			"remaining": 0,
+		}).
+		OrderBy("credited_at ASC", "id ASC").
+		Suffix("FOR UPDATE")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	spent := make(map[string]int64)
+	ids := make([]string, 0)
+
+	for rows.Next() && amount > 0 {
+		var (
+			id        string
+			remaining int64
+		)
+
+		err = rows.Scan(&id, &remaining)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		take := min(remaining, amount)
+		spent[id] = take
+		ids = append(ids, id)
+		amount -= take
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		queryUpdate := builder.
+			Update("accrual_lots").
+			Set("remaining", sq.Expr("remaining - ?", spent[id])).
+			Where(sq.Eq{
+				"id": id,
+			})
+
+		sql, args, err = queryUpdate.ToSql()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+
+		querySpending := builder.
+			Insert("lot_spendings").
+			Columns("entry_id, lot_id, amount").
+			Values(entry.ID, id, spent[id])
+
+		sql, args, err = querySpending.ToSql()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func nullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
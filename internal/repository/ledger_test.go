@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestLedger(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "user-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+		ID:     uuid.NewString(),
+		UserID: user.ID,
+		Number: uuid.NewString(),
+	})
+	require.NoError(t, err)
+
+	order.Status = entity.StatusProcessed
+	order.Accrual = 50000
+	require.NoError(t, NewAccrualWorkerRepository(db).UpdateOrderAndUserBalance(ctx, *order))
+
+	balanceRepo := NewBalanceRepository(db)
+
+	err = balanceRepo.AddWithdrawal(ctx, &entity.WithdrawInfo{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		OrderNumber: uuid.NewString(),
+		Sum:         12050,
+	}, nil)
+	require.NoError(t, err)
+
+	err = balanceRepo.AddWithdrawal(ctx, &entity.WithdrawInfo{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		OrderNumber: uuid.NewString(),
+		Sum:         100000,
+	}, nil)
+	assert.ErrorIs(t, err, entity.ErrNotEnoughPointsToWithdraw)
+
+	balance, err := balanceRepo.GetUserBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(37950), balance.Balance)
+	assert.Equal(t, int64(12050), balance.Withdrawn)
+
+	t.Run("transactions with running balance", func(t *testing.T) {
+		transactions, err := balanceRepo.GetTransactions(ctx, &entity.TransactionFilter{
+			PageFilter: entity.PageFilter{UserID: user.ID, Limit: 1, Descending: true},
+		})
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		assert.Equal(t, entity.EntryWithdrawal, transactions[0].Kind)
+		assert.Equal(t, int64(-12050), transactions[0].Amount)
+		assert.Equal(t, int64(37950), transactions[0].Balance)
+
+		transactions, err = balanceRepo.GetTransactions(ctx, &entity.TransactionFilter{
+			PageFilter: entity.PageFilter{
+				UserID:     user.ID,
+				AfterTime:  transactions[0].CreatedAt,
+				AfterID:    transactions[0].ID,
+				Limit:      10,
+				Descending: true,
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		assert.Equal(t, entity.EntryAccrual, transactions[0].Kind)
+		assert.Equal(t, order.Number, transactions[0].OrderNumber)
+		assert.Equal(t, int64(50000), transactions[0].Balance)
+
+		transactions, err = balanceRepo.GetTransactions(ctx, &entity.TransactionFilter{
+			PageFilter: entity.PageFilter{UserID: user.ID, Limit: 10},
+			Kinds:      []entity.EntryKind{entity.EntryAccrual},
+		})
+		require.NoError(t, err)
+		assert.Len(t, transactions, 1)
+	})
+
+	t.Run("entries are append-only", func(t *testing.T) {
+		_, err := db.Pool.Exec(ctx, "UPDATE ledger_entries SET amount = 1 WHERE user_id = $1", user.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("reconcile restores the cached balance", func(t *testing.T) {
+		_, err := db.Pool.Exec(ctx, "UPDATE program_accounts SET balance = 1 WHERE user_id = $1", user.ID)
+		require.NoError(t, err)
+
+		reconciled, err := NewLedgerRepository(db).ReconcileBalances(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, reconciled, int64(1))
+
+		var cached int64
+		err = db.Pool.QueryRow(ctx, "SELECT balance FROM program_accounts WHERE user_id = $1 AND program_id = $2",
+			user.ID, entity.DefaultProgramID).Scan(&cached)
+		require.NoError(t, err)
+		assert.Equal(t, int64(37950), cached)
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
 
 	"github.com/ivas1ly/gophermart/internal/entity"
@@ -38,7 +40,8 @@ func (r *OrderRepository) AddOrder(ctx context.Context, orderInfo *entity.OrderI
 	}(tx)
 
 	checkQuery := r.db.Builder.
-		Select("id, user_id, number, status, accrual, created_at, updated_at, deleted_at").
+		Select("id, user_id, program_id, number, status, accrual, accrual_status, accrual_checked_at",
+			"created_at, updated_at, deleted_at", programPrecision("orders")).
 		From("orders").
 		Where(sq.Eq{
 			"number": orderInfo.Number,
@@ -53,12 +56,16 @@ func (r *OrderRepository) AddOrder(ctx context.Context, orderInfo *entity.OrderI
 	err = checkRow.Scan(
 		&order.ID,
 		&order.UserID,
+		&order.ProgramID,
 		&order.Number,
 		&order.Status,
 		&order.Accrual,
+		&order.AccrualStatus,
+		&order.CheckedAt,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 		&order.DeletedAt,
+		&order.Precision,
 	)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return nil, err
@@ -69,11 +76,17 @@ func (r *OrderRepository) AddOrder(ctx context.Context, orderInfo *entity.OrderI
 
 	zap.L().Info("no rows found, continue to add new order")
 
+	if orderInfo.ProgramID == "" {
+		orderInfo.ProgramID = entity.DefaultProgramID
+	}
+
 	query := r.db.Builder.
 		Insert("orders").
-		Columns("id, user_id, number, status, accrual").
-		Values(orderInfo.ID, orderInfo.UserID, orderInfo.Number, entity.StatusNew.String(), 0).
-		Suffix("RETURNING id, user_id, number, status, accrual, created_at, updated_at, deleted_at")
+		Columns("id, user_id, program_id, number, status, accrual, next_attempt_at").
+		Values(orderInfo.ID, orderInfo.UserID, orderInfo.ProgramID, orderInfo.Number, entity.StatusNew.String(), 0,
+			orderInfo.NextAttemptAt).
+		Suffix("RETURNING id, user_id, program_id, number, status, accrual, accrual_status, accrual_checked_at, " +
+			"created_at, updated_at, deleted_at, " + programPrecision("orders"))
 
 	sql, args, err = query.ToSql()
 	if err != nil {
@@ -84,14 +97,22 @@ func (r *OrderRepository) AddOrder(ctx context.Context, orderInfo *entity.OrderI
 	err = row.Scan(
 		&order.ID,
 		&order.UserID,
+		&order.ProgramID,
 		&order.Number,
 		&order.Status,
 		&order.Accrual,
+		&order.AccrualStatus,
+		&order.CheckedAt,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 		&order.DeletedAt,
+		&order.Precision,
 	)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return nil, entity.ErrProgramNotFound
+		}
 		return nil, err
 	}
 
@@ -103,15 +124,24 @@ func (r *OrderRepository) AddOrder(ctx context.Context, orderInfo *entity.OrderI
 	return repoEntity.ToOrderFromRepo(order), nil
 }
 
-func (r *OrderRepository) GetOrders(ctx context.Context, userID string) ([]entity.Order, error) {
+func (r *OrderRepository) GetOrders(ctx context.Context, filter *entity.OrderFilter) ([]entity.Order, error) {
 	query := r.db.Builder.
-		Select("id, user_id, number, status, accrual, created_at, updated_at, deleted_at").
+		Select("id, user_id, program_id, number, status, accrual, accrual_status, accrual_checked_at",
+			"created_at, updated_at, deleted_at", programPrecision("orders")).
 		From("orders").
 		Where(sq.Eq{
-			"user_id": userID,
-		}).
-		OrderBy("created_at ASC").
-		Limit(DefaultEntityCap)
+			"user_id": filter.UserID,
+		})
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, status := range filter.Statuses {
+			statuses = append(statuses, status.String())
+		}
+		query = query.Where(sq.Eq{"status": statuses})
+	}
+
+	query = withPage(query, filter.PageFilter)
 
 	sql, args, err := query.ToSql()
 	if err != nil {
@@ -124,7 +154,7 @@ func (r *OrderRepository) GetOrders(ctx context.Context, userID string) ([]entit
 	}
 	defer rows.Close()
 
-	orders := make([]repoEntity.Order, 0, DefaultEntityCap)
+	orders := make([]repoEntity.Order, 0, filter.Limit)
 
 	for rows.Next() {
 		order := repoEntity.Order{}
@@ -132,12 +162,16 @@ func (r *OrderRepository) GetOrders(ctx context.Context, userID string) ([]entit
 		err = rows.Scan(
 			&order.ID,
 			&order.UserID,
+			&order.ProgramID,
 			&order.Number,
 			&order.Status,
 			&order.Accrual,
+			&order.AccrualStatus,
+			&order.CheckedAt,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 			&order.DeletedAt,
+			&order.Precision,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestPrograms(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	balanceRepo := NewBalanceRepository(db)
+
+	partner, err := balanceRepo.AddProgram(ctx, &entity.Program{
+		ID:             "partner-" + uuid.NewString()[:8],
+		Name:           "Partner",
+		ConversionRate: decimal.NewFromInt(10),
+		Precision:      0,
+	})
+	require.NoError(t, err)
+
+	_, err = balanceRepo.AddProgram(ctx, partner)
+	assert.ErrorIs(t, err, entity.ErrProgramUniqueViolation)
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "user-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	_, err = NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Number:    uuid.NewString(),
+		ProgramID: "unknown-" + uuid.NewString()[:8],
+	})
+	assert.ErrorIs(t, err, entity.ErrProgramNotFound)
+
+	order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Number:    uuid.NewString(),
+		ProgramID: partner.ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, partner.ID, order.ProgramID)
+
+	// 12.34 accrual system points are 123 partner points.
+	order.Status = entity.StatusProcessed
+	order.Accrual = 1234
+	require.NoError(t, NewAccrualWorkerRepository(db).UpdateOrderAndUserBalance(ctx, *order))
+
+	err = balanceRepo.AddWithdrawal(ctx, &entity.WithdrawInfo{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		OrderNumber: uuid.NewString(),
+		Sum:         1,
+	}, nil)
+	assert.ErrorIs(t, err, entity.ErrNotEnoughPointsToWithdraw)
+
+	err = balanceRepo.AddWithdrawal(ctx, &entity.WithdrawInfo{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		OrderNumber: uuid.NewString(),
+		ProgramID:   partner.ID,
+		Sum:         23,
+	}, nil)
+	require.NoError(t, err)
+
+	balance, err := balanceRepo.GetUserBalance(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), balance.Balance)
+
+	var found bool
+	for _, programBalance := range balance.Programs {
+		if programBalance.Program.ID != partner.ID {
+			continue
+		}
+		found = true
+		assert.Equal(t, int64(100), programBalance.Balance)
+		assert.Equal(t, int64(23), programBalance.Withdrawn)
+	}
+	assert.True(t, found)
+
+	withdrawals, err := balanceRepo.GetWithdrawals(ctx, &entity.PageFilter{UserID: user.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, partner.ID, withdrawals[0].ProgramID)
+	assert.Equal(t, int32(0), withdrawals[0].Precision)
+}
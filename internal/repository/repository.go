@@ -1,5 +1,46 @@
 package repository
 
+import (
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
 const (
 	DefaultEntityCap = 100
 )
+
+// programPrecision selects the precision of the program of a table row, so amounts can be converted
+// to points without a join.
+func programPrecision(table string) string {
+	return "(SELECT decimal_places FROM programs WHERE programs.id = " + table + ".program_id)"
+}
+
+// withPage adds the time range, the cursor, the sort order and the limit of the page to a query
+// of a table with "created_at" and "id" columns.
+func withPage(query sq.SelectBuilder, page entity.PageFilter) sq.SelectBuilder {
+	if !page.From.IsZero() {
+		query = query.Where(sq.GtOrEq{"created_at": page.From})
+	}
+	if !page.To.IsZero() {
+		query = query.Where(sq.Lt{"created_at": page.To})
+	}
+
+	order, compare := "ASC", ">"
+	if page.Descending {
+		order, compare = "DESC", "<"
+	}
+
+	if page.AfterID != "" {
+		query = query.Where(sq.Expr("(created_at, id) "+compare+" (?, ?)", page.AfterTime, page.AfterID))
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultEntityCap
+	}
+
+	return query.
+		OrderBy("created_at "+order, "id "+order).
+		Limit(uint64(limit))
+}
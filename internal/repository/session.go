@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/storage/postgres"
+	repoEntity "github.com/ivas1ly/gophermart/internal/repository/entity"
+)
+
+type SessionRepository struct {
+	db *postgres.DB
+}
+
+func NewSessionRepository(db *postgres.DB) *SessionRepository {
+	return &SessionRepository{
+		db: db,
+	}
+}
+
+// AddSession saves the session with its first refresh token.
+func (r *SessionRepository) AddSession(ctx context.Context, sessionInfo *entity.SessionInfo) (*entity.Session,
+	error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx pgx.Tx) {
+		err = tx.Rollback(ctx)
+		if errors.Is(err, pgx.ErrTxClosed) {
+			return
+		}
+	}(tx)
+
+	queryInsertSession := r.db.Builder.
+		Insert("sessions").
+		Columns("id, user_id, expires_at").
+		Values(sessionInfo.ID, sessionInfo.UserID, sessionInfo.ExpiresAt).
+		Suffix("RETURNING id, user_id, created_at, expires_at, revoked_at")
+
+	sql, args, err := queryInsertSession.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &repoEntity.Session{}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertRefreshToken(ctx, tx, r.db.Builder, sessionInfo.ID, sessionInfo.RefreshTokenHash,
+		sessionInfo.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return repoEntity.ToSessionFromRepo(session), nil
+}
+
+// RotateRefreshToken marks the refresh token as used, saves the new one and extends the session.
+// A used token is presented again only if it was stolen or leaked, so the whole session is revoked
+// and ErrRefreshTokenReused is returned.
+func (r *SessionRepository) RotateRefreshToken(ctx context.Context, refreshInfo *entity.RefreshInfo) (*entity.Session,
+	error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx pgx.Tx) {
+		err = tx.Rollback(ctx)
+		if errors.Is(err, pgx.ErrTxClosed) {
+			return
+		}
+	}(tx)
+
+	querySelectToken := r.db.Builder.
+		Select("sessions.id, sessions.user_id, sessions.created_at, sessions.expires_at, sessions.revoked_at",
+			"refresh_tokens.expires_at, refresh_tokens.used_at").
+		From("refresh_tokens").
+		Join("sessions ON sessions.id = refresh_tokens.session_id").
+		Where(sq.Eq{
+			"refresh_tokens.token_hash": refreshInfo.TokenHash,
+		}).
+		Suffix("FOR UPDATE")
+
+	sql, args, err := querySelectToken.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &repoEntity.Session{}
+
+	var (
+		tokenExpiresAt time.Time
+		tokenUsedAt    pgtype.Timestamptz
+	)
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+		&tokenExpiresAt,
+		&tokenUsedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, entity.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if session.RevokedAt.Valid || !tokenExpiresAt.After(now) {
+		return nil, entity.ErrInvalidRefreshToken
+	}
+
+	if tokenUsedAt.Valid {
+		_, err = revokeSessions(ctx, tx, r.db.Builder, sq.Eq{"id": session.ID})
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Commit(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, entity.ErrRefreshTokenReused
+	}
+
+	queryUseToken := r.db.Builder.
+		Update("refresh_tokens").
+		Set("used_at", sq.Expr("now()")).
+		Where(sq.Eq{
+			"token_hash": refreshInfo.TokenHash,
+		})
+
+	sql, args, err = queryUseToken.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertRefreshToken(ctx, tx, r.db.Builder, session.ID, refreshInfo.NewTokenHash, refreshInfo.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	queryExtendSession := r.db.Builder.
+		Update("sessions").
+		Set("expires_at", refreshInfo.ExpiresAt).
+		Set("refreshed_at", sq.Expr("now()")).
+		Where(sq.Eq{
+			"id": session.ID,
+		})
+
+	sql, args, err = queryExtendSession.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session.ExpiresAt = refreshInfo.ExpiresAt
+
+	return repoEntity.ToSessionFromRepo(session), nil
+}
+
+// RevokeSession revokes the session of the user.
+func (r *SessionRepository) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	revoked, err := revokeSessions(ctx, r.db.Pool, r.db.Builder, sq.Eq{"id": sessionID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return entity.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes all sessions of the user and returns their number.
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, userID string) (int64, error) {
+	return revokeSessions(ctx, r.db.Pool, r.db.Builder, sq.Eq{"user_id": userID})
+}
+
+// IsSessionActive reports whether the session exists and is neither revoked nor expired.
+func (r *SessionRepository) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	query := r.db.Builder.
+		Select("1").
+		From("sessions").
+		Where(sq.Eq{
+			"id":         sessionID,
+			"revoked_at": nil,
+		}).
+		Where(sq.Expr("expires_at > now()"))
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var one int
+
+	err = r.db.Pool.QueryRow(ctx, sql, args...).Scan(&one)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteExpired removes sessions expired or revoked before the time with their refresh tokens.
+func (r *SessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := r.db.Builder.
+		Delete("sessions").
+		Where(sq.Or{
+			sq.Lt{"expires_at": before},
+			sq.Lt{"revoked_at": before},
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func insertRefreshToken(ctx context.Context, tx pgx.Tx, builder sq.StatementBuilderType, sessionID, tokenHash string,
+	expiresAt time.Time) error {
+	query := builder.
+		Insert("refresh_tokens").
+		Columns("token_hash, session_id, expires_at").
+		Values(tokenHash, sessionID, expiresAt)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+
+	return err
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func revokeSessions(ctx context.Context, db execer, builder sq.StatementBuilderType, where sq.Eq) (int64, error) {
+	query := builder.
+		Update("sessions").
+		Set("revoked_at", sq.Expr("now()")).
+		Where(where).
+		Where(sq.Eq{"revoked_at": nil})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := db.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestSessions(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	user, err := NewAuthRepository(db).AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "session-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	sessionRepo := NewSessionRepository(db)
+
+	newSession := func(t *testing.T, tokenHash string) *entity.Session {
+		session, err := sessionRepo.AddSession(ctx, &entity.SessionInfo{
+			ExpiresAt:        time.Now().Add(time.Hour),
+			ID:               uuid.NewString(),
+			UserID:           user.ID,
+			RefreshTokenHash: tokenHash,
+		})
+		require.NoError(t, err)
+
+		return session
+	}
+
+	t.Run("rotate refresh token", func(t *testing.T) {
+		first, second := uuid.NewString(), uuid.NewString()
+		session := newSession(t, first)
+
+		rotated, err := sessionRepo.RotateRefreshToken(ctx, &entity.RefreshInfo{
+			ExpiresAt:    time.Now().Add(2 * time.Hour),
+			TokenHash:    first,
+			NewTokenHash: second,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, session.ID, rotated.ID)
+
+		active, err := sessionRepo.IsSessionActive(ctx, session.ID)
+		require.NoError(t, err)
+		assert.True(t, active)
+
+		_, err = sessionRepo.RotateRefreshToken(ctx, &entity.RefreshInfo{
+			ExpiresAt:    time.Now().Add(2 * time.Hour),
+			TokenHash:    first,
+			NewTokenHash: uuid.NewString(),
+		})
+		assert.ErrorIs(t, err, entity.ErrRefreshTokenReused)
+
+		active, err = sessionRepo.IsSessionActive(ctx, session.ID)
+		require.NoError(t, err)
+		assert.False(t, active)
+
+		_, err = sessionRepo.RotateRefreshToken(ctx, &entity.RefreshInfo{
+			ExpiresAt:    time.Now().Add(2 * time.Hour),
+			TokenHash:    second,
+			NewTokenHash: uuid.NewString(),
+		})
+		assert.ErrorIs(t, err, entity.ErrInvalidRefreshToken)
+	})
+
+	t.Run("unknown refresh token", func(t *testing.T) {
+		_, err := sessionRepo.RotateRefreshToken(ctx, &entity.RefreshInfo{
+			ExpiresAt:    time.Now().Add(time.Hour),
+			TokenHash:    uuid.NewString(),
+			NewTokenHash: uuid.NewString(),
+		})
+		assert.ErrorIs(t, err, entity.ErrInvalidRefreshToken)
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		session := newSession(t, uuid.NewString())
+
+		require.NoError(t, sessionRepo.RevokeSession(ctx, user.ID, session.ID))
+		assert.ErrorIs(t, sessionRepo.RevokeSession(ctx, user.ID, session.ID), entity.ErrSessionNotFound)
+
+		active, err := sessionRepo.IsSessionActive(ctx, session.ID)
+		require.NoError(t, err)
+		assert.False(t, active)
+	})
+
+	t.Run("logout all sessions", func(t *testing.T) {
+		first := newSession(t, uuid.NewString())
+		second := newSession(t, uuid.NewString())
+
+		revoked, err := sessionRepo.RevokeUserSessions(ctx, user.ID)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, revoked, int64(2))
+
+		for _, session := range []*entity.Session{first, second} {
+			active, err := sessionRepo.IsSessionActive(ctx, session.ID)
+			require.NoError(t, err)
+			assert.False(t, active)
+		}
+	})
+}
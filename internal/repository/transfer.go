@@ -0,0 +1,418 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/storage/postgres"
+	repoEntity "github.com/ivas1ly/gophermart/internal/repository/entity"
+)
+
+type TransferRepository struct {
+	db *postgres.DB
+}
+
+func NewTransferRepository(db *postgres.DB) *TransferRepository {
+	return &TransferRepository{
+		db: db,
+	}
+}
+
+// AddTransfer moves the points to the recipient in one transaction. Both users are locked in
+// the same order by every transfer, so opposite transfers can't deadlock. A transfer with
+// the same idempotency key of the sender is returned as is and reported as replayed, the check
+// is skipped if it is nil.
+func (r *TransferRepository) AddTransfer(ctx context.Context, transferInfo *entity.TransferInfo,
+	check entity.TransferCheck) (*entity.Transfer, bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func(tx pgx.Tx) {
+		err = tx.Rollback(ctx)
+		if errors.Is(err, pgx.ErrTxClosed) {
+			return
+		}
+	}(tx)
+
+	if transferInfo.ProgramID == "" {
+		transferInfo.ProgramID = entity.DefaultProgramID
+	}
+
+	sender, recipient, err := r.lockUsers(ctx, tx, transferInfo.SenderID, transferInfo.Recipient)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, err := r.getTransferByKey(ctx, tx, transferInfo.SenderID, transferInfo.IdempotencyKey)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+	if err == nil {
+		if !existing.Matches(transferInfo) {
+			return nil, false, entity.ErrTransferKeyReused
+		}
+		return existing, true, nil
+	}
+
+	if sender.ID == recipient.ID {
+		return nil, false, entity.ErrTransferToSelf
+	}
+
+	err = r.checkAllowed(ctx, tx, recipient.ID, sender.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if check != nil {
+		stats, err := r.getTransferStats(ctx, tx, sender.ID, transferInfo.ProgramID)
+		if err != nil {
+			return nil, false, err
+		}
+
+		err = check(stats)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	outEntry := &entity.LedgerEntry{
+		UserID:    sender.ID,
+		ProgramID: transferInfo.ProgramID,
+		Reason:    fmt.Sprintf("transfer to %s", recipient.Username),
+		Kind:      entity.EntryTransferOut,
+		Amount:    -transferInfo.Sum,
+	}
+
+	err = insertLedgerEntry(ctx, tx, r.db.Builder, outEntry)
+	if err != nil {
+		return nil, false, err
+	}
+
+	inEntry := &entity.LedgerEntry{
+		UserID:      recipient.ID,
+		ProgramID:   transferInfo.ProgramID,
+		ReferenceID: outEntry.ID,
+		Reason:      fmt.Sprintf("transfer from %s", sender.Username),
+		Kind:        entity.EntryTransferIn,
+		Amount:      transferInfo.Sum,
+	}
+
+	err = insertLedgerEntry(ctx, tx, r.db.Builder, inEntry)
+	if err != nil {
+		return nil, false, err
+	}
+
+	queryInsertTransfer := r.db.Builder.
+		Insert("transfers").
+		Columns("id, sender_id, recipient_id, program_id, amount, idempotency_key, out_entry_id, in_entry_id").
+		Values(transferInfo.ID, sender.ID, recipient.ID, transferInfo.ProgramID, transferInfo.Sum,
+			transferInfo.IdempotencyKey, outEntry.ID, inEntry.ID).
+		Suffix("RETURNING created_at, " + programPrecision("transfers"))
+
+	sql, args, err := queryInsertTransfer.ToSql()
+	if err != nil {
+		return nil, false, err
+	}
+
+	transfer := &entity.Transfer{
+		ID:             transferInfo.ID,
+		SenderID:       sender.ID,
+		Sender:         sender.Username,
+		RecipientID:    recipient.ID,
+		Recipient:      recipient.Username,
+		ProgramID:      transferInfo.ProgramID,
+		IdempotencyKey: transferInfo.IdempotencyKey,
+		Amount:         transferInfo.Sum,
+	}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&transfer.CreatedAt, &transfer.Precision)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return transfer, false, nil
+}
+
+// lockUsers locks the sender and the recipient rows ordered by id until the end of the transaction.
+func (r *TransferRepository) lockUsers(ctx context.Context, tx pgx.Tx, senderID,
+	recipientUsername string) (*repoEntity.User, *repoEntity.User, error) {
+	query := r.db.Builder.
+		Select("id, username").
+		From("users").
+		Where(sq.Or{
+			sq.Eq{"id": senderID},
+			sq.Eq{"username": recipientUsername},
+		}).
+		OrderBy("id").
+		Suffix("FOR UPDATE")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var sender, recipient *repoEntity.User
+
+	for rows.Next() {
+		user := &repoEntity.User{}
+
+		err = rows.Scan(&user.ID, &user.Username)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if user.ID == senderID {
+			sender = user
+		}
+		if user.Username == recipientUsername {
+			recipient = user
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if sender == nil {
+		return nil, nil, entity.ErrCanNotUpdateUserBalance
+	}
+	if recipient == nil {
+		return nil, nil, entity.ErrRecipientNotFound
+	}
+
+	return sender, recipient, nil
+}
+
+func (r *TransferRepository) getTransferByKey(ctx context.Context, tx pgx.Tx, senderID,
+	key string) (*entity.Transfer, error) {
+	query := r.db.Builder.
+		Select("transfers.id, transfers.sender_id, senders.username, transfers.recipient_id",
+			"recipients.username, transfers.program_id, transfers.amount, transfers.idempotency_key",
+			"transfers.created_at", programPrecision("transfers")).
+		From("transfers").
+		Join("users senders ON senders.id = transfers.sender_id").
+		Join("users recipients ON recipients.id = transfers.recipient_id").
+		Where(sq.Eq{
+			"transfers.sender_id":       senderID,
+			"transfers.idempotency_key": key,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &entity.Transfer{}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(
+		&transfer.ID,
+		&transfer.SenderID,
+		&transfer.Sender,
+		&transfer.RecipientID,
+		&transfer.Recipient,
+		&transfer.ProgramID,
+		&transfer.Amount,
+		&transfer.IdempotencyKey,
+		&transfer.CreatedAt,
+		&transfer.Precision,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+func (r *TransferRepository) checkAllowed(ctx context.Context, tx pgx.Tx, recipientID, senderID string) error {
+	query := r.db.Builder.
+		Select("1").
+		From("transfer_allowlist").
+		Where(sq.Eq{
+			"user_id":         recipientID,
+			"allowed_user_id": senderID,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var allowed int
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&allowed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entity.ErrTransferNotAllowed
+	}
+
+	return err
+}
+
+// getTransferStats sums the transfers of the sender in the program within the last day.
+func (r *TransferRepository) getTransferStats(ctx context.Context, tx pgx.Tx, senderID,
+	programID string) (*entity.TransferStats, error) {
+	program, err := getProgram(ctx, tx, r.db.Builder, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.db.Builder.
+		Select("COALESCE(SUM(amount), 0)").
+		From("transfers").
+		Where(sq.Eq{
+			"sender_id":  senderID,
+			"program_id": programID,
+		}).
+		Where("created_at > now() - interval '1 day'")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &entity.TransferStats{Program: *program}
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&stats.DaySum)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// AllowSender lets the user with the username transfer points to the user.
+func (r *TransferRepository) AllowSender(ctx context.Context, userID, username string) error {
+	allowedUserID, err := r.getUserID(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	query := r.db.Builder.
+		Insert("transfer_allowlist").
+		Columns("user_id, allowed_user_id").
+		Values(userID, allowedUserID).
+		Suffix("ON CONFLICT DO NOTHING")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Pool.Exec(ctx, sql, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return entity.ErrTransferToSelf
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *TransferRepository) DisallowSender(ctx context.Context, userID, username string) error {
+	allowedUserID, err := r.getUserID(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	query := r.db.Builder.
+		Delete("transfer_allowlist").
+		Where(sq.Eq{
+			"user_id":         userID,
+			"allowed_user_id": allowedUserID,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Pool.Exec(ctx, sql, args...)
+
+	return err
+}
+
+// GetAllowedSenders returns the usernames allowed to transfer points to the user.
+func (r *TransferRepository) GetAllowedSenders(ctx context.Context, userID string) ([]string, error) {
+	query := r.db.Builder.
+		Select("users.username").
+		From("transfer_allowlist").
+		Join("users ON users.id = transfer_allowlist.allowed_user_id").
+		Where(sq.Eq{
+			"transfer_allowlist.user_id": userID,
+		}).
+		OrderBy("users.username")
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usernames := make([]string, 0)
+
+	for rows.Next() {
+		var username string
+
+		err = rows.Scan(&username)
+		if err != nil {
+			return nil, err
+		}
+
+		usernames = append(usernames, username)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return usernames, nil
+}
+
+func (r *TransferRepository) getUserID(ctx context.Context, username string) (string, error) {
+	query := r.db.Builder.
+		Select("id").
+		From("users").
+		Where(sq.Eq{
+			"username": username,
+		})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return "", err
+	}
+
+	var userID string
+
+	err = r.db.Pool.QueryRow(ctx, sql, args...).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", entity.ErrAllowedUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+func TestTransfer(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	authRepo := NewAuthRepository(db)
+
+	sender, err := authRepo.AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "sender-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	recipient, err := authRepo.AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "recipient-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+		ID:     uuid.NewString(),
+		UserID: sender.ID,
+		Number: uuid.NewString(),
+	})
+	require.NoError(t, err)
+
+	order.Status = entity.StatusProcessed
+	order.Accrual = 10000
+	require.NoError(t, NewAccrualWorkerRepository(db).UpdateOrderAndUserBalance(ctx, *order))
+
+	transferRepo := NewTransferRepository(db)
+	balanceRepo := NewBalanceRepository(db)
+
+	transferInfo := &entity.TransferInfo{
+		ID:             uuid.NewString(),
+		SenderID:       sender.ID,
+		Recipient:      recipient.Username,
+		IdempotencyKey: uuid.NewString(),
+		Sum:            2500,
+	}
+
+	_, _, err = transferRepo.AddTransfer(ctx, transferInfo, nil)
+	assert.ErrorIs(t, err, entity.ErrTransferNotAllowed)
+
+	require.NoError(t, transferRepo.AllowSender(ctx, recipient.ID, sender.Username))
+
+	transfer, replayed, err := transferRepo.AddTransfer(ctx, transferInfo, nil)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, recipient.ID, transfer.RecipientID)
+
+	t.Run("same key returns the transfer", func(t *testing.T) {
+		repeated, replayed, err := transferRepo.AddTransfer(ctx, &entity.TransferInfo{
+			ID:             uuid.NewString(),
+			SenderID:       sender.ID,
+			Recipient:      recipient.Username,
+			IdempotencyKey: transferInfo.IdempotencyKey,
+			Sum:            2500,
+		}, nil)
+		require.NoError(t, err)
+		assert.True(t, replayed)
+		assert.Equal(t, transfer.ID, repeated.ID)
+	})
+
+	t.Run("same key with another sum", func(t *testing.T) {
+		_, _, err := transferRepo.AddTransfer(ctx, &entity.TransferInfo{
+			ID:             uuid.NewString(),
+			SenderID:       sender.ID,
+			Recipient:      recipient.Username,
+			IdempotencyKey: transferInfo.IdempotencyKey,
+			Sum:            1,
+		}, nil)
+		assert.ErrorIs(t, err, entity.ErrTransferKeyReused)
+	})
+
+	t.Run("not enough points", func(t *testing.T) {
+		_, _, err := transferRepo.AddTransfer(ctx, &entity.TransferInfo{
+			ID:             uuid.NewString(),
+			SenderID:       sender.ID,
+			Recipient:      recipient.Username,
+			IdempotencyKey: uuid.NewString(),
+			Sum:            100000,
+		}, nil)
+		assert.ErrorIs(t, err, entity.ErrNotEnoughPointsToWithdraw)
+	})
+
+	t.Run("balances and history of both users", func(t *testing.T) {
+		senderBalance, err := balanceRepo.GetUserBalance(ctx, sender.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7500), senderBalance.Balance)
+
+		recipientBalance, err := balanceRepo.GetUserBalance(ctx, recipient.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2500), recipientBalance.Balance)
+
+		transactions, err := balanceRepo.GetTransactions(ctx, &entity.TransactionFilter{
+			PageFilter: entity.PageFilter{UserID: recipient.ID, Limit: 10},
+		})
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		assert.Equal(t, entity.EntryTransferIn, transactions[0].Kind)
+	})
+}
+
+func TestTransferKeepsExpiry(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	authRepo := NewAuthRepository(db)
+
+	sender, err := authRepo.AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "sender-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	recipient, err := authRepo.AddUser(ctx, &entity.UserInfo{
+		ID:       uuid.NewString(),
+		Username: "recipient-" + uuid.NewString(),
+		Hash:     "hash",
+	})
+	require.NoError(t, err)
+
+	order, err := NewOrderRepository(db).AddOrder(ctx, &entity.OrderInfo{
+		ID:     uuid.NewString(),
+		UserID: sender.ID,
+		Number: uuid.NewString(),
+	})
+	require.NoError(t, err)
+
+	order.Status = entity.StatusProcessed
+	order.Accrual = 10000
+	require.NoError(t, NewAccrualWorkerRepository(db).UpdateOrderAndUserBalance(ctx, *order))
+
+	_, err = db.Pool.Exec(ctx, `UPDATE accrual_lots SET credited_at = now() - interval '1 year'
+		WHERE user_id = $1`, sender.ID)
+	require.NoError(t, err)
+
+	transferRepo := NewTransferRepository(db)
+	require.NoError(t, transferRepo.AllowSender(ctx, recipient.ID, sender.Username))
+	require.NoError(t, transferRepo.AllowSender(ctx, sender.ID, recipient.Username))
+
+	_, _, err = transferRepo.AddTransfer(ctx, &entity.TransferInfo{
+		ID:             uuid.NewString(),
+		SenderID:       sender.ID,
+		Recipient:      recipient.Username,
+		IdempotencyKey: uuid.NewString(),
+		Sum:            4000,
+	}, nil)
+	require.NoError(t, err)
+
+	_, _, err = transferRepo.AddTransfer(ctx, &entity.TransferInfo{
+		ID:             uuid.NewString(),
+		SenderID:       recipient.ID,
+		Recipient:      sender.Username,
+		IdempotencyKey: uuid.NewString(),
+		Sum:            1000,
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = NewLedgerRepository(db).ExpireLots(ctx, time.Now().AddDate(0, -1, 0), DefaultEntityCap)
+	require.NoError(t, err)
+
+	balanceRepo := NewBalanceRepository(db)
+
+	for _, userID := range []string{sender.ID, recipient.ID} {
+		balance, err := balanceRepo.GetUserBalance(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), balance.Balance, "transferred points must expire with their credit")
+	}
+}
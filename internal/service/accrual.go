@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type AccrualRepository interface {
+	GetOrderByNumber(ctx context.Context, number string) (*entity.Order, error)
+	UpdateOrderAndUserBalance(ctx context.Context, order entity.Order) error
+	UpdateAccrualStatus(ctx context.Context, order entity.Order, nextAttemptAt time.Time) error
+}
+
+type AccrualService struct {
+	accrualRepository AccrualRepository
+	pollDelay         time.Duration
+}
+
+// NewAccrualService creates the service applying accrual system callbacks. Orders with
+// a non-final status are polled by the worker only if no callback comes within pollDelay.
+func NewAccrualService(accrualRepository AccrualRepository, pollDelay time.Duration) *AccrualService {
+	return &AccrualService{
+		accrualRepository: accrualRepository,
+		pollDelay:         pollDelay,
+	}
+}
+
+func (s *AccrualService) ApplyCallback(ctx context.Context, accrualInfo *entity.AccrualInfo) error {
+	status := entity.ParseStatus(accrualInfo.Status)
+	if status == entity.StatusUnknown || status == entity.StatusNew {
+		return entity.ErrUnknownOrderStatus
+	}
+
+	order, err := s.accrualRepository.GetOrderByNumber(ctx, accrualInfo.Number)
+	if err != nil {
+		return err
+	}
+
+	if order.Status.IsFinal() {
+		return entity.ErrOrderAlreadyFinal
+	}
+
+	checkedAt := time.Now()
+	order.AccrualStatus = accrualInfo.Status
+	order.CheckedAt = &checkedAt
+
+	if !status.IsFinal() {
+		order.ExpectedAccrual = accrualInfo.Accrual
+		return s.accrualRepository.UpdateAccrualStatus(ctx, *order, checkedAt.Add(s.pollDelay))
+	}
+
+	order.Status = status
+	order.Accrual = 0
+	if status == entity.StatusProcessed {
+		order.Accrual = accrualInfo.Accrual
+	}
+
+	return s.accrualRepository.UpdateOrderAndUserBalance(ctx, *order)
+}
@@ -2,25 +2,126 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 
 	"github.com/ivas1ly/gophermart/internal/entity"
 	"github.com/ivas1ly/gophermart/pkg/argon2id"
+	"github.com/ivas1ly/gophermart/pkg/throttle"
+)
+
+const (
+	secretTokenLength = 32
+
+	dummyPassword = "dummy password"
+
+	// loginResetAfter is the time after the last failed login when the failures are forgotten.
+	loginResetAfter = 1 * time.Hour
 )
 
 type AuthRepository interface {
 	AddUser(ctx context.Context, userInfo *entity.UserInfo) (*entity.User, error)
 	FindUser(ctx context.Context, username string) (*entity.User, error)
+	FindUserByID(ctx context.Context, userID string) (*entity.User, error)
+	UpdatePassword(ctx context.Context, userID, hash string) error
+	RehashPassword(ctx context.Context, userID, oldHash, newHash string) error
+	AddPasswordReset(ctx context.Context, reset *entity.PasswordReset) error
+	FindPasswordReset(ctx context.Context, tokenHash string) (*entity.User, error)
+	ResetPassword(ctx context.Context, tokenHash, hash string) error
+}
+
+type SessionRepository interface {
+	AddSession(ctx context.Context, sessionInfo *entity.SessionInfo) (*entity.Session, error)
+	RotateRefreshToken(ctx context.Context, refreshInfo *entity.RefreshInfo) (*entity.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	RevokeUserSessions(ctx context.Context, userID string) (int64, error)
+	IsSessionActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+type TokenSigner interface {
+	NewToken(id, sessionID string, ttl time.Duration) (string, error)
+}
+
+type PasswordResetNotifier interface {
+	NotifyPasswordReset(ctx context.Context, reset *entity.PasswordReset) error
+}
+
+// AttemptLimiter counts every attempt as failed before it is made, a successful attempt is taken
+// back by Refund or Reset.
+type AttemptLimiter interface {
+	Reserve(key string) time.Duration
+	Refund(key string)
+	Reset(key string)
 }
 
 type AuthService struct {
-	authRepository AuthRepository
+	authRepository    AuthRepository
+	sessionRepository SessionRepository
+	tokenSigner       TokenSigner
+	notifier          PasswordResetNotifier
+	userLimiter       AttemptLimiter
+	ipLimiter         AttemptLimiter
+	resetUserLimiter  AttemptLimiter
+	resetIPLimiter    AttemptLimiter
+	hashing           chan struct{}
+	dummyHash         string
+	passwordPolicy    entity.PasswordPolicy
+	sessionPolicy     entity.SessionPolicy
+	hashingWait       time.Duration
 }
 
-func NewAuthService(authRepository AuthRepository) *AuthService {
+// NewAuthService returns the auth service, password reset is disabled if the notifier is nil.
+// Passwords are hashed with argon2id.DefaultParams if the policy has no hash params.
+func NewAuthService(authRepository AuthRepository, sessionRepository SessionRepository, tokenSigner TokenSigner,
+	notifier PasswordResetNotifier, sessionPolicy entity.SessionPolicy, loginPolicy entity.LoginPolicy,
+	passwordPolicy entity.PasswordPolicy) *AuthService {
+	if passwordPolicy.HashParams == nil {
+		passwordPolicy.HashParams = argon2id.DefaultParams
+	}
+
+	userSettings := throttle.Settings{
+		FreeAttempts:    loginPolicy.UserFreeAttempts,
+		MaxAttempts:     loginPolicy.UserMaxAttempts,
+		BaseDelay:       loginPolicy.BaseDelay,
+		LockoutDuration: loginPolicy.LockoutDuration,
+		ResetAfter:      loginResetAfter,
+	}
+	ipSettings := throttle.Settings{
+		FreeAttempts:    loginPolicy.IPFreeAttempts,
+		MaxAttempts:     loginPolicy.IPMaxAttempts,
+		BaseDelay:       loginPolicy.BaseDelay,
+		LockoutDuration: loginPolicy.LockoutDuration,
+		ResetAfter:      loginResetAfter,
+	}
+
+	// The password of an unknown username is compared with the dummy hash, so the login takes
+	// the same time for unknown and existing users.
+	dummyHash, err := argon2id.CreateHash(dummyPassword, passwordPolicy.HashParams)
+	if err != nil {
+		zap.L().Warn("can't create dummy password hash", zap.Error(err))
+	}
+
 	return &AuthService{
-		authRepository: authRepository,
+		authRepository:    authRepository,
+		sessionRepository: sessionRepository,
+		tokenSigner:       tokenSigner,
+		notifier:          notifier,
+		userLimiter:       throttle.New(userSettings),
+		ipLimiter:         throttle.New(ipSettings),
+		resetUserLimiter:  throttle.New(userSettings),
+		resetIPLimiter:    throttle.New(ipSettings),
+		hashing:           make(chan struct{}, max(loginPolicy.MaxHashing, 1)),
+		dummyHash:         dummyHash,
+		passwordPolicy:    passwordPolicy,
+		sessionPolicy:     sessionPolicy,
+		hashingWait:       loginPolicy.HashingWait,
 	}
 }
 
@@ -30,7 +131,12 @@ func (s *AuthService) Register(ctx context.Context, username, password string) (
 		return nil, err
 	}
 
-	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	err = s.passwordPolicy.Check(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := s.hashPassword(ctx, password)
 	if err != nil {
 		return nil, err
 	}
@@ -49,19 +155,345 @@ func (s *AuthService) Register(ctx context.Context, username, password string) (
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, username, password string) (*entity.User, error) {
-	user, err := s.authRepository.FindUser(ctx, username)
+// Login checks the password of the user. Attempts are counted per username and per IP before
+// the password is checked, an attempt while either of them is blocked returns ThrottleError.
+// The password of a hash with outdated params is hashed again with the current ones.
+func (s *AuthService) Login(ctx context.Context, loginInfo *entity.LoginInfo) (*entity.User, error) {
+	wait := s.reserve(loginInfo.Username, loginInfo.RemoteIP)
+	if wait > 0 {
+		return nil, &entity.ThrottleError{RetryAfter: wait}
+	}
+
+	user, err := s.authRepository.FindUser(ctx, loginInfo.Username)
+	if errors.Is(err, entity.ErrUsernameNotFound) {
+		if err = s.compareDummyHash(ctx, loginInfo.Password); err != nil {
+			s.refund(loginInfo.Username, loginInfo.RemoteIP)
+			return nil, err
+		}
+		return nil, entity.ErrUsernameNotFound
+	}
 	if err != nil {
+		s.refund(loginInfo.Username, loginInfo.RemoteIP)
 		return nil, err
 	}
 
-	ok, err := argon2id.ComparePasswordAndHash(password, user.Hash)
+	release, err := s.acquireHashing(ctx)
+	if err != nil {
+		s.refund(loginInfo.Username, loginInfo.RemoteIP)
+		return nil, err
+	}
+
+	ok, hashParams, err := argon2id.CheckHash(loginInfo.Password, user.Hash)
+	release()
 	if !ok {
 		return nil, entity.ErrIncorrectLoginOrPassword
 	}
 	if err != nil {
+		s.refund(loginInfo.Username, loginInfo.RemoteIP)
 		return nil, err
 	}
 
+	// Only this attempt is taken back from the IP, its other failures are kept, otherwise one
+	// known password would hide guessing of others.
+	s.userLimiter.Reset(loginInfo.Username)
+	s.ipLimiter.Refund(loginInfo.RemoteIP)
+
+	if argon2id.NeedsRehash(hashParams, s.passwordPolicy.HashParams) {
+		// The old hash still works, so the login doesn't fail if it can't be replaced now.
+		if err = s.rehashPassword(ctx, user, loginInfo.Password); err != nil {
+			zap.L().Warn("can't rehash password", zap.String("user_id", user.ID), zap.Error(err))
+		}
+	}
+
 	return user, nil
 }
+
+// ChangePassword sets the new password if the current one is correct. All sessions of the user
+// are revoked and the tokens of a new session are returned. Wrong current passwords are
+// throttled like failed logins of the user.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, currentPassword,
+	newPassword string) (*entity.Tokens, error) {
+	user, err := s.authRepository.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if wait := s.userLimiter.Reserve(user.Username); wait > 0 {
+		return nil, &entity.ThrottleError{RetryAfter: wait}
+	}
+
+	release, err := s.acquireHashing(ctx)
+	if err != nil {
+		s.userLimiter.Refund(user.Username)
+		return nil, err
+	}
+
+	ok, err := argon2id.ComparePasswordAndHash(currentPassword, user.Hash)
+	release()
+	if !ok {
+		return nil, entity.ErrIncorrectPassword
+	}
+	s.userLimiter.Refund(user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.passwordPolicy.Check(user.Username, newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := s.hashPassword(ctx, newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authRepository.UpdatePassword(ctx, user.ID, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.NewSession(ctx, user.ID)
+}
+
+// RequestPasswordReset sends a reset token to the user by the notifier. Unknown usernames are
+// ignored, so the response doesn't tell which users exist. Every request is counted per username
+// and per IP like a failed login, but separately from the logins, so requests for somebody else
+// don't lock the user out.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, username, remoteIP string) error {
+	if wait := s.resetUserLimiter.Reserve(username); wait > 0 {
+		return &entity.ThrottleError{RetryAfter: wait}
+	}
+	if wait := s.resetIPLimiter.Reserve(remoteIP); wait > 0 {
+		s.resetUserLimiter.Refund(username)
+		return &entity.ThrottleError{RetryAfter: wait}
+	}
+
+	user, err := s.authRepository.FindUser(ctx, username)
+	if errors.Is(err, entity.ErrUsernameNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, tokenHash, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	reset := &entity.PasswordReset{
+		ExpiresAt: time.Now().Add(s.passwordPolicy.ResetTTL),
+		UserID:    user.ID,
+		Username:  user.Username,
+		Token:     token,
+		TokenHash: tokenHash,
+	}
+
+	err = s.authRepository.AddPasswordReset(ctx, reset)
+	if err != nil {
+		return err
+	}
+
+	return s.notifier.NotifyPasswordReset(ctx, reset)
+}
+
+// ResetPassword sets the new password by the reset token and revokes all sessions of the user.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	tokenHash := hashSecretToken(token)
+
+	user, err := s.authRepository.FindPasswordReset(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	err = s.passwordPolicy.Check(user.Username, newPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := s.hashPassword(ctx, newPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.authRepository.ResetPassword(ctx, tokenHash, hash)
+}
+
+// reserve counts the login attempt of the username from the IP. Nothing is counted if either
+// of them is blocked, the longest wait is returned.
+func (s *AuthService) reserve(username, remoteIP string) time.Duration {
+	if wait := s.userLimiter.Reserve(username); wait > 0 {
+		return wait
+	}
+
+	if wait := s.ipLimiter.Reserve(remoteIP); wait > 0 {
+		s.userLimiter.Refund(username)
+		return wait
+	}
+
+	return 0
+}
+
+// refund takes back the login attempt that failed for a reason other than the credentials.
+func (s *AuthService) refund(username, remoteIP string) {
+	s.userLimiter.Refund(username)
+	s.ipLimiter.Refund(remoteIP)
+}
+
+func (s *AuthService) compareDummyHash(ctx context.Context, password string) error {
+	release, err := s.acquireHashing(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	_, _ = argon2id.ComparePasswordAndHash(password, s.dummyHash)
+
+	return nil
+}
+
+func (s *AuthService) hashPassword(ctx context.Context, password string) (string, error) {
+	release, err := s.acquireHashing(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return argon2id.CreateHash(password, s.passwordPolicy.HashParams)
+}
+
+// rehashPassword replaces the hash of the user with a hash of the same password created with
+// the current params.
+func (s *AuthService) rehashPassword(ctx context.Context, user *entity.User, password string) error {
+	hash, err := s.hashPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	return s.authRepository.RehashPassword(ctx, user.ID, user.Hash, hash)
+}
+
+// acquireHashing limits the number of password hashes computed at once, each of them takes
+// a lot of memory. It waits for a free slot up to the hashing wait.
+func (s *AuthService) acquireHashing(ctx context.Context) (func(), error) {
+	release := func() { <-s.hashing }
+
+	select {
+	case s.hashing <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	timer := time.NewTimer(s.hashingWait)
+	defer timer.Stop()
+
+	select {
+	case s.hashing <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, &entity.ThrottleError{RetryAfter: time.Second}
+	}
+}
+
+// NewSession starts a new session of the user and returns its first tokens.
+func (s *AuthService) NewSession(ctx context.Context, userID string) (*entity.Tokens, error) {
+	sessionUUID, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, tokenHash, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionInfo := &entity.SessionInfo{
+		ExpiresAt:        time.Now().Add(s.sessionPolicy.RefreshTTL),
+		ID:               sessionUUID.String(),
+		UserID:           userID,
+		RefreshTokenHash: tokenHash,
+	}
+
+	session, err := s.sessionRepository.AddSession(ctx, sessionInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newTokens(session, refreshToken)
+}
+
+// Refresh exchanges the refresh token for new tokens of the same session, the token can be
+// used only once.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*entity.Tokens, error) {
+	newToken, newTokenHash, err := newSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshInfo := &entity.RefreshInfo{
+		ExpiresAt:    time.Now().Add(s.sessionPolicy.RefreshTTL),
+		TokenHash:    hashSecretToken(refreshToken),
+		NewTokenHash: newTokenHash,
+	}
+
+	session, err := s.sessionRepository.RotateRefreshToken(ctx, refreshInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newTokens(session, newToken)
+}
+
+// Logout revokes the session, its access and refresh tokens are rejected from now on.
+func (s *AuthService) Logout(ctx context.Context, userID, sessionID string) error {
+	return s.sessionRepository.RevokeSession(ctx, userID, sessionID)
+}
+
+// LogoutAll revokes all sessions of the user and returns their number.
+func (s *AuthService) LogoutAll(ctx context.Context, userID string) (int64, error) {
+	return s.sessionRepository.RevokeUserSessions(ctx, userID)
+}
+
+func (s *AuthService) IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	return s.sessionRepository.IsSessionActive(ctx, sessionID)
+}
+
+func (s *AuthService) newTokens(session *entity.Session, refreshToken string) (*entity.Tokens, error) {
+	accessExpiresAt := time.Now().Add(s.sessionPolicy.AccessTTL)
+
+	accessToken, err := s.tokenSigner.NewToken(session.UserID, session.ID, s.sessionPolicy.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.Tokens{
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+	}, nil
+}
+
+// newSecretToken returns a random opaque token for refresh or password reset and its hash,
+// only the hash is stored.
+func newSecretToken() (string, string, error) {
+	buf := make([]byte, secretTokenLength)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	return token, hashSecretToken(token), nil
+}
+
+func hashSecretToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
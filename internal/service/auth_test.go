@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/pkg/argon2id"
+)
+
+type loginRepository struct {
+	AuthRepository
+	user *entity.User
+}
+
+func (r *loginRepository) FindUserByID(_ context.Context, userID string) (*entity.User, error) {
+	if userID != r.user.ID {
+		return nil, entity.ErrUsernameNotFound
+	}
+	return r.user, nil
+}
+
+func (r *loginRepository) UpdatePassword(_ context.Context, _, hash string) error {
+	r.user.Hash = hash
+	return nil
+}
+
+func (r *loginRepository) RehashPassword(_ context.Context, _, oldHash, newHash string) error {
+	if r.user.Hash == oldHash {
+		r.user.Hash = newHash
+	}
+	return nil
+}
+
+type passwordSessionRepository struct {
+	SessionRepository
+}
+
+func (r *passwordSessionRepository) AddSession(_ context.Context, sessionInfo *entity.SessionInfo) (*entity.Session,
+	error) {
+	return &entity.Session{ID: sessionInfo.ID, UserID: sessionInfo.UserID, ExpiresAt: sessionInfo.ExpiresAt}, nil
+}
+
+type tokenSigner struct{}
+
+func (tokenSigner) NewToken(id, sessionID string, _ time.Duration) (string, error) {
+	return id + "." + sessionID, nil
+}
+
+func (r *loginRepository) FindUser(_ context.Context, username string) (*entity.User, error) {
+	if username != r.user.Username {
+		return nil, entity.ErrUsernameNotFound
+	}
+	return r.user, nil
+}
+
+func TestLoginThrottling(t *testing.T) {
+	hash, err := argon2id.CreateHash("password", argon2id.DefaultParams)
+	require.NoError(t, err)
+
+	repo := &loginRepository{user: &entity.User{ID: "user", Username: "gopher", Hash: hash}}
+
+	newService := func(policy entity.LoginPolicy) *AuthService {
+		return NewAuthService(repo, nil, nil, nil, entity.SessionPolicy{}, policy, entity.PasswordPolicy{})
+	}
+
+	login := func(s *AuthService, username, password, ip string) error {
+		_, err := s.Login(context.Background(), &entity.LoginInfo{
+			Username: username,
+			Password: password,
+			RemoteIP: ip,
+		})
+		return err
+	}
+
+	t.Run("username is locked out", func(t *testing.T) {
+		s := newService(entity.LoginPolicy{
+			UserFreeAttempts: 1,
+			UserMaxAttempts:  2,
+			IPMaxAttempts:    100,
+			BaseDelay:        time.Minute,
+			LockoutDuration:  time.Hour,
+		})
+
+		assert.ErrorIs(t, login(s, "gopher", "wrong", "10.0.0.1"), entity.ErrIncorrectLoginOrPassword)
+		assert.ErrorIs(t, login(s, "gopher", "wrong", "10.0.0.2"), entity.ErrIncorrectLoginOrPassword)
+
+		err := login(s, "gopher", "password", "10.0.0.3")
+		var throttleErr *entity.ThrottleError
+		require.True(t, errors.As(err, &throttleErr))
+		assert.Greater(t, throttleErr.RetryAfter, 59*time.Minute)
+	})
+
+	t.Run("ip is delayed for unknown usernames", func(t *testing.T) {
+		s := newService(entity.LoginPolicy{
+			UserMaxAttempts: 100,
+			IPFreeAttempts:  1,
+			IPMaxAttempts:   100,
+			BaseDelay:       time.Minute,
+			LockoutDuration: time.Hour,
+		})
+
+		assert.ErrorIs(t, login(s, "first", "password", "10.0.0.1"), entity.ErrUsernameNotFound)
+		assert.ErrorIs(t, login(s, "second", "password", "10.0.0.1"), entity.ErrUsernameNotFound)
+		assert.ErrorIs(t, login(s, "gopher", "password", "10.0.0.1"), entity.ErrTooManyAttempts)
+		assert.NoError(t, login(s, "gopher", "password", "10.0.0.2"))
+	})
+
+	t.Run("successful login resets username failures", func(t *testing.T) {
+		s := newService(entity.LoginPolicy{
+			UserFreeAttempts: 1,
+			UserMaxAttempts:  100,
+			IPFreeAttempts:   100,
+			IPMaxAttempts:    100,
+			BaseDelay:        time.Minute,
+			LockoutDuration:  time.Hour,
+		})
+
+		assert.ErrorIs(t, login(s, "gopher", "wrong", "10.0.0.1"), entity.ErrIncorrectLoginOrPassword)
+		assert.NoError(t, login(s, "gopher", "password", "10.0.0.1"))
+		// Without the reset it would be the second penalized failure.
+		assert.ErrorIs(t, login(s, "gopher", "wrong", "10.0.0.1"), entity.ErrIncorrectLoginOrPassword)
+		assert.NoError(t, login(s, "gopher", "password", "10.0.0.1"))
+	})
+
+	t.Run("busy hashing", func(t *testing.T) {
+		s := newService(entity.LoginPolicy{
+			UserMaxAttempts: 100,
+			IPMaxAttempts:   100,
+			MaxHashing:      1,
+			HashingWait:     10 * time.Millisecond,
+		})
+
+		release, err := s.acquireHashing(context.Background())
+		require.NoError(t, err)
+		defer release()
+
+		assert.ErrorIs(t, login(s, "gopher", "password", "10.0.0.1"), entity.ErrTooManyAttempts)
+		assert.ErrorIs(t, login(s, "unknown", "password", "10.0.0.1"), entity.ErrTooManyAttempts,
+			"unknown usernames must be hashed too")
+	})
+
+	t.Run("concurrent attempts are counted", func(t *testing.T) {
+		s := newService(entity.LoginPolicy{
+			UserFreeAttempts: 1,
+			UserMaxAttempts:  2,
+			IPFreeAttempts:   100,
+			IPMaxAttempts:    100,
+			BaseDelay:        time.Minute,
+			LockoutDuration:  time.Hour,
+			MaxHashing:       2,
+			HashingWait:      time.Minute,
+		})
+
+		const attempts = 8
+
+		errs := make(chan error, attempts)
+		for i := 0; i < attempts; i++ {
+			go func() {
+				errs <- login(s, "gopher", "wrong", "10.0.0.1")
+			}()
+		}
+
+		checked := 0
+		for i := 0; i < attempts; i++ {
+			if errors.Is(<-errs, entity.ErrIncorrectLoginOrPassword) {
+				checked++
+			}
+		}
+		assert.Equal(t, 2, checked)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	hash, err := argon2id.CreateHash("password", argon2id.DefaultParams)
+	require.NoError(t, err)
+
+	repo := &loginRepository{user: &entity.User{ID: "user", Username: "gopher", Hash: hash}}
+
+	authService := NewAuthService(repo, &passwordSessionRepository{}, tokenSigner{}, nil, entity.SessionPolicy{},
+		entity.LoginPolicy{UserFreeAttempts: 5, UserMaxAttempts: 10, IPMaxAttempts: 10},
+		entity.PasswordPolicy{
+			MinLength: 9,
+			Breached:  map[string]struct{}{"qwerty12345": {}},
+		})
+
+	t.Run("incorrect current password", func(t *testing.T) {
+		_, err := authService.ChangePassword(context.Background(), "user", "wrong", "new password")
+		assert.ErrorIs(t, err, entity.ErrIncorrectPassword)
+	})
+
+	t.Run("password policy", func(t *testing.T) {
+		for password, rule := range map[string]string{
+			"short":         entity.PasswordRuleMinLength,
+			"QWERTY12345":   entity.PasswordRuleBreached,
+			"my gopher pwd": entity.PasswordRuleContainsUsername,
+		} {
+			_, err := authService.ChangePassword(context.Background(), "user", "password", password)
+
+			var violation *entity.PasswordViolation
+			require.True(t, errors.As(err, &violation), password)
+			assert.ErrorIs(t, err, entity.ErrWeakPassword)
+			assert.Equal(t, rule, violation.Rule)
+		}
+	})
+
+	t.Run("password changed", func(t *testing.T) {
+		tokens, err := authService.ChangePassword(context.Background(), "user", "password", "new password")
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.RefreshToken)
+
+		ok, err := argon2id.ComparePasswordAndHash("new password", repo.user.Hash)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestLoginRehash(t *testing.T) {
+	oldParams := &argon2id.Params{Memory: 1024, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+	newParams := &argon2id.Params{Memory: 2048, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	hash, err := argon2id.CreateHash("password", oldParams)
+	require.NoError(t, err)
+
+	repo := &loginRepository{user: &entity.User{ID: "user", Username: "gopher", Hash: hash}}
+
+	authService := NewAuthService(repo, nil, nil, nil, entity.SessionPolicy{},
+		entity.LoginPolicy{UserMaxAttempts: 10, IPMaxAttempts: 10},
+		entity.PasswordPolicy{HashParams: newParams})
+
+	login := func(password string) error {
+		_, err := authService.Login(context.Background(), &entity.LoginInfo{
+			Username: "gopher",
+			Password: password,
+			RemoteIP: "10.0.0.1",
+		})
+		return err
+	}
+
+	t.Run("failed login keeps the hash", func(t *testing.T) {
+		assert.ErrorIs(t, login("wrong"), entity.ErrIncorrectLoginOrPassword)
+		assert.Equal(t, hash, repo.user.Hash)
+	})
+
+	t.Run("outdated hash is replaced", func(t *testing.T) {
+		require.NoError(t, login("password"))
+
+		ok, params, err := argon2id.CheckHash("password", repo.user.Hash)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, *newParams, *params)
+	})
+
+	t.Run("current hash is kept", func(t *testing.T) {
+		current := repo.user.Hash
+
+		require.NoError(t, login("password"))
+		assert.Equal(t, current, repo.user.Hash)
+	})
+}
+
+type resetRepository struct {
+	loginRepository
+	resets int
+}
+
+func (r *resetRepository) AddPasswordReset(_ context.Context, _ *entity.PasswordReset) error {
+	r.resets++
+	return nil
+}
+
+type resetNotifier struct{}
+
+func (resetNotifier) NotifyPasswordReset(_ context.Context, _ *entity.PasswordReset) error {
+	return nil
+}
+
+func TestRequestPasswordResetThrottling(t *testing.T) {
+	repo := &resetRepository{loginRepository: loginRepository{user: &entity.User{ID: "user", Username: "gopher"}}}
+
+	authService := NewAuthService(repo, nil, nil, resetNotifier{}, entity.SessionPolicy{},
+		entity.LoginPolicy{
+			UserFreeAttempts: 2,
+			UserMaxAttempts:  100,
+			IPFreeAttempts:   3,
+			IPMaxAttempts:    100,
+			BaseDelay:        time.Minute,
+			LockoutDuration:  time.Hour,
+		},
+		entity.PasswordPolicy{ResetTTL: time.Hour})
+
+	request := func(username, ip string) error {
+		return authService.RequestPasswordReset(context.Background(), username, ip)
+	}
+
+	t.Run("username is throttled", func(t *testing.T) {
+		require.NoError(t, request("gopher", "10.0.0.1"))
+		require.NoError(t, request("gopher", "10.0.0.2"))
+		require.NoError(t, request("gopher", "10.0.0.3"))
+
+		var throttleErr *entity.ThrottleError
+		require.True(t, errors.As(request("gopher", "10.0.0.4"), &throttleErr))
+		assert.Equal(t, 3, repo.resets)
+	})
+
+	t.Run("ip is throttled for unknown usernames", func(t *testing.T) {
+		for _, username := range []string{"first", "second", "third", "fourth"} {
+			require.NoError(t, request(username, "10.0.0.5"))
+		}
+		assert.ErrorIs(t, request("fifth", "10.0.0.5"), entity.ErrTooManyAttempts)
+	})
+
+	t.Run("logins are not locked out", func(t *testing.T) {
+		_, err := authService.Login(context.Background(), &entity.LoginInfo{
+			Username: "gopher",
+			Password: "password",
+			RemoteIP: "10.0.0.4",
+		})
+		assert.ErrorIs(t, err, entity.ErrIncorrectLoginOrPassword)
+	})
+}
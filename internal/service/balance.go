@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -10,37 +11,92 @@ import (
 
 type BalanceRepository interface {
 	GetUserBalance(ctx context.Context, userID string) (*entity.Balance, error)
-	AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo) error
-	GetWithdrawals(ctx context.Context, userID string) ([]entity.Withdraw, error)
+	AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo, check entity.WithdrawalCheck) error
+	GetWithdrawals(ctx context.Context, filter *entity.PageFilter) ([]entity.Withdraw, error)
+	GetTransactions(ctx context.Context, filter *entity.TransactionFilter) ([]entity.Transaction, error)
+	ReverseWithdrawal(ctx context.Context, reversalInfo *entity.ReversalInfo) (*entity.Withdraw, error)
+	GetExpiringPoints(ctx context.Context, userID string, creditedBefore time.Time) (map[string]int64, error)
+	GetPendingAccrual(ctx context.Context, userID string) (map[string]entity.PendingAccrual, error)
+	GetProgram(ctx context.Context, programID string) (*entity.Program, error)
+	AddProgram(ctx context.Context, program *entity.Program) (*entity.Program, error)
 }
 
 type BalanceService struct {
 	balanceRepository BalanceRepository
+	withdrawalRules   []withdrawalRule
+	expiryPolicy      entity.ExpiryPolicy
 }
 
-func NewBalanceService(balanceRepository BalanceRepository) *BalanceService {
+func NewBalanceService(balanceRepository BalanceRepository, expiryPolicy entity.ExpiryPolicy,
+	withdrawalRules entity.WithdrawalRules) *BalanceService {
 	return &BalanceService{
 		balanceRepository: balanceRepository,
+		withdrawalRules:   newWithdrawalRules(withdrawalRules),
+		expiryPolicy:      expiryPolicy,
 	}
 }
 
+// GetCurrentBalance returns the user balance with the pending accruals and the points expiring
+// soon of every program, the expected accrual is converted to points of the program.
 func (s *BalanceService) GetCurrentBalance(ctx context.Context, userID string) (*entity.Balance, error) {
 	currentBalance, err := s.balanceRepository.GetUserBalance(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	pending, err := s.balanceRepository.GetPendingAccrual(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	expiring := make(map[string]int64)
+	if s.expiryPolicy.Enabled() {
+		soon := s.expiryPolicy.CreditedBefore(time.Now().Add(s.expiryPolicy.SoonWindow))
+
+		expiring, err = s.balanceRepository.GetExpiringPoints(ctx, userID, soon)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range currentBalance.Programs {
+		programBalance := &currentBalance.Programs[i]
+		programID := programBalance.Program.ID
+
+		programBalance.Pending = programBalance.Program.Convert(pending[programID].Sum)
+		programBalance.PendingOrders = pending[programID].Orders
+		programBalance.ExpiringSoon = expiring[programID]
+
+		if programID == entity.DefaultProgramID {
+			currentBalance.Pending = programBalance.Pending
+			currentBalance.PendingOrders = programBalance.PendingOrders
+			currentBalance.ExpiringSoon = programBalance.ExpiringSoon
+		}
+	}
+
 	return currentBalance, nil
 }
 
+// AddWithdrawal checks the withdrawal rules against the user history in the same transaction
+// as the withdrawal, a violation is returned as *entity.RuleViolation.
 func (s *BalanceService) AddWithdrawal(ctx context.Context, withdrawInfo *entity.WithdrawInfo) error {
 	withdrawalUUID, err := uuid.NewV7()
 	if err != nil {
 		return err
 	}
 	withdrawInfo.ID = withdrawalUUID.String()
+	if withdrawInfo.ProgramID == "" {
+		withdrawInfo.ProgramID = entity.DefaultProgramID
+	}
 
-	err = s.balanceRepository.AddWithdrawal(ctx, withdrawInfo)
+	err = s.balanceRepository.AddWithdrawal(ctx, withdrawInfo, func(stats *entity.WithdrawalStats) error {
+		for _, rule := range s.withdrawalRules {
+			if err := rule(withdrawInfo, stats); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
@@ -48,11 +104,71 @@ func (s *BalanceService) AddWithdrawal(ctx context.Context, withdrawInfo *entity
 	return nil
 }
 
-func (s *BalanceService) GetWithdrawals(ctx context.Context, userID string) ([]entity.Withdraw, error) {
-	withdrawals, err := s.balanceRepository.GetWithdrawals(ctx, userID)
+// GetWithdrawals returns a page of the user withdrawals and reports whether there are more of them.
+func (s *BalanceService) GetWithdrawals(ctx context.Context, filter *entity.PageFilter) ([]entity.Withdraw, bool,
+	error) {
+	pageFilter := *filter
+	pageFilter.Limit++
+
+	withdrawals, err := s.balanceRepository.GetWithdrawals(ctx, &pageFilter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(withdrawals) > filter.Limit {
+		return withdrawals[:filter.Limit], true, nil
+	}
+
+	return withdrawals, false, nil
+}
+
+// GetTransactions returns a page of the user transactions and reports whether there are more of them.
+func (s *BalanceService) GetTransactions(ctx context.Context,
+	filter *entity.TransactionFilter) ([]entity.Transaction, bool, error) {
+	pageFilter := *filter
+	pageFilter.Limit++
+
+	transactions, err := s.balanceRepository.GetTransactions(ctx, &pageFilter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(transactions) > filter.Limit {
+		return transactions[:filter.Limit], true, nil
+	}
+
+	return transactions, false, nil
+}
+
+func (s *BalanceService) ReverseWithdrawal(ctx context.Context, reversalInfo *entity.ReversalInfo) (*entity.Withdraw,
+	error) {
+	withdraw, err := s.balanceRepository.ReverseWithdrawal(ctx, reversalInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return withdraw, nil
+}
+
+// GetProgram returns the loyalty program, the default one if programID is empty.
+func (s *BalanceService) GetProgram(ctx context.Context, programID string) (*entity.Program, error) {
+	if programID == "" {
+		programID = entity.DefaultProgramID
+	}
+
+	program, err := s.balanceRepository.GetProgram(ctx, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	return program, nil
+}
+
+func (s *BalanceService) AddProgram(ctx context.Context, program *entity.Program) (*entity.Program, error) {
+	created, err := s.balanceRepository.AddProgram(ctx, program)
 	if err != nil {
 		return nil, err
 	}
 
-	return withdrawals, nil
+	return created, nil
 }
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type currentBalanceRepository struct {
+	BalanceRepository
+}
+
+func (r *currentBalanceRepository) GetUserBalance(_ context.Context, userID string) (*entity.Balance, error) {
+	return &entity.Balance{
+		ID:      userID,
+		Balance: 10000,
+		Programs: []entity.ProgramBalance{
+			{Program: defaultProgram, Balance: 10000},
+			{Program: partnerProgram, Balance: 500},
+		},
+	}, nil
+}
+
+func (r *currentBalanceRepository) GetPendingAccrual(_ context.Context, _ string) (map[string]entity.PendingAccrual,
+	error) {
+	return map[string]entity.PendingAccrual{
+		entity.DefaultProgramID: {Sum: 2500, Orders: 2},
+		partnerProgram.ID:       {Sum: 1234, Orders: 1},
+	}, nil
+}
+
+func (r *currentBalanceRepository) GetExpiringPoints(_ context.Context, _ string, _ time.Time) (map[string]int64,
+	error) {
+	return map[string]int64{partnerProgram.ID: 300}, nil
+}
+
+func TestGetCurrentBalance(t *testing.T) {
+	balanceService := NewBalanceService(&currentBalanceRepository{}, entity.ExpiryPolicy{Months: 12},
+		entity.WithdrawalRules{})
+
+	balance, err := balanceService.GetCurrentBalance(context.Background(), "user")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2500), balance.Pending)
+	assert.Equal(t, 2, balance.PendingOrders)
+	assert.Equal(t, int64(0), balance.ExpiringSoon)
+
+	require.Len(t, balance.Programs, 2)
+	partner := balance.Programs[1]
+	// 12.34 accrual system points are 123 partner points.
+	assert.Equal(t, int64(123), partner.Pending)
+	assert.Equal(t, 1, partner.PendingOrders)
+	assert.Equal(t, int64(300), partner.ExpiringSoon)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -11,16 +12,20 @@ import (
 
 type OrderRepository interface {
 	AddOrder(ctx context.Context, orderInfo *entity.OrderInfo) (*entity.Order, error)
-	GetOrders(ctx context.Context, userID string) ([]entity.Order, error)
+	GetOrders(ctx context.Context, filter *entity.OrderFilter) ([]entity.Order, error)
 }
 
 type OrderService struct {
 	orderRepository OrderRepository
+	pollDelay       time.Duration
 }
 
-func NewOrderService(orderRepository OrderRepository) *OrderService {
+// NewOrderService creates the order service, new orders are polled in the accrual system
+// only after pollDelay. It gives the accrual system time to push the status by callback.
+func NewOrderService(orderRepository OrderRepository, pollDelay time.Duration) *OrderService {
 	return &OrderService{
 		orderRepository: orderRepository,
+		pollDelay:       pollDelay,
 	}
 }
 
@@ -30,6 +35,7 @@ func (s *OrderService) AddOrder(ctx context.Context, orderInfo *entity.OrderInfo
 		return nil, err
 	}
 	orderInfo.ID = orderUUID.String()
+	orderInfo.NextAttemptAt = time.Now().Add(s.pollDelay)
 
 	order, err := s.orderRepository.AddOrder(ctx, orderInfo)
 	if errors.Is(err, entity.ErrOrderUniqueViolation) {
@@ -45,11 +51,19 @@ func (s *OrderService) AddOrder(ctx context.Context, orderInfo *entity.OrderInfo
 	return order, nil
 }
 
-func (s *OrderService) GetOrders(ctx context.Context, userID string) ([]entity.Order, error) {
-	orders, err := s.orderRepository.GetOrders(ctx, userID)
+// GetOrders returns a page of the user orders and reports whether there are more of them.
+func (s *OrderService) GetOrders(ctx context.Context, filter *entity.OrderFilter) ([]entity.Order, bool, error) {
+	pageFilter := *filter
+	pageFilter.Limit++
+
+	orders, err := s.orderRepository.GetOrders(ctx, &pageFilter)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	if len(orders) > filter.Limit {
+		return orders[:filter.Limit], true, nil
 	}
 
-	return orders, nil
+	return orders, false, nil
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+// withdrawalRule returns *entity.RuleViolation if the withdrawal is not allowed.
+type withdrawalRule func(withdrawInfo *entity.WithdrawInfo, stats *entity.WithdrawalStats) error
+
+// newWithdrawalRules builds the enabled rules in the order they are checked.
+func newWithdrawalRules(rules entity.WithdrawalRules) []withdrawalRule {
+	checks := make([]withdrawalRule, 0)
+
+	if rules.MinAccountAge > 0 {
+		checks = append(checks, func(_ *entity.WithdrawInfo, stats *entity.WithdrawalStats) error {
+			if stats.Now.Sub(stats.UserCreatedAt) < rules.MinAccountAge {
+				return &entity.RuleViolation{
+					Rule:    entity.RuleMinAccountAge,
+					Limit:   rules.MinAccountAge.String(),
+					Message: "the account is too new to withdraw points",
+				}
+			}
+			return nil
+		})
+	}
+
+	if rules.MaxPerWithdrawal > 0 {
+		checks = append(checks, func(withdrawInfo *entity.WithdrawInfo, stats *entity.WithdrawalStats) error {
+			limit := stats.Program.Convert(rules.MaxPerWithdrawal)
+			if withdrawInfo.Sum > limit {
+				return &entity.RuleViolation{
+					Rule:    entity.RuleMaxPerWithdrawal,
+					Limit:   stats.Program.ToPoints(limit).String(),
+					Message: "the sum exceeds the maximum of a single withdrawal",
+				}
+			}
+			return nil
+		})
+	}
+
+	if rules.MaxPerHour > 0 {
+		checks = append(checks, func(_ *entity.WithdrawInfo, stats *entity.WithdrawalStats) error {
+			if stats.HourCount >= rules.MaxPerHour {
+				return &entity.RuleViolation{
+					Rule:    entity.RuleMaxPerHour,
+					Limit:   strconv.Itoa(rules.MaxPerHour),
+					Message: "too many withdrawals within an hour",
+				}
+			}
+			return nil
+		})
+	}
+
+	if rules.MaxPerDay > 0 {
+		checks = append(checks, func(withdrawInfo *entity.WithdrawInfo, stats *entity.WithdrawalStats) error {
+			limit := stats.Program.Convert(rules.MaxPerDay)
+			if stats.DaySum+withdrawInfo.Sum > limit {
+				points := stats.Program.ToPoints(limit).String()
+				return &entity.RuleViolation{
+					Rule:    entity.RuleMaxPerDay,
+					Limit:   points,
+					Message: fmt.Sprintf("only %s points can be withdrawn within a day", points),
+				}
+			}
+			return nil
+		})
+	}
+
+	if rules.MaxPerWeek > 0 {
+		checks = append(checks, func(withdrawInfo *entity.WithdrawInfo, stats *entity.WithdrawalStats) error {
+			limit := stats.Program.Convert(rules.MaxPerWeek)
+			if stats.WeekSum+withdrawInfo.Sum > limit {
+				points := stats.Program.ToPoints(limit).String()
+				return &entity.RuleViolation{
+					Rule:    entity.RuleMaxPerWeek,
+					Limit:   points,
+					Message: fmt.Sprintf("only %s points can be withdrawn within a week", points),
+				}
+			}
+			return nil
+		})
+	}
+
+	return checks
+}
+
+// transferRule returns *entity.RuleViolation if the transfer is not allowed.
+type transferRule func(transferInfo *entity.TransferInfo, stats *entity.TransferStats) error
+
+// newTransferRules builds the enabled transfer rules in the order they are checked.
+func newTransferRules(rules entity.TransferRules) []transferRule {
+	checks := make([]transferRule, 0)
+
+	if rules.MaxPerTransfer > 0 {
+		checks = append(checks, func(transferInfo *entity.TransferInfo, stats *entity.TransferStats) error {
+			limit := stats.Program.Convert(rules.MaxPerTransfer)
+			if transferInfo.Sum > limit {
+				return &entity.RuleViolation{
+					Rule:    entity.RuleMaxPerTransfer,
+					Limit:   stats.Program.ToPoints(limit).String(),
+					Message: "the sum exceeds the maximum of a single transfer",
+				}
+			}
+			return nil
+		})
+	}
+
+	if rules.MaxPerDay > 0 {
+		checks = append(checks, func(transferInfo *entity.TransferInfo, stats *entity.TransferStats) error {
+			limit := stats.Program.Convert(rules.MaxPerDay)
+			if stats.DaySum+transferInfo.Sum > limit {
+				points := stats.Program.ToPoints(limit).String()
+				return &entity.RuleViolation{
+					Rule:    entity.RuleMaxTransferPerDay,
+					Limit:   points,
+					Message: fmt.Sprintf("only %s points can be transferred within a day", points),
+				}
+			}
+			return nil
+		})
+	}
+
+	return checks
+}
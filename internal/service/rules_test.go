@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+var (
+	defaultProgram = entity.Program{ID: entity.DefaultProgramID, Precision: 2, ConversionRate: decimal.NewFromInt(1)}
+	// partnerProgram has whole points, ten of them are credited for one point of the accrual system.
+	partnerProgram = entity.Program{ID: "partner", Precision: 0, ConversionRate: decimal.NewFromInt(10)}
+)
+
+type transferRulesRepository struct {
+	TransferRepository
+	stats *entity.TransferStats
+}
+
+func (r *transferRulesRepository) AddTransfer(_ context.Context, transferInfo *entity.TransferInfo,
+	check entity.TransferCheck) (*entity.Transfer, bool, error) {
+	if err := check(r.stats); err != nil {
+		return nil, false, err
+	}
+	return &entity.Transfer{ID: transferInfo.ID, Amount: transferInfo.Sum}, false, nil
+}
+
+type rulesRepository struct {
+	BalanceRepository
+	stats *entity.WithdrawalStats
+	added bool
+}
+
+func (r *rulesRepository) AddWithdrawal(_ context.Context, _ *entity.WithdrawInfo,
+	check entity.WithdrawalCheck) error {
+	if err := check(r.stats); err != nil {
+		return err
+	}
+	r.added = true
+	return nil
+}
+
+func TestWithdrawalRules(t *testing.T) {
+	now := time.Now()
+
+	rules := entity.WithdrawalRules{
+		MinAccountAge:    24 * time.Hour,
+		MaxPerWithdrawal: 100000,
+		MaxPerDay:        150000,
+		MaxPerWeek:       500000,
+		MaxPerHour:       3,
+	}
+
+	tests := []struct {
+		name  string
+		sum   int64
+		stats entity.WithdrawalStats
+		rule  string
+	}{
+		{
+			name: "allowed",
+			sum:  100000,
+			stats: entity.WithdrawalStats{Program: defaultProgram, UserCreatedAt: now.Add(-48 * time.Hour), Now: now,
+				DaySum: 50000},
+		},
+		{
+			name:  "account is too new",
+			sum:   100,
+			stats: entity.WithdrawalStats{Program: defaultProgram, UserCreatedAt: now.Add(-time.Hour), Now: now},
+			rule:  entity.RuleMinAccountAge,
+		},
+		{
+			name:  "single withdrawal limit",
+			sum:   100001,
+			stats: entity.WithdrawalStats{Program: defaultProgram, UserCreatedAt: now.Add(-48 * time.Hour), Now: now},
+			rule:  entity.RuleMaxPerWithdrawal,
+		},
+		{
+			name: "too many withdrawals within an hour",
+			sum:  100,
+			stats: entity.WithdrawalStats{Program: defaultProgram, UserCreatedAt: now.Add(-48 * time.Hour), Now: now,
+				HourCount: 3},
+			rule: entity.RuleMaxPerHour,
+		},
+		{
+			name: "daily limit",
+			sum:  50001,
+			stats: entity.WithdrawalStats{Program: defaultProgram, UserCreatedAt: now.Add(-48 * time.Hour), Now: now,
+				DaySum: 100000},
+			rule: entity.RuleMaxPerDay,
+		},
+		{
+			name: "weekly limit",
+			sum:  100,
+			stats: entity.WithdrawalStats{Program: defaultProgram, UserCreatedAt: now.Add(-48 * time.Hour), Now: now,
+				DaySum: 0, WeekSum: 500000},
+			rule: entity.RuleMaxPerWeek,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &rulesRepository{stats: &tt.stats}
+			balanceService := NewBalanceService(repo, entity.ExpiryPolicy{}, rules)
+
+			err := balanceService.AddWithdrawal(context.Background(), &entity.WithdrawInfo{
+				UserID:      "user",
+				OrderNumber: "2377225624",
+				Sum:         tt.sum,
+			})
+
+			if tt.rule == "" {
+				require.NoError(t, err)
+				assert.True(t, repo.added)
+				return
+			}
+
+			var violation *entity.RuleViolation
+			require.True(t, errors.As(err, &violation))
+			assert.ErrorIs(t, err, entity.ErrRuleViolation)
+			assert.Equal(t, tt.rule, violation.Rule)
+			assert.False(t, repo.added)
+		})
+	}
+
+	t.Run("limits of other programs are converted", func(t *testing.T) {
+		// 1000 points of the accrual system are 10000 partner points.
+		for sum, rule := range map[int64]string{
+			10000: "",
+			10001: entity.RuleMaxPerWithdrawal,
+		} {
+			repo := &rulesRepository{stats: &entity.WithdrawalStats{Program: partnerProgram,
+				UserCreatedAt: now.Add(-48 * time.Hour), Now: now}}
+			balanceService := NewBalanceService(repo, entity.ExpiryPolicy{}, rules)
+
+			err := balanceService.AddWithdrawal(context.Background(), &entity.WithdrawInfo{
+				ProgramID: partnerProgram.ID,
+				Sum:       sum,
+			})
+			if rule == "" {
+				require.NoError(t, err)
+				continue
+			}
+
+			var violation *entity.RuleViolation
+			require.True(t, errors.As(err, &violation))
+			assert.Equal(t, rule, violation.Rule)
+			assert.Equal(t, "10000", violation.Limit)
+		}
+
+		repo := &rulesRepository{stats: &entity.WithdrawalStats{Program: partnerProgram,
+			UserCreatedAt: now.Add(-48 * time.Hour), Now: now, DaySum: 15000}}
+		balanceService := NewBalanceService(repo, entity.ExpiryPolicy{}, rules)
+
+		err := balanceService.AddWithdrawal(context.Background(), &entity.WithdrawInfo{
+			ProgramID: partnerProgram.ID,
+			Sum:       1,
+		})
+
+		var violation *entity.RuleViolation
+		require.True(t, errors.As(err, &violation))
+		assert.Equal(t, entity.RuleMaxPerDay, violation.Rule)
+		assert.False(t, repo.added)
+	})
+
+	t.Run("rules are disabled by default", func(t *testing.T) {
+		repo := &rulesRepository{stats: &entity.WithdrawalStats{Program: defaultProgram, Now: now, UserCreatedAt: now,
+			HourCount: 100}}
+		balanceService := NewBalanceService(repo, entity.ExpiryPolicy{}, entity.WithdrawalRules{})
+
+		err := balanceService.AddWithdrawal(context.Background(), &entity.WithdrawInfo{Sum: 1 << 40})
+		require.NoError(t, err)
+		assert.True(t, repo.added)
+	})
+
+	t.Run("limit is shown in points", func(t *testing.T) {
+		repo := &rulesRepository{stats: &entity.WithdrawalStats{Program: defaultProgram, Now: now,
+			UserCreatedAt: now.Add(-48 * time.Hour)}}
+		balanceService := NewBalanceService(repo, entity.ExpiryPolicy{}, entity.WithdrawalRules{MaxPerWithdrawal: 50})
+
+		err := balanceService.AddWithdrawal(context.Background(), &entity.WithdrawInfo{Sum: 51})
+
+		var violation *entity.RuleViolation
+		require.True(t, errors.As(err, &violation))
+		assert.Equal(t, "0.5", violation.Limit)
+	})
+}
+
+func TestTransferRules(t *testing.T) {
+	rules := entity.TransferRules{
+		MaxPerTransfer: 10000,
+		MaxPerDay:      20000,
+	}
+
+	tests := []struct {
+		name    string
+		program entity.Program
+		sum     int64
+		daySum  int64
+		rule    string
+	}{
+		{name: "allowed", program: defaultProgram, sum: 10000, daySum: 10000},
+		{name: "single transfer limit", program: defaultProgram, sum: 10001, rule: entity.RuleMaxPerTransfer},
+		{name: "daily limit", program: defaultProgram, sum: 5000, daySum: 15001, rule: entity.RuleMaxTransferPerDay},
+		{name: "other program allowed", program: partnerProgram, sum: 1000, daySum: 1000},
+		{name: "other program single transfer limit", program: partnerProgram, sum: 1001,
+			rule: entity.RuleMaxPerTransfer},
+		{name: "other program daily limit", program: partnerProgram, sum: 500, daySum: 1501,
+			rule: entity.RuleMaxTransferPerDay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transferService := NewTransferService(&transferRulesRepository{
+				stats: &entity.TransferStats{Program: tt.program, DaySum: tt.daySum},
+			}, rules)
+
+			_, _, err := transferService.Transfer(context.Background(), &entity.TransferInfo{
+				SenderID:  "sender",
+				Recipient: "recipient",
+				ProgramID: tt.program.ID,
+				Sum:       tt.sum,
+			})
+
+			if tt.rule == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var violation *entity.RuleViolation
+			require.True(t, errors.As(err, &violation))
+			assert.Equal(t, tt.rule, violation.Rule)
+		})
+	}
+}
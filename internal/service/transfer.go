@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type TransferRepository interface {
+	AddTransfer(ctx context.Context, transferInfo *entity.TransferInfo, check entity.TransferCheck) (*entity.Transfer,
+		bool, error)
+	AllowSender(ctx context.Context, userID, username string) error
+	DisallowSender(ctx context.Context, userID, username string) error
+	GetAllowedSenders(ctx context.Context, userID string) ([]string, error)
+}
+
+type TransferService struct {
+	transferRepository TransferRepository
+	transferRules      []transferRule
+}
+
+func NewTransferService(transferRepository TransferRepository, transferRules entity.TransferRules) *TransferService {
+	return &TransferService{
+		transferRepository: transferRepository,
+		transferRules:      newTransferRules(transferRules),
+	}
+}
+
+// Transfer moves the points to the recipient. A repeated request with the same idempotency key
+// returns the original transfer and reports it as replayed, a violated limit is returned
+// as *entity.RuleViolation.
+func (s *TransferService) Transfer(ctx context.Context, transferInfo *entity.TransferInfo) (*entity.Transfer, bool,
+	error) {
+	transferUUID, err := uuid.NewV7()
+	if err != nil {
+		return nil, false, err
+	}
+	transferInfo.ID = transferUUID.String()
+	if transferInfo.ProgramID == "" {
+		transferInfo.ProgramID = entity.DefaultProgramID
+	}
+
+	transfer, replayed, err := s.transferRepository.AddTransfer(ctx, transferInfo,
+		func(stats *entity.TransferStats) error {
+			for _, rule := range s.transferRules {
+				if err := rule(transferInfo, stats); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return transfer, replayed, nil
+}
+
+func (s *TransferService) AllowSender(ctx context.Context, userID, username string) error {
+	return s.transferRepository.AllowSender(ctx, userID, username)
+}
+
+func (s *TransferService) DisallowSender(ctx context.Context, userID, username string) error {
+	return s.transferRepository.DisallowSender(ctx, userID, username)
+}
+
+func (s *TransferService) GetAllowedSenders(ctx context.Context, userID string) ([]string, error) {
+	return s.transferRepository.GetAllowedSenders(ctx, userID)
+}
@@ -4,43 +4,78 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/client"
+	"github.com/ivas1ly/gophermart/pkg/breaker"
 )
 
-const defaultWorkerEntities = 5
-
 type AccrualClient interface {
-	GetOrderStatus(id string) (string, int64, error)
+	GetOrderStatus(ctx context.Context, number string) (*client.OrderAccrual, error)
 }
 
 type AccrualWorkerRepository interface {
-	GetOrdersToProcess(ctx context.Context, count int) ([]entity.Order, error)
+	GetOrdersToProcess(ctx context.Context, claim *entity.ClaimInfo) ([]entity.Order, error)
 	UpdateOrderAndUserBalance(ctx context.Context, order entity.Order) error
+	RescheduleOrder(ctx context.Context, retry *entity.RetryInfo) error
+	FailOrder(ctx context.Context, workerID string, order entity.Order, reason string) error
+}
+
+type Config struct {
+	ID           string
+	PollInterval time.Duration
+	Lease        time.Duration
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	MaxAge       time.Duration
+	Concurrency  int
+	BatchSize    int
+	MaxAttempts  int
 }
 
+var errOrderNotFinal = errors.New("order status is not final")
+
 type AccrualWorker struct {
 	ar           AccrualWorkerRepository
 	client       AccrualClient
+	breaker      *breaker.Breaker
 	log          *zap.Logger
+	id           string
 	pollInterval time.Duration
+	lease        time.Duration
+	retryBase    time.Duration
+	retryMax     time.Duration
+	maxAge       time.Duration
+	concurrency  int
+	batchSize    int
+	maxAttempts  int
 }
 
-func NewAccrualWorker(accrualClient AccrualClient, accrualRepository AccrualWorkerRepository,
-	pollInterval time.Duration, log *zap.Logger) *AccrualWorker {
+func NewAccrualWorker(accrualClient AccrualClient, cb *breaker.Breaker, accrualRepository AccrualWorkerRepository,
+	cfg Config, log *zap.Logger) *AccrualWorker {
 	return &AccrualWorker{
 		client:       accrualClient,
+		breaker:      cb,
 		ar:           accrualRepository,
-		pollInterval: pollInterval,
-		log:          log.With(zap.String("worker", "accrual system")),
+		id:           cfg.ID,
+		pollInterval: cfg.PollInterval,
+		lease:        cfg.Lease,
+		retryBase:    cfg.RetryBase,
+		retryMax:     cfg.RetryMax,
+		maxAge:       cfg.MaxAge,
+		concurrency:  max(cfg.Concurrency, 1),
+		batchSize:    max(cfg.BatchSize, 1),
+		maxAttempts:  cfg.MaxAttempts,
+		log:          log.With(zap.String("worker", "accrual system"), zap.String("worker id", cfg.ID)),
 	}
 }
 
 func (w *AccrualWorker) Run(ctx context.Context) {
-	w.log.Info("start worker")
+	w.log.Info("start worker", zap.Int("concurrency", w.concurrency), zap.Int("batch size", w.batchSize))
 
 	inputCh, ticker := w.getNewOrders(ctx)
 	defer ticker.Stop()
@@ -50,12 +85,12 @@ func (w *AccrualWorker) Run(ctx context.Context) {
 	<-ctx.Done()
 }
 
-func (w *AccrualWorker) getNewOrders(ctx context.Context) (chan []entity.Order, *time.Ticker) {
+func (w *AccrualWorker) getNewOrders(ctx context.Context) (chan entity.Order, *time.Ticker) {
 	w.log.Info("start process orders with interval", zap.Duration("poll interval", w.pollInterval))
 
 	updateTicker := time.NewTicker(w.pollInterval)
 
-	inputCh := make(chan []entity.Order)
+	inputCh := make(chan entity.Order)
 
 	go func() {
 		defer close(inputCh)
@@ -68,8 +103,17 @@ func (w *AccrualWorker) getNewOrders(ctx context.Context) (chan []entity.Order,
 				w.log.Info("received done context")
 				return
 			case <-updateTicker.C:
+				if w.breaker.State() == breaker.StateOpen {
+					w.log.Info("accrual system circuit is open, skip polling")
+					continue
+				}
+
 				w.log.Info("trying to get new orders")
-				orders, err := w.ar.GetOrdersToProcess(ctx, defaultWorkerEntities)
+				orders, err := w.ar.GetOrdersToProcess(ctx, &entity.ClaimInfo{
+					WorkerID: w.id,
+					Lease:    w.lease,
+					Count:    w.batchSize,
+				})
 				if err != nil {
 					w.log.Info("can't get new orders", zap.Error(err))
 					continue
@@ -79,8 +123,15 @@ func (w *AccrualWorker) getNewOrders(ctx context.Context) (chan []entity.Order,
 					continue
 				}
 
-				w.log.Info("add orders to process queue")
-				inputCh <- orders
+				w.log.Info("add orders to process queue", zap.Int("count", len(orders)))
+				for _, order := range orders {
+					select {
+					case <-ctx.Done():
+						w.log.Info("received done context")
+						return
+					case inputCh <- order:
+					}
+				}
 			}
 		}
 	}()
@@ -88,55 +139,178 @@ func (w *AccrualWorker) getNewOrders(ctx context.Context) (chan []entity.Order,
 	return inputCh, updateTicker
 }
 
-func (w *AccrualWorker) getOrderAccrual(ctx context.Context, inputCh chan []entity.Order) chan []entity.Order {
+func (w *AccrualWorker) getOrderAccrual(ctx context.Context, inputCh chan entity.Order) chan entity.Order {
 	w.log.Info("start process order accrual")
 
-	result := make(chan []entity.Order)
+	result := make(chan entity.Order)
 
-	go func() {
-		defer close(result)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func(fetcher int) {
+			defer wg.Done()
 
-		for orders := range inputCh {
-			processedOrders := make([]entity.Order, 0)
+			log := w.log.With(zap.Int("fetcher", fetcher))
 
-			for _, order := range orders {
-				order := order
-
-				w.log.Info("check order", zap.String("order", order.Number))
-
-				status, accrual, err := w.client.GetOrderStatus(order.Number)
+			for order := range inputCh {
+				processed, err := w.fetchOrderAccrual(ctx, log, order)
 				if err != nil {
-					w.log.Info("response error, skip order", zap.Error(err))
+					w.retryOrder(ctx, log, processed, err)
 					continue
 				}
 
-				w.log.Info("received order status from accrual system", zap.String("status", status))
-				if status == entity.StatusProcessed.String() || status == entity.StatusInvalid.String() {
-					order.Status = status
-					order.Accrual = accrual
-
-					w.log.Info("adding new order to queue", zap.String("order",
-						fmt.Sprintf("%+v", order)))
-
-					processedOrders = append(processedOrders, order)
-					w.log.Info("order added to queue for status update")
+				select {
+				case <-ctx.Done():
+					log.Info("received done context")
+					return
+				case result <- processed:
+					log.Info("order has been pushed to the next stage for update")
 				}
 			}
+		}(i)
+	}
 
-			select {
-			case <-ctx.Done():
-				w.log.Info("received done context")
-				return
-			case result <- processedOrders:
-				w.log.Info("orders have been pushed to the next stage for update")
-			}
-		}
+	go func() {
+		wg.Wait()
+		close(result)
 	}()
 
 	return result
 }
 
-func (w *AccrualWorker) updateOrderStatus(ctx context.Context, inputCh chan []entity.Order) {
+// fetchOrderAccrual asks the accrual system for the order status. It returns errOrderNotFinal if
+// the order is not final yet, the order will be polled again later.
+func (w *AccrualWorker) fetchOrderAccrual(ctx context.Context, log *zap.Logger,
+	order entity.Order) (entity.Order, error) {
+	log.Info("check order", zap.String("order", order.Number))
+
+	orderAccrual, err := w.getOrderStatus(ctx, order.Number)
+
+	var rateLimit *client.RateLimitError
+	if errors.As(err, &rateLimit) {
+		log.Info("accrual system rate limit, postpone order", zap.String("order", order.Number),
+			zap.Time("retry at", rateLimit.RetryAt))
+		return order, err
+	}
+	if errors.Is(err, breaker.ErrOpen) {
+		log.Info("accrual system circuit is open, skip order", zap.String("order", order.Number))
+		return order, err
+	}
+	if errors.Is(err, client.ErrNotRegistered) {
+		log.Info("order is not registered in accrual system yet", zap.String("order", order.Number))
+		return order, err
+	}
+	if err != nil {
+		log.Info("response error, skip order", zap.Error(err))
+		return order, err
+	}
+
+	status, accrual := orderAccrual.Status, orderAccrual.Accrual
+	log.Info("received order status from accrual system", zap.String("status", status))
+
+	checkedAt := time.Now()
+	order.AccrualStatus = status
+	order.CheckedAt = &checkedAt
+
+	accrualStatus := entity.ParseStatus(status)
+	if accrualStatus == entity.StatusUnknown {
+		log.Warn("unknown order status from accrual system", zap.String("status", status))
+	}
+	if !accrualStatus.IsFinal() {
+		order.ExpectedAccrual = accrual
+		return order, errOrderNotFinal
+	}
+
+	order.Status = accrualStatus
+	order.Accrual = accrual
+
+	log.Info("order added to queue for status update", zap.String("order", fmt.Sprintf("%+v", order)))
+
+	return order, nil
+}
+
+// getOrderStatus sends the request through the circuit breaker, only upstream errors count as failures.
+// Rate limited and canceled requests are neither failures nor successes, they only release
+// the breaker slot.
+func (w *AccrualWorker) getOrderStatus(ctx context.Context, number string) (*client.OrderAccrual, error) {
+	if err := w.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	orderAccrual, err := w.client.GetOrderStatus(ctx, number)
+	if errors.Is(err, client.ErrRateLimited) || ctx.Err() != nil {
+		w.breaker.Release()
+	} else {
+		w.breaker.Done(!errors.Is(err, client.ErrUpstream))
+	}
+
+	return orderAccrual, err
+}
+
+// retryOrder schedules the next poll of the order. Orders that are not final yet are polled
+// with the usual interval, rate limited ones when the accrual system allows requests again,
+// failed requests are retried with exponential backoff until the attempts or age limit is reached.
+func (w *AccrualWorker) retryOrder(ctx context.Context, log *zap.Logger, order entity.Order, reason error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	log = log.With(zap.String("order", order.Number))
+
+	var rateLimit *client.RateLimitError
+
+	retry := &entity.RetryInfo{
+		OrderID:       order.ID,
+		WorkerID:      w.id,
+		Attempts:      order.Attempts,
+		NextAttemptAt: time.Now().Add(w.pollInterval),
+	}
+
+	switch {
+	case errors.Is(reason, errOrderNotFinal):
+		retry.AccrualStatus = order.AccrualStatus
+		retry.CheckedAt = order.CheckedAt
+		retry.ExpectedAccrual = order.ExpectedAccrual
+	case errors.Is(reason, breaker.ErrOpen):
+		retry.NextAttemptAt = time.Now()
+	case errors.As(reason, &rateLimit):
+		retry.NextAttemptAt = rateLimit.RetryAt
+	default:
+		retry.Attempts++
+		retry.LastError = reason.Error()
+		retry.NextAttemptAt = time.Now().Add(backoff(retry.Attempts, w.retryBase, w.retryMax))
+
+		if w.exhausted(order, retry.Attempts) {
+			log.Warn("order retry limit exceeded, mark as invalid", zap.Int("attempts", retry.Attempts))
+
+			err := w.ar.FailOrder(ctx, w.id, order, fmt.Sprintf("retry limit exceeded: %s", reason))
+			if err != nil {
+				log.Warn("can't mark order as invalid", zap.Error(err))
+			}
+			return
+		}
+	}
+
+	err := w.ar.RescheduleOrder(ctx, retry)
+	if err != nil {
+		log.Warn("can't reschedule order", zap.Error(err))
+		return
+	}
+
+	log.Info("order rescheduled", zap.Int("attempts", retry.Attempts), zap.Time("next attempt", retry.NextAttemptAt))
+}
+
+// exhausted reports whether a failed request must not be retried anymore. Orders that are
+// not final yet are polled regardless of the limits.
+func (w *AccrualWorker) exhausted(order entity.Order, attempts int) bool {
+	if w.maxAttempts > 0 && attempts >= w.maxAttempts {
+		return true
+	}
+
+	return w.maxAge > 0 && time.Since(order.CreatedAt) > w.maxAge
+}
+
+func (w *AccrualWorker) updateOrderStatus(ctx context.Context, inputCh chan entity.Order) {
 	w.log.Info("start update order status")
 
 	go func() {
@@ -145,14 +319,13 @@ func (w *AccrualWorker) updateOrderStatus(ctx context.Context, inputCh chan []en
 			case <-ctx.Done():
 				w.log.Info("received done context")
 				return
-			case orders := <-inputCh:
-				if len(orders) == 0 {
-					w.log.Info("nothing to update in database")
-					continue
+			case order, ok := <-inputCh:
+				if !ok {
+					return
 				}
 
-				w.log.Info("trying to update orders status")
-				updated := w.updateOrders(ctx, orders...)
+				w.log.Info("trying to update order status")
+				updated := w.updateOrders(ctx, order)
 				if updated == 0 {
 					w.log.Info("failed to update order status, skip", zap.Int("updated", updated))
 					continue
@@ -169,6 +342,10 @@ func (w *AccrualWorker) updateOrders(ctx context.Context, orders ...entity.Order
 	var count int
 	for _, order := range orders {
 		err := w.ar.UpdateOrderAndUserBalance(ctx, order)
+		if errors.Is(err, entity.ErrOrderAlreadyFinal) {
+			w.log.Info("order accrual is already applied", zap.String("number", order.Number))
+			continue
+		}
 		if errors.Is(err, entity.ErrCanNotUpdateOrder) {
 			w.log.Warn("can't update order status", zap.Error(err))
 			continue
@@ -0,0 +1,212 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/accrualmock"
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/client"
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+	"github.com/ivas1ly/gophermart/pkg/breaker"
+)
+
+const (
+	defaultLogLevel    = "info"
+	defaultTestTimeout = 5 * time.Second
+	defaultTestTick    = 10 * time.Millisecond
+)
+
+// memoryRepository keeps orders in memory the same way AccrualWorkerRepository keeps them in Postgres.
+type memoryRepository struct {
+	orders   map[string]*entity.Order
+	lockedBy map[string]string
+	balance  map[string]int64
+	mu       sync.Mutex
+}
+
+func newMemoryRepository(orders ...entity.Order) *memoryRepository {
+	repo := &memoryRepository{
+		orders:   make(map[string]*entity.Order),
+		lockedBy: make(map[string]string),
+		balance:  make(map[string]int64),
+	}
+
+	for _, order := range orders {
+		order := order
+		repo.orders[order.ID] = &order
+	}
+
+	return repo
+}
+
+func (r *memoryRepository) GetOrdersToProcess(_ context.Context, claim *entity.ClaimInfo) ([]entity.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := make([]entity.Order, 0, claim.Count)
+	for _, order := range r.orders {
+		if len(orders) == claim.Count {
+			break
+		}
+		if order.Status.IsFinal() || r.lockedBy[order.ID] != "" || order.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+
+		order.Status = entity.StatusProcessing
+		r.lockedBy[order.ID] = claim.WorkerID
+		orders = append(orders, *order)
+	}
+
+	return orders, nil
+}
+
+func (r *memoryRepository) UpdateOrderAndUserBalance(_ context.Context, order entity.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.orders[order.ID]
+	if stored.Status.IsFinal() {
+		return entity.ErrOrderAlreadyFinal
+	}
+	stored.Status = order.Status
+	stored.Accrual = order.Accrual
+	stored.AccrualStatus = order.AccrualStatus
+	delete(r.lockedBy, order.ID)
+
+	r.balance[order.UserID] += order.Accrual
+
+	return nil
+}
+
+func (r *memoryRepository) RescheduleOrder(_ context.Context, retry *entity.RetryInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.orders[retry.OrderID]
+	stored.Attempts = retry.Attempts
+	stored.NextAttemptAt = retry.NextAttemptAt
+	stored.LastError = retry.LastError
+	stored.ExpectedAccrual = retry.ExpectedAccrual
+	if retry.AccrualStatus != "" {
+		stored.AccrualStatus = retry.AccrualStatus
+	}
+	delete(r.lockedBy, retry.OrderID)
+
+	return nil
+}
+
+func (r *memoryRepository) FailOrder(_ context.Context, _ string, order entity.Order, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.orders[order.ID]
+	stored.Status = entity.StatusInvalid
+	stored.LastError = reason
+	delete(r.lockedBy, order.ID)
+
+	return nil
+}
+
+func (r *memoryRepository) order(id string) entity.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return *r.orders[id]
+}
+
+func (r *memoryRepository) allFinal() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, order := range r.orders {
+		if !order.Status.IsFinal() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestAccrualWorker(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+
+	mock := accrualmock.New(log)
+	mock.AddReward(accrualmock.Reward{Order: "2377225624", Accrual: decimal.RequireFromString("729.98")})
+	mock.SetScript("41632078327500", accrualmock.Script{Steps: []accrualmock.Step{
+		{Status: accrualmock.StatusRegistered},
+		{Status: accrualmock.StatusInvalid},
+	}})
+	mock.SetScript("45444541846", accrualmock.Script{Steps: []accrualmock.Step{
+		{Code: http.StatusTooManyRequests, RetryAfter: 1},
+		{Status: accrualmock.StatusProcessed, Accrual: &decimal.Zero},
+	}})
+	mock.SetScript("12345678903", accrualmock.Script{Steps: []accrualmock.Step{
+		{Status: accrualmock.StatusRegistered},
+		{Status: accrualmock.StatusProcessing},
+		{Status: accrualmock.StatusProcessed, Accrual: &decimal.Zero},
+	}})
+
+	ts := httptest.NewServer(mock.Router())
+	defer ts.Close()
+
+	repo := newMemoryRepository(
+		entity.Order{ID: "1", UserID: "user", Number: "2377225624", CreatedAt: time.Now()},
+		entity.Order{ID: "2", UserID: "user", Number: "41632078327500", CreatedAt: time.Now()},
+		entity.Order{ID: "3", UserID: "user", Number: "45444541846", CreatedAt: time.Now()},
+		entity.Order{ID: "4", UserID: "user", Number: "333207722682", CreatedAt: time.Now()},
+		entity.Order{ID: "5", UserID: "user", Number: "12345678903", CreatedAt: time.Now().Add(-2 * time.Hour)},
+	)
+
+	accrualClient := client.NewAccrualClient(ts.URL, ts.Client(), nil, client.NewGate(), log)
+	cb := breaker.New(breaker.Settings{FailureThreshold: 5, OpenTimeout: time.Second})
+
+	w := NewAccrualWorker(accrualClient, cb, repo, Config{
+		ID:           "test",
+		PollInterval: defaultTestTick,
+		Lease:        time.Minute,
+		RetryBase:    defaultTestTick,
+		RetryMax:     defaultTestTick,
+		Concurrency:  2,
+		BatchSize:    2,
+		MaxAttempts:  3,
+		MaxAge:       time.Hour,
+	}, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go w.Run(ctx)
+
+	require.Eventually(t, repo.allFinal, defaultTestTimeout, defaultTestTick)
+
+	processed := repo.order("1")
+	assert.Equal(t, entity.StatusProcessed, processed.Status)
+	assert.Equal(t, int64(72998), processed.Accrual)
+
+	invalid := repo.order("2")
+	assert.Equal(t, entity.StatusInvalid, invalid.Status)
+	assert.Equal(t, "INVALID", invalid.AccrualStatus)
+
+	rateLimited := repo.order("3")
+	assert.Equal(t, entity.StatusProcessed, rateLimited.Status)
+	assert.Equal(t, 0, rateLimited.Attempts)
+
+	notRegistered := repo.order("4")
+	assert.Equal(t, entity.StatusInvalid, notRegistered.Status)
+	assert.Contains(t, notRegistered.LastError, "retry limit exceeded")
+
+	old := repo.order("5")
+	assert.Equal(t, entity.StatusProcessed, old.Status)
+
+	assert.Equal(t, breaker.StateClosed, cb.State())
+}
@@ -0,0 +1,16 @@
+package worker
+
+import "time"
+
+// backoff returns the exponential delay before the given attempt, capped at maxDelay.
+func backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+
+	return min(delay, maxDelay)
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+)
+
+type ExpiryRepository interface {
+	ExpireLots(ctx context.Context, creditedBefore time.Time, limit int) (int, error)
+}
+
+type ExpiryConfig struct {
+	Policy    entity.ExpiryPolicy
+	Interval  time.Duration
+	BatchSize int
+}
+
+// ExpiryWorker periodically writes off points credited longer ago than the expiry policy allows.
+type ExpiryWorker struct {
+	er        ExpiryRepository
+	log       *zap.Logger
+	policy    entity.ExpiryPolicy
+	interval  time.Duration
+	batchSize int
+}
+
+func NewExpiryWorker(expiryRepository ExpiryRepository, cfg ExpiryConfig, log *zap.Logger) *ExpiryWorker {
+	return &ExpiryWorker{
+		er:        expiryRepository,
+		policy:    cfg.Policy,
+		interval:  cfg.Interval,
+		batchSize: max(cfg.BatchSize, 1),
+		log:       log.With(zap.String("worker", "points expiry")),
+	}
+}
+
+func (w *ExpiryWorker) Run(ctx context.Context) {
+	if !w.policy.Enabled() {
+		w.log.Info("points never expire, worker is not started")
+		return
+	}
+
+	w.log.Info("start worker", zap.Int("expiry months", w.policy.Months), zap.Duration("interval", w.interval))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.expire(ctx)
+
+		select {
+		case <-ctx.Done():
+			w.log.Info("received done context")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire writes off lots in batches until there is nothing left to expire.
+func (w *ExpiryWorker) expire(ctx context.Context) {
+	var total int
+
+	for ctx.Err() == nil {
+		expired, err := w.er.ExpireLots(ctx, w.policy.CreditedBefore(time.Now()), w.batchSize)
+		total += expired
+		if err != nil {
+			w.log.Warn("can't expire points", zap.Error(err))
+			break
+		}
+		if expired < w.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		w.log.Info("points expired", zap.Int("lots", total))
+	}
+}
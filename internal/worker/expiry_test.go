@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/ivas1ly/gophermart/internal/entity"
+	"github.com/ivas1ly/gophermart/internal/lib/logger"
+)
+
+type memoryLots struct {
+	creditedAt []time.Time
+	expired    int
+	mu         sync.Mutex
+}
+
+func (l *memoryLots) ExpireLots(_ context.Context, creditedBefore time.Time, limit int) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expired int
+	left := l.creditedAt[:0]
+	for _, creditedAt := range l.creditedAt {
+		if expired < limit && creditedAt.Before(creditedBefore) {
+			expired++
+			continue
+		}
+		left = append(left, creditedAt)
+	}
+	l.creditedAt = left
+	l.expired += expired
+
+	return expired, nil
+}
+
+func (l *memoryLots) state() (left, expired int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.creditedAt), l.expired
+}
+
+func TestExpiryWorker(t *testing.T) {
+	log := logger.New(defaultLogLevel, zap.NewDevelopmentConfig())
+
+	now := time.Now()
+	lots := &memoryLots{creditedAt: []time.Time{
+		now.AddDate(0, -13, 0),
+		now.AddDate(0, -12, -1),
+		now.AddDate(-2, 0, 0),
+		now.AddDate(0, -11, 0),
+		now,
+	}}
+
+	w := NewExpiryWorker(lots, ExpiryConfig{
+		Policy:    entity.ExpiryPolicy{Months: 12},
+		Interval:  time.Hour,
+		BatchSize: 2,
+	}, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go w.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		_, expired := lots.state()
+		return expired == 3
+	}, defaultTestTimeout, defaultTestTick)
+
+	left, _ := lots.state()
+	assert.Equal(t, 2, left)
+}
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS locked_by TEXT,
+  ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS orders_status_idx ON orders (status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS orders_status_idx;
+
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS locked_until,
+  DROP COLUMN IF EXISTS locked_by;
+-- +goose StatementEnd
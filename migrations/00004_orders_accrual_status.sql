@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS accrual_status TEXT,
+  ADD COLUMN IF NOT EXISTS accrual_checked_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS accrual_checked_at,
+  DROP COLUMN IF EXISTS accrual_status;
+-- +goose StatementEnd
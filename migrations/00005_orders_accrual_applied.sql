@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS accrual_applied_at TIMESTAMPTZ;
+
+UPDATE orders
+SET accrual_applied_at = updated_at
+WHERE status = 'PROCESSED';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS accrual_applied_at;
+-- +goose StatementEnd
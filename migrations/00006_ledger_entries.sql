@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS ledger_entries(
+  id uuid PRIMARY KEY,
+  user_id uuid NOT NULL,
+  kind VARCHAR(32) NOT NULL CHECK (kind IN ('accrual', 'withdrawal', 'adjustment', 'reversal')),
+  amount BIGINT NOT NULL CHECK (amount <> 0),
+  order_number TEXT,
+  reference_id uuid,
+  reason TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_ledger_entries FOREIGN KEY (reference_id) REFERENCES ledger_entries (id)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_user_id_idx ON ledger_entries (user_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS ledger_entries_accrual_idx ON ledger_entries (order_number)
+  WHERE kind = 'accrual';
+CREATE UNIQUE INDEX IF NOT EXISTS ledger_entries_withdrawal_idx ON ledger_entries (order_number)
+  WHERE kind = 'withdrawal';
+CREATE UNIQUE INDEX IF NOT EXISTS ledger_entries_reversal_idx ON ledger_entries (reference_id)
+  WHERE kind = 'reversal';
+
+CREATE OR REPLACE FUNCTION ledger_entries_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'ledger entries are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only
+  BEFORE UPDATE OR DELETE ON ledger_entries
+  FOR EACH ROW EXECUTE FUNCTION ledger_entries_append_only();
+
+INSERT INTO ledger_entries (id, user_id, kind, amount, order_number, created_at)
+SELECT gen_random_uuid(), user_id, 'accrual', accrual, number, COALESCE(accrual_applied_at, updated_at)
+FROM orders
+WHERE status = 'PROCESSED' AND accrual > 0;
+
+INSERT INTO ledger_entries (id, user_id, kind, amount, order_number, created_at)
+SELECT gen_random_uuid(), user_id, 'withdrawal', -withdrawn, order_number, created_at
+FROM withdrawals
+WHERE withdrawn > 0;
+
+-- Balances changed outside of orders and withdrawals are kept as opening adjustments.
+INSERT INTO ledger_entries (id, user_id, kind, amount, reason)
+SELECT gen_random_uuid(), users.id, 'adjustment', users.current_balance - COALESCE(SUM(ledger_entries.amount), 0),
+  'opening balance'
+FROM users
+LEFT JOIN ledger_entries ON ledger_entries.user_id = users.id
+GROUP BY users.id
+HAVING users.current_balance <> COALESCE(SUM(ledger_entries.amount), 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE ledger_entries;
+DROP FUNCTION IF EXISTS ledger_entries_append_only;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE withdrawals
+  ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'WITHDRAWN'
+    CHECK (status IN ('WITHDRAWN', 'REVERSED')),
+  ADD COLUMN IF NOT EXISTS reversed_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS reversal_reason TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE withdrawals
+  DROP COLUMN IF EXISTS reversal_reason,
+  DROP COLUMN IF EXISTS reversed_at,
+  DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_kind_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_kind_check
+  CHECK (kind IN ('accrual', 'withdrawal', 'adjustment', 'reversal', 'expiration'));
+
+CREATE TABLE IF NOT EXISTS accrual_lots(
+  id uuid PRIMARY KEY,
+  user_id uuid NOT NULL,
+  entry_id uuid,
+  amount BIGINT NOT NULL CHECK (amount > 0),
+  remaining BIGINT NOT NULL CHECK (remaining >= 0 AND remaining <= amount),
+  credited_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  expired_at TIMESTAMPTZ,
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_ledger_entries FOREIGN KEY (entry_id) REFERENCES ledger_entries (id)
+);
+
+CREATE INDEX IF NOT EXISTS accrual_lots_user_id_idx ON accrual_lots (user_id, credited_at)
+  WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS accrual_lots_credited_at_idx ON accrual_lots (credited_at)
+  WHERE remaining > 0;
+
+-- Existing credits become lots, everything spent so far is taken from the oldest of them.
+INSERT INTO accrual_lots (id, user_id, entry_id, amount, remaining, credited_at)
+SELECT gen_random_uuid(), credits.user_id, credits.id, credits.amount,
+  GREATEST(0, LEAST(credits.amount, credits.credited_total - COALESCE(debits.total, 0))), credits.created_at
+FROM (
+  SELECT id, user_id, amount, created_at,
+    SUM(amount) OVER (PARTITION BY user_id ORDER BY created_at, id) AS credited_total
+  FROM ledger_entries
+  WHERE amount > 0
+) AS credits
+LEFT JOIN (
+  SELECT user_id, -SUM(amount) AS total
+  FROM ledger_entries
+  WHERE amount < 0
+  GROUP BY user_id
+) AS debits ON debits.user_id = credits.user_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE accrual_lots;
+
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_kind_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_kind_check
+  CHECK (kind IN ('accrual', 'withdrawal', 'adjustment', 'reversal'));
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS expected_accrual BIGINT NOT NULL DEFAULT 0 CHECK (expected_accrual >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS expected_accrual;
+-- +goose StatementEnd
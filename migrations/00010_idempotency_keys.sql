@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+  user_id uuid NOT NULL,
+  key TEXT NOT NULL,
+  request_hash TEXT NOT NULL,
+  status_code INT NOT NULL DEFAULT 0,
+  content_type TEXT NOT NULL DEFAULT '',
+  response_body BYTEA,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  expires_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (user_id, key),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,76 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS programs(
+  id VARCHAR(64) PRIMARY KEY,
+  name TEXT NOT NULL,
+  decimal_places SMALLINT NOT NULL CHECK (decimal_places BETWEEN 0 AND 6),
+  conversion_rate NUMERIC(18, 6) NOT NULL CHECK (conversion_rate > 0),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp
+);
+
+INSERT INTO programs (id, name, decimal_places, conversion_rate) VALUES ('default', 'Gophermart', 2, 1);
+
+CREATE TABLE IF NOT EXISTS program_accounts(
+  user_id uuid NOT NULL,
+  program_id VARCHAR(64) NOT NULL,
+  balance BIGINT NOT NULL CHECK (balance >= 0) DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (user_id, program_id),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_programs FOREIGN KEY (program_id) REFERENCES programs (id)
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS program_id VARCHAR(64) NOT NULL DEFAULT 'default'
+  REFERENCES programs (id);
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS program_id VARCHAR(64) NOT NULL DEFAULT 'default'
+  REFERENCES programs (id);
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS program_id VARCHAR(64) NOT NULL DEFAULT 'default'
+  REFERENCES programs (id);
+ALTER TABLE accrual_lots ADD COLUMN IF NOT EXISTS program_id VARCHAR(64) NOT NULL DEFAULT 'default'
+  REFERENCES programs (id);
+
+DROP INDEX IF EXISTS ledger_entries_user_id_idx;
+CREATE INDEX IF NOT EXISTS ledger_entries_user_id_idx ON ledger_entries (user_id, program_id, created_at);
+DROP INDEX IF EXISTS accrual_lots_user_id_idx;
+CREATE INDEX IF NOT EXISTS accrual_lots_user_id_idx ON accrual_lots (user_id, program_id, credited_at)
+  WHERE remaining > 0;
+
+-- The cached user balance becomes the account of the default program.
+INSERT INTO program_accounts (user_id, program_id, balance)
+SELECT id, 'default', current_balance
+FROM users;
+
+ALTER TABLE users DROP COLUMN IF EXISTS current_balance;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS current_balance BIGINT NOT NULL CHECK (current_balance >= 0) DEFAULT 0;
+
+UPDATE users SET current_balance = program_accounts.balance
+FROM program_accounts
+WHERE program_accounts.user_id = users.id AND program_accounts.program_id = 'default';
+
+-- Points of other programs can't be kept without the programs.
+DELETE FROM accrual_lots WHERE program_id <> 'default';
+ALTER TABLE ledger_entries DISABLE TRIGGER ledger_entries_append_only;
+DELETE FROM ledger_entries WHERE program_id <> 'default';
+ALTER TABLE ledger_entries ENABLE TRIGGER ledger_entries_append_only;
+DELETE FROM withdrawals WHERE program_id <> 'default';
+DELETE FROM orders WHERE program_id <> 'default';
+
+DROP INDEX IF EXISTS accrual_lots_user_id_idx;
+CREATE INDEX IF NOT EXISTS accrual_lots_user_id_idx ON accrual_lots (user_id, credited_at)
+  WHERE remaining > 0;
+DROP INDEX IF EXISTS ledger_entries_user_id_idx;
+CREATE INDEX IF NOT EXISTS ledger_entries_user_id_idx ON ledger_entries (user_id, created_at);
+
+ALTER TABLE accrual_lots DROP COLUMN IF EXISTS program_id;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS program_id;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS program_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS program_id;
+
+DROP TABLE program_accounts;
+DROP TABLE programs;
+-- +goose StatementEnd
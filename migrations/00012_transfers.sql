@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_kind_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_kind_check
+  CHECK (kind IN ('accrual', 'withdrawal', 'adjustment', 'reversal', 'expiration', 'transfer_in', 'transfer_out'));
+
+-- Users receive transfers only from the users they allowed.
+CREATE TABLE IF NOT EXISTS transfer_allowlist(
+  user_id uuid NOT NULL,
+  allowed_user_id uuid NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (user_id, allowed_user_id),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id),
+  CONSTRAINT fk_allowed_users FOREIGN KEY (allowed_user_id) REFERENCES users (id),
+  CHECK (user_id <> allowed_user_id)
+);
+
+CREATE TABLE IF NOT EXISTS transfers(
+  id uuid PRIMARY KEY,
+  sender_id uuid NOT NULL,
+  recipient_id uuid NOT NULL,
+  program_id VARCHAR(64) NOT NULL,
+  amount BIGINT NOT NULL CHECK (amount > 0),
+  idempotency_key TEXT NOT NULL,
+  out_entry_id uuid NOT NULL,
+  in_entry_id uuid NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  UNIQUE (sender_id, idempotency_key),
+  CONSTRAINT fk_senders FOREIGN KEY (sender_id) REFERENCES users (id),
+  CONSTRAINT fk_recipients FOREIGN KEY (recipient_id) REFERENCES users (id),
+  CONSTRAINT fk_programs FOREIGN KEY (program_id) REFERENCES programs (id),
+  CONSTRAINT fk_out_entries FOREIGN KEY (out_entry_id) REFERENCES ledger_entries (id),
+  CONSTRAINT fk_in_entries FOREIGN KEY (in_entry_id) REFERENCES ledger_entries (id),
+  CHECK (sender_id <> recipient_id)
+);
+
+CREATE INDEX IF NOT EXISTS transfers_sender_id_idx ON transfers (sender_id, program_id, created_at);
+CREATE INDEX IF NOT EXISTS transfers_recipient_id_idx ON transfers (recipient_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE transfers;
+DROP TABLE transfer_allowlist;
+
+ALTER TABLE ledger_entries DISABLE TRIGGER ledger_entries_append_only;
+DELETE FROM accrual_lots WHERE entry_id IN (SELECT id FROM ledger_entries WHERE kind = 'transfer_in');
+DELETE FROM ledger_entries WHERE kind IN ('transfer_in', 'transfer_out');
+ALTER TABLE ledger_entries ENABLE TRIGGER ledger_entries_append_only;
+
+ALTER TABLE ledger_entries DROP CONSTRAINT IF EXISTS ledger_entries_kind_check;
+ALTER TABLE ledger_entries ADD CONSTRAINT ledger_entries_kind_check
+  CHECK (kind IN ('accrual', 'withdrawal', 'adjustment', 'reversal', 'expiration'));
+-- +goose StatementEnd
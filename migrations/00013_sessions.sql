@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS sessions(
+  id uuid PRIMARY KEY,
+  user_id uuid NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  refreshed_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ,
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON sessions (expires_at);
+
+-- Only SHA-256 hashes of refresh tokens are stored. Used tokens are kept until the session is
+-- deleted to detect their reuse.
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+  token_hash TEXT PRIMARY KEY,
+  session_id uuid NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ,
+  CONSTRAINT fk_sessions FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE refresh_tokens;
+DROP TABLE sessions;
+-- +goose StatementEnd
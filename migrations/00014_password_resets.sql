@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Only SHA-256 hashes of reset tokens are stored.
+CREATE TABLE IF NOT EXISTS password_resets(
+  token_hash TEXT PRIMARY KEY,
+  user_id uuid NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ,
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_id_idx ON password_resets (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE password_resets;
+-- +goose StatementEnd
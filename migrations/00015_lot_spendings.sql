@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Lots each debit was taken from, points returned by a reversal or a transfer keep the credit
+-- time of these lots.
+CREATE TABLE IF NOT EXISTS lot_spendings(
+  entry_id uuid NOT NULL,
+  lot_id uuid NOT NULL,
+  amount BIGINT NOT NULL CHECK (amount > 0),
+  PRIMARY KEY (entry_id, lot_id),
+  CONSTRAINT fk_ledger_entries FOREIGN KEY (entry_id) REFERENCES ledger_entries (id),
+  CONSTRAINT fk_accrual_lots FOREIGN KEY (lot_id) REFERENCES accrual_lots (id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE lot_spendings;
+-- +goose StatementEnd
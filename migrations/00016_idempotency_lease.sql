@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keys in progress can be taken over by a retry of the same request after the lease, the first
+-- request may have never stored its response.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+UPDATE idempotency_keys SET locked_until = now() WHERE status_code = 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+-- +goose StatementEnd
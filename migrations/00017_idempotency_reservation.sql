@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every reservation of a key gets its own id, a request only saves or releases the key while
+-- it still owns the reservation.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS reservation_id uuid;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS reservation_id;
+-- +goose StatementEnd
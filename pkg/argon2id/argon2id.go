@@ -6,8 +6,8 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"runtime"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -16,12 +16,21 @@ var (
 	ErrInvalidHash         = errors.New("argon2id: hash is not in the correct format")
 	ErrIncompatibleVariant = errors.New("argon2id: incompatible variant of argon2")
 	ErrIncompatibleVersion = errors.New("argon2id: incompatible version of argon2")
+	ErrInvalidParams       = errors.New("argon2id: invalid params")
 )
 
+const (
+	minSaltLength = 8
+	minKeyLength  = 16
+	// minMemoryPerThread is the minimum memory in KiB argon2 needs for each thread.
+	minMemoryPerThread = 8
+)
+
+// DefaultParams don't depend on the machine, so hashes created anywhere have the same params.
 var DefaultParams = &Params{
 	Memory:      64 * 1024,
 	Iterations:  1,
-	Parallelism: uint8(runtime.NumCPU()),
+	Parallelism: 1,
 	SaltLength:  16,
 	KeyLength:   32,
 }
@@ -34,6 +43,81 @@ type Params struct {
 	KeyLength   uint32
 }
 
+// Validate checks that the params can be used to create hashes.
+func (p *Params) Validate() error {
+	switch {
+	case p.Iterations < 1:
+		return fmt.Errorf("%w: iterations must be at least 1", ErrInvalidParams)
+	case p.Parallelism < 1:
+		return fmt.Errorf("%w: parallelism must be at least 1", ErrInvalidParams)
+	case p.Memory < minMemoryPerThread*uint32(p.Parallelism):
+		return fmt.Errorf("%w: memory must be at least %d KiB per thread", ErrInvalidParams, minMemoryPerThread)
+	case p.SaltLength < minSaltLength:
+		return fmt.Errorf("%w: salt must be at least %d bytes", ErrInvalidParams, minSaltLength)
+	case p.KeyLength < minKeyLength:
+		return fmt.Errorf("%w: key must be at least %d bytes", ErrInvalidParams, minKeyLength)
+	}
+	return nil
+}
+
+// NeedsRehash reports whether a hash created with hashParams should be replaced with a hash
+// created with params. Any difference counts, so the params can be lowered too.
+func NeedsRehash(hashParams, params *Params) bool {
+	return *hashParams != *params
+}
+
+// Benchmark picks params for a hash that takes about the target time on this machine. Memory
+// of the base params is doubled up to maxMemory KiB first, then iterations are added. The
+// strongest params that don't exceed the target and their hash time are returned, the base
+// params are returned if even they exceed it.
+func Benchmark(target time.Duration, maxMemory uint32, base *Params) (*Params, time.Duration, error) {
+	if err := base.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	best := *base
+	elapsed, err := measure(&best)
+	if err != nil {
+		return nil, 0, err
+	}
+	if elapsed >= target {
+		return &best, elapsed, nil
+	}
+
+	for {
+		candidate := best
+		if candidate.Memory <= maxMemory/2 {
+			candidate.Memory *= 2
+		} else {
+			candidate.Iterations++
+		}
+
+		candidateElapsed, err := measure(&candidate)
+		if err != nil {
+			return nil, 0, err
+		}
+		if candidateElapsed > target {
+			break
+		}
+
+		best, elapsed = candidate, candidateElapsed
+	}
+
+	return &best, elapsed, nil
+}
+
+// measure returns the time to hash a random password with the params.
+func measure(params *Params) (time.Duration, error) {
+	password, err := generateRandomBytes(params.KeyLength)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	_, err = CreateHash(string(password), params)
+	return time.Since(start), err
+}
+
 // CreateHash - https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html
 func CreateHash(password string, params *Params) (hash string, err error) {
 	salt, err := generateRandomBytes(params.SaltLength)
@@ -3,6 +3,7 @@ package argon2id
 import (
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -69,3 +70,50 @@ func TestVariant(t *testing.T) {
 		"$argon2d$v=19$m=65536,t=1,p=8$QrnU5AAUZNdblXhW48VaQA$+FMwKJQ/VizkNgWDXFf9yPMLsomaXQDDT0OFbZEw9SU")
 	assert.ErrorIs(t, err, ErrIncompatibleVariant)
 }
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := CreateHash("uwuowo", &Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16,
+		KeyLength: 32})
+	assert.NoError(t, err)
+
+	ok, params, err := CheckHash("uwuowo", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.True(t, NeedsRehash(params, DefaultParams))
+	assert.False(t, NeedsRehash(params, &Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16,
+		KeyLength: 32}))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, DefaultParams.Validate())
+
+	invalid := []Params{
+		{Memory: 1024, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 0, SaltLength: 16, KeyLength: 32},
+		{Memory: 8, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 8},
+	}
+	for _, params := range invalid {
+		assert.ErrorIs(t, params.Validate(), ErrInvalidParams)
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	base := &Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	params, elapsed, err := Benchmark(0, 1024, base)
+	assert.NoError(t, err)
+	assert.Equal(t, *base, *params)
+	assert.Positive(t, elapsed)
+
+	params, _, err = Benchmark(20*time.Millisecond, 1024, base)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, params.Memory, uint32(1024))
+	assert.GreaterOrEqual(t, params.Memory, base.Memory)
+	assert.Equal(t, base.Parallelism, params.Parallelism)
+
+	_, _, err = Benchmark(time.Second, 1024, &Params{})
+	assert.ErrorIs(t, err, ErrInvalidParams)
+}
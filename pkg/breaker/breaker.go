@@ -0,0 +1,158 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("breaker: circuit is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type Settings struct {
+	// OnStateChange is called synchronously on every transition, it must not call the breaker.
+	OnStateChange func(from, to State)
+	// FailureThreshold is the number of consecutive failures that opens the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probe requests are allowed.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of successful probes needed to close the circuit.
+	HalfOpenRequests int
+}
+
+// Breaker is a circuit breaker with closed, open and half-open states.
+type Breaker struct {
+	openedAt time.Time
+	now      func() time.Time
+	settings Settings
+	state    State
+	failures int
+	probes   int
+	passed   int
+	mu       sync.Mutex
+}
+
+func New(settings Settings) *Breaker {
+	settings.FailureThreshold = max(settings.FailureThreshold, 1)
+	settings.HalfOpenRequests = max(settings.HalfOpenRequests, 1)
+
+	return &Breaker{
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+// State returns the current state, an open circuit becomes half-open after the timeout.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkTimeout()
+
+	return b.state
+}
+
+// Allow returns ErrOpen if the request must not be sent. Every allowed request must be
+// followed by Done with its result or by Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.checkTimeout()
+
+	switch b.state {
+	case StateOpen:
+		return ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			return ErrOpen
+		}
+		b.probes++
+	case StateClosed:
+	}
+
+	return nil
+}
+
+// Done records the result of a request allowed by Allow.
+func (b *Breaker) Done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if !success {
+			b.setState(StateOpen)
+			return
+		}
+
+		b.passed++
+		if b.passed >= b.settings.HalfOpenRequests {
+			b.setState(StateClosed)
+		}
+	case StateOpen:
+	}
+}
+
+// Release returns the slot of a request allowed by Allow whose result says nothing about
+// the upstream, for example a request that wasn't sent. The state is not changed.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *Breaker) checkTimeout() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+}
+
+func (b *Breaker) setState(state State) {
+	from := b.state
+
+	b.state = state
+	b.failures = 0
+	b.probes = 0
+	b.passed = 0
+
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+
+	if b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(from, state)
+	}
+}
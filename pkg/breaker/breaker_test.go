@@ -0,0 +1,80 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+
+	var transitions []string
+	b := New(Settings{
+		FailureThreshold: 3,
+		OpenTimeout:      time.Minute,
+		HalfOpenRequests: 2,
+		OnStateChange: func(from, to State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	b.now = func() time.Time { return now }
+
+	t.Run("opens after consecutive failures", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, b.Allow())
+			b.Done(false)
+		}
+		assert.NoError(t, b.Allow())
+		b.Done(true)
+		assert.Equal(t, StateClosed, b.State())
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, b.Allow())
+			b.Done(false)
+		}
+		assert.Equal(t, StateOpen, b.State())
+		assert.ErrorIs(t, b.Allow(), ErrOpen)
+	})
+
+	t.Run("half-open after timeout and back to open on failure", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		assert.Equal(t, StateHalfOpen, b.State())
+
+		assert.NoError(t, b.Allow())
+		b.Done(false)
+		assert.Equal(t, StateOpen, b.State())
+	})
+
+	t.Run("released probe keeps the circuit half-open", func(t *testing.T) {
+		now = now.Add(time.Minute)
+
+		assert.NoError(t, b.Allow())
+		assert.NoError(t, b.Allow())
+		assert.ErrorIs(t, b.Allow(), ErrOpen)
+
+		b.Release()
+		b.Release()
+		assert.Equal(t, StateHalfOpen, b.State())
+	})
+
+	t.Run("limits probes and closes after successful ones", func(t *testing.T) {
+		assert.NoError(t, b.Allow())
+		assert.NoError(t, b.Allow())
+		assert.ErrorIs(t, b.Allow(), ErrOpen)
+
+		b.Done(true)
+		assert.Equal(t, StateHalfOpen, b.State())
+		b.Done(true)
+		assert.Equal(t, StateClosed, b.State())
+	})
+
+	assert.Equal(t, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}, transitions)
+}
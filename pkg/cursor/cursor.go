@@ -0,0 +1,51 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const separator = "|"
+
+// Cursor is the position of the last row of a page sorted by time and UUID.
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+// Encode returns an opaque URL-safe representation of the cursor.
+func (c Cursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + separator + c.ID
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode parses a cursor created by Encode, the ID must be a UUID.
+func Decode(value string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	rawTime, id, ok := strings.Cut(string(raw), separator)
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if _, err = uuid.Parse(id); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, rawTime)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{Time: t, ID: id}, nil
+}
@@ -0,0 +1,43 @@
+package cursor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("encode and decode", func(t *testing.T) {
+		c := Cursor{
+			Time: time.Date(2024, time.February, 10, 12, 0, 0, 123456000, time.UTC),
+			ID:   "018d9a5e-4f7a-7c3e-9b1a-2f3c4d5e6f70",
+		}
+
+		decoded, err := Decode(c.Encode())
+		require.NoError(t, err)
+		assert.True(t, c.Time.Equal(decoded.Time))
+		assert.Equal(t, c.ID, decoded.ID)
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		_, err := Decode("not a cursor!")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("missing id", func(t *testing.T) {
+		_, err := Decode(Cursor{Time: time.Now()}.Encode())
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("id is not uuid", func(t *testing.T) {
+		_, err := Decode(Cursor{Time: time.Now(), ID: "1' OR '1'='1"}.Encode())
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("invalid time", func(t *testing.T) {
+		_, err := Decode("eWVzdGVyZGF5fGlk")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
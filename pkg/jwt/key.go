@@ -0,0 +1,156 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+const minRSAKeyBits = 2048
+
+var (
+	ErrNoPEMBlock         = errors.New("no PEM block found")
+	ErrUnsupportedKeyType = errors.New("unsupported key type, only RSA and Ed25519 keys are supported")
+	ErrWeakRSAKey         = fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+)
+
+// Key is a key of the token signature. Keys without the private part only verify tokens,
+// such keys are kept after a rotation until the tokens signed by them expire.
+type Key struct {
+	Signer    crypto.Signer
+	Public    crypto.PublicKey
+	ID        string
+	Algorithm jwa.SignatureAlgorithm
+}
+
+// GenerateKey returns a new Ed25519 key.
+func GenerateKey() (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("can't generate ed25519 key: %w", err)
+	}
+
+	return newKey(private, private.Public())
+}
+
+// LoadKey reads the key from the PEM file.
+func LoadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read key file: %w", err)
+	}
+
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse key file %q: %w", path, err)
+	}
+
+	return key, nil
+}
+
+// ParseKey parses a PKCS #8 or PKCS #1 private key or a PKIX or PKCS #1 public key in PEM format.
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEMBlock
+	}
+
+	var (
+		raw any
+		err error
+	)
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		raw, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		raw, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		raw, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		raw, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := raw.(type) {
+	case *rsa.PrivateKey:
+		return newKey(key, key.Public())
+	case ed25519.PrivateKey:
+		return newKey(key, key.Public())
+	case *rsa.PublicKey:
+		return newKey(nil, key)
+	case ed25519.PublicKey:
+		return newKey(nil, key)
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// newKey identifies the key by the RFC 7638 thumbprint of its public part, so the same key
+// always gets the same ID without any configuration.
+func newKey(signer crypto.Signer, public crypto.PublicKey) (*Key, error) {
+	key := &Key{
+		Signer: signer,
+		Public: public,
+	}
+
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSAKeyBits {
+			return nil, ErrWeakRSAKey
+		}
+		key.Algorithm = jwa.RS256
+	case ed25519.PublicKey:
+		key.Algorithm = jwa.EdDSA
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+
+	publicKey, err := jwk.FromRaw(public)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbprint, err := publicKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	return key, nil
+}
+
+// JWK returns the public part of the key in JWK format.
+func (k *Key) JWK() (jwk.Key, error) {
+	publicKey, err := jwk.FromRaw(k.Public)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range map[string]any{
+		jwk.KeyIDKey:     k.ID,
+		jwk.AlgorithmKey: k.Algorithm,
+		jwk.KeyUsageKey:  jwk.ForSignature,
+	} {
+		if err = publicKey.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return publicKey, nil
+}
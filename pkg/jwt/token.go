@@ -1,40 +1,110 @@
 package jwt
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	jwxjwt "github.com/lestrrat-go/jwx/v2/jwt"
 )
 
 const (
-	OneDay = 24 * time.Hour
+	// SessionClaim is the claim with the ID of the session the token is issued for.
+	SessionClaim = "sid"
+
+	DefaultTTL = 15 * time.Minute
+
+	issuer = "gophermart"
 )
 
-var SigningKey []byte
+var ErrNoSigningKey = errors.New("no private key to sign tokens")
+
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+}
+
+// KeySet signs tokens with its first private key and verifies tokens signed by any of its keys,
+// the key is chosen by the "kid" header of the token.
+type KeySet struct {
+	signing *Key
+	public  jwk.Set
+}
+
+func NewKeySet(keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{
+		public: jwk.NewSet(),
+	}
+
+	for _, key := range keys {
+		if ks.signing == nil && key.Signer != nil {
+			ks.signing = key
+		}
+
+		publicKey, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := ks.public.LookupKeyID(key.ID); ok {
+			continue
+		}
 
-func NewToken(key []byte, id string) (string, error) {
+		err = ks.public.AddKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ks.signing == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return ks, nil
+}
+
+// NewToken returns the access token of the user session valid for the ttl.
+func (ks *KeySet) NewToken(id, sessionID string, ttl time.Duration) (string, error) {
 	tokenID, err := uuid.NewV7()
 	if err != nil {
 		return "", fmt.Errorf("jwt can't get new uuid v7: %w", err)
 	}
 
-	claims := jwt.RegisteredClaims{
-		Issuer:    "gophermart",
-		Subject:   id,
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(OneDay)),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		ID:        tokenID.String(),
+	now := time.Now()
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   id,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        tokenID.String(),
+		},
+		SessionID: sessionID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(ks.signing.Algorithm.String()), claims)
+	token.Header["kid"] = ks.signing.ID
 
-	ss, err := token.SignedString(key)
+	ss, err := token.SignedString(ks.signing.Signer)
 	if err != nil {
 		return "", fmt.Errorf("jwt can't sign string: %w", err)
 	}
 
 	return ss, nil
 }
+
+// Verify checks the signature and the time claims of the token.
+func (ks *KeySet) Verify(token string) (jwxjwt.Token, error) {
+	return jwxjwt.Parse([]byte(token), jwxjwt.WithKeySet(ks.public), jwxjwt.WithIssuer(issuer),
+		jwxjwt.WithValidate(true))
+}
+
+// PublicKeys returns the public keys of the set to publish them as JWKS.
+func (ks *KeySet) PublicKeys() jwk.Set {
+	return ks.public
+}
@@ -1,31 +1,186 @@
 package jwt
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestToken(t *testing.T) {
-	SigningKey = []byte("36626d331c8c44f2d72f348f36323743598e267e86b3e4aca27c5b433247ea72")
+	key, err := GenerateKey()
+	require.NoError(t, err)
+
+	keys, err := NewKeySet(key)
+	require.NoError(t, err)
 
 	id, err := uuid.NewV7()
 	assert.NoError(t, err)
 
+	sessionID, err := uuid.NewV7()
+	assert.NoError(t, err)
+
 	t.Run("check token", func(t *testing.T) {
-		signedToken, err := NewToken(SigningKey, id.String())
+		signedToken, err := keys.NewToken(id.String(), sessionID.String(), DefaultTTL)
 		assert.NoError(t, err)
 		assert.Equal(t, len(strings.Split(signedToken, ".")), 3)
 
 		claims := jwt.MapClaims{}
 		token, err := jwt.ParseWithClaims(signedToken, claims, func(_ *jwt.Token) (interface{}, error) {
-			return SigningKey, nil
+			return key.Public, nil
 		})
 		assert.NoError(t, err)
 		assert.Equal(t, token.Valid, true)
+		assert.Equal(t, key.ID, token.Header["kid"])
 		assert.Equal(t, claims["sub"], id.String())
+		assert.Equal(t, claims[SessionClaim], sessionID.String())
 	})
+
+	t.Run("verify token", func(t *testing.T) {
+		signedToken, err := keys.NewToken(id.String(), sessionID.String(), DefaultTTL)
+		require.NoError(t, err)
+
+		token, err := keys.Verify(signedToken)
+		require.NoError(t, err)
+		assert.Equal(t, id.String(), token.Subject())
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		signedToken, err := keys.NewToken(id.String(), sessionID.String(), -time.Minute)
+		require.NoError(t, err)
+
+		_, err = keys.Verify(signedToken)
+		assert.Error(t, err)
+	})
+
+	t.Run("token of unknown key", func(t *testing.T) {
+		anotherKey, err := GenerateKey()
+		require.NoError(t, err)
+
+		anotherKeys, err := NewKeySet(anotherKey)
+		require.NoError(t, err)
+
+		signedToken, err := anotherKeys.NewToken(id.String(), sessionID.String(), DefaultTTL)
+		require.NoError(t, err)
+
+		_, err = keys.Verify(signedToken)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, err := GenerateKey()
+	require.NoError(t, err)
+
+	oldKeys, err := NewKeySet(oldKey)
+	require.NoError(t, err)
+
+	oldToken, err := oldKeys.NewToken("user", "session", DefaultTTL)
+	require.NoError(t, err)
+
+	newKey := generateRSAKey(t)
+
+	// The old key is kept only to verify tokens issued before the rotation.
+	publicOldKey := &Key{Public: oldKey.Public, ID: oldKey.ID, Algorithm: oldKey.Algorithm}
+
+	keys, err := NewKeySet(publicOldKey, newKey)
+	require.NoError(t, err)
+
+	_, err = keys.Verify(oldToken)
+	assert.NoError(t, err)
+
+	newToken, err := keys.NewToken("user", "session", DefaultTTL)
+	require.NoError(t, err)
+
+	token, err := keys.Verify(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user", token.Subject())
+
+	t.Run("published keys", func(t *testing.T) {
+		data, err := json.Marshal(keys.PublicKeys())
+		require.NoError(t, err)
+
+		var jwks struct {
+			Keys []map[string]any `json:"keys"`
+		}
+		require.NoError(t, json.Unmarshal(data, &jwks))
+		require.Len(t, jwks.Keys, 2)
+
+		for _, published := range jwks.Keys {
+			assert.NotContains(t, published, "d", "private part must not be published")
+			assert.Equal(t, "sig", published["use"])
+		}
+		assert.Equal(t, oldKey.ID, jwks.Keys[0]["kid"])
+		assert.Equal(t, newKey.ID, jwks.Keys[1]["kid"])
+	})
+
+	t.Run("set without private keys", func(t *testing.T) {
+		_, err := NewKeySet(publicOldKey)
+		assert.ErrorIs(t, err, ErrNoSigningKey)
+	})
+}
+
+func TestParseKey(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+
+	pkix, err := x509.MarshalPKIXPublicKey(private.Public())
+	require.NoError(t, err)
+
+	privateKey, err := ParseKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	require.NoError(t, err)
+	assert.Equal(t, jwa.EdDSA, privateKey.Algorithm)
+	assert.NotNil(t, privateKey.Signer)
+
+	publicKey, err := ParseKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+	require.NoError(t, err)
+	assert.Nil(t, publicKey.Signer)
+	assert.Equal(t, privateKey.ID, publicKey.ID, "key ID must not depend on the private part")
+
+	t.Run("rsa key", func(t *testing.T) {
+		key := generateRSAKey(t)
+		assert.Equal(t, jwa.RS256, key.Algorithm)
+	})
+
+	t.Run("weak rsa key", func(t *testing.T) {
+		weak, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+
+		_, err = ParseKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(weak)}))
+		assert.ErrorIs(t, err, ErrWeakRSAKey)
+	})
+
+	t.Run("not a PEM", func(t *testing.T) {
+		_, err := ParseKey([]byte("secret"))
+		assert.ErrorIs(t, err, ErrNoPEMBlock)
+	})
+}
+
+func generateRSAKey(t *testing.T) *Key {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+	require.NoError(t, err)
+
+	key, err := ParseKey(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(private),
+	}))
+	require.NoError(t, err)
+
+	return key
 }
@@ -0,0 +1,136 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+type Settings struct {
+	// FreeAttempts is the number of failures allowed without a delay.
+	FreeAttempts int
+	// MaxAttempts is the number of failures that locks the key out for LockoutDuration.
+	MaxAttempts int
+	// BaseDelay is the delay after the first failure over the free attempts, it is doubled
+	// on every next failure up to LockoutDuration.
+	BaseDelay time.Duration
+	// LockoutDuration is how long the key is locked out.
+	LockoutDuration time.Duration
+	// ResetAfter is the time after the last failure when the failures are forgotten.
+	ResetAfter time.Duration
+}
+
+type entry struct {
+	lastFailure  time.Time
+	blockedUntil time.Time
+	failures     int
+}
+
+// Limiter tracks failed attempts per key and blocks the key with progressive delays and
+// a lockout after too many failures.
+type Limiter struct {
+	entries   map[string]*entry
+	lastSweep time.Time
+	now       func() time.Time
+	settings  Settings
+	mu        sync.Mutex
+}
+
+func New(settings Settings) *Limiter {
+	settings.MaxAttempts = max(settings.MaxAttempts, 1)
+	settings.FreeAttempts = min(max(settings.FreeAttempts, 0), settings.MaxAttempts)
+	settings.ResetAfter = max(settings.ResetAfter, settings.LockoutDuration)
+
+	return &Limiter{
+		entries:  make(map[string]*entry),
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+// Reserve counts the attempt of the key as failed before it is made, so concurrent attempts
+// can't pass the check at once. It returns how long the key is blocked, the blocked attempt
+// is not counted. A successful attempt is taken back by Refund or Reset.
+func (l *Limiter) Reserve(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	if e, ok := l.entries[key]; ok && now.Before(e.blockedUntil) {
+		return e.blockedUntil.Sub(now)
+	}
+
+	l.fail(key, now)
+
+	return 0
+}
+
+// Refund takes back an attempt counted by Reserve. The block is lifted only if the rest of
+// the failures are free, a delay earned by the other failures is kept.
+func (l *Limiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok || e.failures == 0 {
+		return
+	}
+
+	e.failures--
+	if e.failures <= l.settings.FreeAttempts {
+		e.blockedUntil = time.Time{}
+	}
+}
+
+// Reset forgets the failures of the key.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// fail counts a failed attempt of the key and blocks the key if it is over the free attempts.
+func (l *Limiter) fail(key string, now time.Time) {
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.lastFailure) >= l.settings.ResetAfter {
+		e = &entry{}
+		l.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	switch {
+	case e.failures >= l.settings.MaxAttempts:
+		e.blockedUntil = now.Add(l.settings.LockoutDuration)
+	case e.failures > l.settings.FreeAttempts:
+		e.blockedUntil = now.Add(l.delay(e.failures - l.settings.FreeAttempts))
+	}
+}
+
+func (l *Limiter) delay(penalized int) time.Duration {
+	delay := l.settings.BaseDelay
+	for i := 1; i < penalized && delay < l.settings.LockoutDuration; i++ {
+		delay *= 2
+	}
+
+	return min(delay, l.settings.LockoutDuration)
+}
+
+// sweep removes forgotten keys at most once per ResetAfter, so the limiter doesn't grow
+// with keys that aren't used anymore.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.settings.ResetAfter {
+		return
+	}
+	l.lastSweep = now
+
+	for key, e := range l.entries {
+		if now.Sub(e.lastFailure) >= l.settings.ResetAfter && !now.Before(e.blockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+
+	l := New(Settings{
+		FreeAttempts:    2,
+		MaxAttempts:     5,
+		BaseDelay:       time.Second,
+		LockoutDuration: time.Minute,
+		ResetAfter:      time.Hour,
+	})
+	l.now = func() time.Time { return now }
+
+	t.Run("free attempts", func(t *testing.T) {
+		assert.Zero(t, l.Reserve("user"))
+		assert.Zero(t, l.Reserve("user"))
+		assert.Zero(t, l.Reserve("user"))
+	})
+
+	t.Run("progressive delays", func(t *testing.T) {
+		assert.Equal(t, time.Second, l.Reserve("user"))
+
+		now = now.Add(time.Second)
+		assert.Zero(t, l.Reserve("user"))
+		assert.Equal(t, 2*time.Second, l.Reserve("user"))
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		now = now.Add(2 * time.Second)
+		assert.Zero(t, l.Reserve("user"))
+		assert.Equal(t, time.Minute, l.Reserve("user"))
+
+		now = now.Add(30 * time.Second)
+		assert.Equal(t, 30*time.Second, l.Reserve("user"))
+		assert.Zero(t, l.Reserve("another user"))
+	})
+
+	t.Run("blocked attempts are not counted", func(t *testing.T) {
+		assert.Equal(t, 5, l.entries["user"].failures)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		l.Reset("user")
+		assert.Zero(t, l.Reserve("user"))
+		assert.Equal(t, 1, l.entries["user"].failures)
+	})
+
+	t.Run("refund", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Zero(t, l.Reserve("refunded"))
+		}
+
+		l.Refund("refunded")
+		assert.Zero(t, l.Reserve("refunded"), "refunded attempt must lift its delay")
+
+		now = now.Add(time.Second)
+		assert.Zero(t, l.Reserve("refunded"))
+		l.Refund("refunded")
+		assert.Equal(t, 2*time.Second, l.Reserve("refunded"), "delay of the other failures must be kept")
+	})
+
+	t.Run("failures are forgotten", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			l.Reserve("forgotten")
+		}
+
+		now = now.Add(2 * time.Hour)
+		assert.Zero(t, l.Reserve("forgotten"))
+		assert.Len(t, l.entries, 1, "stale keys must be removed")
+	})
+}